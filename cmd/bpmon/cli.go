@@ -2,20 +2,37 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/checker"
 	"github.com/unprofession-al/bpmon/internal/config"
+	"github.com/unprofession-al/bpmon/internal/daemon"
 	"github.com/unprofession-al/bpmon/internal/dashboard"
+	"github.com/unprofession-al/bpmon/internal/exporter"
+	"github.com/unprofession-al/bpmon/internal/report"
 	"github.com/unprofession-al/bpmon/internal/runners"
 	"github.com/unprofession-al/bpmon/internal/store"
 	"gopkg.in/yaml.v2"
 
 	_ "github.com/unprofession-al/bpmon/internal/checker/icinga"
+	_ "github.com/unprofession-al/bpmon/internal/checker/prometheus"
 	_ "github.com/unprofession-al/bpmon/internal/store/influx"
+	_ "github.com/unprofession-al/bpmon/internal/store/memory"
+	_ "github.com/unprofession-al/bpmon/internal/store/sql"
 )
 
 const baseEnv = "BPMON_BASE"
@@ -32,16 +49,35 @@ type App struct {
 
 		// config
 		configInitComments bool
+		configPrintBP      string
 
 		// dashboard
 		dashboardPepper string
 		dashboardHeader string
 		dashboardStatic string
 
+		// dashboard, serve, exporter
+		watchInterval time.Duration
+
+		// report
+		reportStart  string
+		reportEnd    string
+		reportFormat string
+
 		// run
-		runParams []string
-		runList   bool
-		runAdHoc  string
+		runParams  []string
+		runList    bool
+		runAdHoc   string
+		runExplain bool
+	}
+
+	// state is kept across reloads of the long running subcommands, see
+	// 'load' and 'openStore'.
+	state struct {
+		sync.Mutex
+		store       store.Accessor
+		storeConfig store.Config
+		watched     []string
 	}
 
 	// entry point
@@ -82,6 +118,7 @@ func NewApp() *App {
 		Short: "Print the given configurantion section as interpreted by BPMON to stdout",
 		Run:   a.configPrintCmd,
 	}
+	configPrintCmd.PersistentFlags().StringVar(&a.cfg.configPrintBP, "bp", "", "print the rules effective for the business process with the given ID instead")
 	configCmd.AddCommand(configPrintCmd)
 
 	// config init
@@ -110,6 +147,7 @@ func NewApp() *App {
 	dashboardCmd.PersistentFlags().StringVarP(&a.cfg.dashboardPepper, "pepper", "", "", "Pepper used to generate auth token")
 	dashboardCmd.PersistentFlags().StringVarP(&a.cfg.dashboardHeader, "header", "", "", "HTTP header name to read recipients from")
 	dashboardCmd.PersistentFlags().StringVarP(&a.cfg.dashboardStatic, "static", "", "", "Path to custom html frontend")
+	dashboardCmd.PersistentFlags().DurationVar(&a.cfg.watchInterval, "watch", 10*time.Second, "interval to check the configuration files for changes, 0 disables the check")
 	rootCmd.AddCommand(dashboardCmd)
 
 	// run
@@ -122,8 +160,34 @@ func NewApp() *App {
 	runCmd.PersistentFlags().StringSliceVar(&a.cfg.runParams, "params", []string{}, "Provide template parameters")
 	runCmd.PersistentFlags().BoolVar(&a.cfg.runList, "list", false, "print a list of available runners")
 	runCmd.PersistentFlags().StringVar(&a.cfg.runAdHoc, "adhoc", "", "pass a runner template as param")
+	runCmd.PersistentFlags().BoolVar(&a.cfg.runExplain, "explain", false, "explain the status of all services which are not ok to stderr")
 	rootCmd.AddCommand(runCmd)
 
+	// rules
+	rulesCmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Various tools to understand and verify your rules",
+	}
+	rootCmd.AddCommand(rulesCmd)
+
+	// rules explain
+	rulesExplainCmd := &cobra.Command{
+		Use:   "explain [host!service]",
+		Short: "Check a service and explain how the rules arrive at its status",
+		Args:  cobra.ExactArgs(1),
+		Run:   a.rulesExplainCmd,
+	}
+	rulesCmd.AddCommand(rulesExplainCmd)
+
+	// rules test
+	rulesTestCmd := &cobra.Command{
+		Use:   "test [file]",
+		Short: "Evaluate the values listed in a YAML file against the rules and compare the status with the status expected",
+		Args:  cobra.ExactArgs(1),
+		Run:   a.rulesTestCmd,
+	}
+	rulesCmd.AddCommand(rulesTestCmd)
+
 	// write
 	writeCmd := &cobra.Command{
 		Use:   "write",
@@ -132,6 +196,35 @@ func NewApp() *App {
 	}
 	rootCmd.AddCommand(writeCmd)
 
+	// serve
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run as daemon and insert data into InfluxDB periodically",
+		Run:   a.serveCmd,
+	}
+	serveCmd.PersistentFlags().DurationVar(&a.cfg.watchInterval, "watch", 10*time.Second, "interval to check the configuration files for changes, 0 disables the check")
+	rootCmd.AddCommand(serveCmd)
+
+	// exporter
+	exporterCmd := &cobra.Command{
+		Use:   "exporter",
+		Short: "Expose the status of all business processes as Prometheus metrics",
+		Run:   a.exporterCmd,
+	}
+	exporterCmd.PersistentFlags().DurationVar(&a.cfg.watchInterval, "watch", 10*time.Second, "interval to check the configuration files for changes, 0 disables the check")
+	rootCmd.AddCommand(exporterCmd)
+
+	// report
+	reportCmd := &cobra.Command{
+		Use:   "report [bp...]",
+		Short: "Report the availability achieved by the business processes within their availability windows",
+		Run:   a.reportCmd,
+	}
+	reportCmd.PersistentFlags().StringVar(&a.cfg.reportStart, "start", "", "beginning of the period as date (2006-01-02) in the timezone of the availability or RFC3339, defaults to the beginning of last month")
+	reportCmd.PersistentFlags().StringVar(&a.cfg.reportEnd, "end", "", "end of the period as date (2006-01-02) in the timezone of the availability or RFC3339, defaults to one month after the beginning")
+	reportCmd.PersistentFlags().StringVar(&a.cfg.reportFormat, "format", "text", "output format, one of text, yaml or json")
+	rootCmd.AddCommand(reportCmd)
+
 	// version
 	versionCmd := &cobra.Command{
 		Use:   "version",
@@ -158,15 +251,26 @@ func (a *App) configPrintCmd(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 
-	s, _, r, _, _, err := fromSection(c, a.cfg.cfgSection, a.cfg.cfgBase, a.cfg.bpPattern)
+	s, _, r, bps, _, err := fromSection(c, a.cfg.cfgSection, a.cfg.cfgBase, a.cfg.bpPattern)
 	if err != nil {
 		msg := fmt.Sprintf("Could not read section '%s' from file '%s':  %s", a.cfg.cfgSection, a.cfg.cfgFile, err.Error())
 		log.Fatal(msg)
 	}
 
+	var out []byte
+	if a.cfg.configPrintBP != "" {
+		for _, bp := range bps {
+			if bp.ID == a.cfg.configPrintBP {
+				out, _ = yaml.Marshal(bp.EffectiveRules(r))
+				fmt.Println(string(out))
+				return
+			}
+		}
+		log.Fatalf("Business process '%s' does not exist", a.cfg.configPrintBP)
+	}
+
 	s.Rules = r
 
-	var out []byte
 	out, _ = yaml.Marshal(s)
 	fmt.Println(string(out))
 }
@@ -186,37 +290,51 @@ func (a *App) configRawCmd(cmd *cobra.Command, args []string) {
 }
 
 func (a *App) dashboardCmd(cmd *cobra.Command, args []string) {
-	cfg := fmt.Sprintf("%s/%s", a.cfg.cfgBase, a.cfg.cfgFile)
-	c, _, err := config.NewFromFile(cfg, a.cfg.injectDefaults)
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	errs, err := c.Validate()
+	s, _, _, bp, err := a.load()
 	if err != nil {
-		for _, msg := range errs {
-			fmt.Println(msg)
-		}
 		log.Fatal(err)
 	}
-
-	s, _, _, bp, store, err := fromSection(c, a.cfg.cfgSection, a.cfg.cfgBase, a.cfg.bpPattern)
+	p, _, err := a.openStore(s.Store)
 	if err != nil {
-		msg := fmt.Sprintf("Could not read section '%s' from file '%s':  %s", a.cfg.cfgSection, a.cfg.cfgFile, err.Error())
-		log.Fatal(msg)
+		log.Fatal(err)
 	}
 
 	if a.cfg.dashboardStatic != "" {
 		s.Dashboard.Static = a.cfg.dashboardStatic
 	}
 
-	d, msg, err := dashboard.New(s.Dashboard, bp, store, a.cfg.dashboardPepper, a.cfg.dashboardHeader)
+	maintenancePath := fmt.Sprintf("%s/%s", a.cfg.cfgBase, s.Env.Maintenance)
+	d, msg, err := dashboard.New(s.Dashboard, bp, p, s.Daemon.Interval, maintenancePath, a.cfg.cfgBase, a.cfg.dashboardPepper, a.cfg.dashboardHeader)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	fmt.Println(msg)
 
+	go a.watch(context.Background(), func() {
+		s, _, _, bp, err := a.load()
+		if err != nil {
+			log.Printf("Reload failed, keeping the current configuration: %s", err.Error())
+			return
+		}
+		p, replaced, err := a.openStore(s.Store)
+		if err != nil {
+			log.Printf("Reload failed, keeping the current configuration: %s", err.Error())
+			return
+		}
+		_, msg, err := d.Update(bp, p)
+		if err != nil {
+			log.Printf("Reload failed, keeping the current configuration: %s", err.Error())
+			return
+		}
+		if replaced != nil {
+			if err := store.Close(replaced); err != nil {
+				log.Printf("Could not close the store replaced: %s", err.Error())
+			}
+		}
+		log.Printf("Configuration reloaded\n%s", msg)
+	})
+
 	d.Run()
 }
 
@@ -270,6 +388,8 @@ func (a *App) runCmd(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	run.Funcs(sloFuncs(b, p, s.Daemon.Interval))
+
 	runner, ok := run[runnerName]
 	if !ok {
 		msg := fmt.Sprintf("Template '%s' not found in section '%s'", runnerName, a.cfg.cfgSection)
@@ -306,9 +426,16 @@ func (a *App) runCmd(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	ctx, cancel := s.Checker.RunContext(context.Background())
+	defer cancel()
+
+	err = b.Prefetch(ctx, i)
+	if err != nil {
+		log.Printf("Could not prefetch services, fetching one by one: %s", err.Error())
+	}
+
 	var sets []store.ResultSet
-	for _, bp := range b {
-		rs := bp.Status(i, nil, r)
+	for _, rs := range b.Status(ctx, i, nil, r) {
 		if s.Store.GetLastStatus {
 			rs.AddPreviousStatus(p, s.Store.SaveOK)
 		}
@@ -347,6 +474,46 @@ func (a *App) runCmd(cmd *cobra.Command, args []string) {
 		msg := fmt.Sprintf("Error while Executing runner: %s", err.Error())
 		log.Fatal(msg)
 	}
+
+	if a.cfg.runExplain {
+		explainIssues(os.Stderr, sets, b, r)
+	}
+
+	if sr, ok := i.(checker.StatsReporter); ok && a.cfg.verbose {
+		hits, misses := sr.Stats()
+		log.Printf("Checker cache: %d hits, %d misses", hits, misses)
+	}
+}
+
+// sloFuncs returns the runner functions 'slo' and 'burnRate' which calculate
+// the error budget of a business process by its ID.
+func sloFuncs(b bpmon.BusinessProcesses, p store.Accessor, interval time.Duration) template.FuncMap {
+	budget := func(id string, burn string) (report.Budget, error) {
+		var window bpmon.Window
+		if burn != "" {
+			var err error
+			window, err = bpmon.ParseWindow(burn)
+			if err != nil {
+				return report.Budget{}, err
+			}
+		}
+		for _, bp := range b {
+			if bp.ID == id {
+				return report.GetBudget(bp, p, time.Now(), time.Duration(window), interval)
+			}
+		}
+		return report.Budget{}, fmt.Errorf("business process %s not found", id)
+	}
+
+	return template.FuncMap{
+		"slo": func(id string) (report.Budget, error) {
+			return budget(id, "")
+		},
+		"burnRate": func(id string, burn string) (float64, error) {
+			b, err := budget(id, burn)
+			return b.BurnRate, err
+		},
+	}
 }
 
 func (a *App) writeCmd(cmd *cobra.Command, args []string) {
@@ -369,11 +536,19 @@ func (a *App) writeCmd(cmd *cobra.Command, args []string) {
 		msg := fmt.Sprintf("Could not read section '%s' from file '%s':  %s", a.cfg.cfgSection, a.cfg.cfgFile, err.Error())
 		log.Fatal(msg)
 	}
-	for _, bp := range b {
-		if a.cfg.verbose {
-			log.Println("Processing " + bp.Name)
-		}
-		rs := bp.Status(i, p, r)
+
+	ctx, cancel := s.Checker.RunContext(context.Background())
+	defer cancel()
+
+	err = b.Prefetch(ctx, i)
+	if err != nil {
+		log.Printf("Could not prefetch services, fetching one by one: %s", err.Error())
+	}
+
+	if a.cfg.verbose {
+		log.Printf("Processing %d business processes", len(b))
+	}
+	for _, rs := range b.Status(ctx, i, p, r) {
 		if s.Store.GetLastStatus {
 			rs.AddPreviousStatus(p, s.Store.SaveOK)
 		}
@@ -384,6 +559,197 @@ func (a *App) writeCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+func (a *App) serveCmd(cmd *cobra.Command, args []string) {
+	s, rt, _, err := a.daemonRuntime()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d := daemon.New(s.Daemon, rt, a.cfg.verbose)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Printf("Received %s, stopping after the current run...", sig)
+		cancel()
+	}()
+
+	go a.watch(ctx, func() {
+		s, rt, replaced, err := a.daemonRuntime()
+		if err != nil {
+			log.Printf("Reload failed, keeping the current configuration: %s", err.Error())
+			return
+		}
+		d.Update(s.Daemon, rt)
+		if replaced != nil {
+			d.Release(replaced)
+		}
+		log.Println("Configuration reloaded")
+	})
+
+	log.Printf("Running every %s, press CTRL-c to stop...", s.Daemon.Interval)
+	d.Run(ctx)
+}
+
+// daemonRuntime loads the configuration and returns the runtime of the
+// daemon as well as the store replaced, if any, see 'openStore'.
+func (a *App) daemonRuntime() (s config.ConfigSection, rt daemon.Runtime, replaced store.Accessor, err error) {
+	s, i, r, b, err := a.load()
+	if err != nil {
+		return
+	}
+	p, replaced, err := a.openStore(s.Store)
+	if err != nil {
+		return
+	}
+
+	rt = daemon.Runtime{
+		BP:            b,
+		Checker:       i,
+		Rules:         r,
+		Store:         p,
+		CheckerConfig: s.Checker,
+		GetLastStatus: s.Store.GetLastStatus,
+		SaveOK:        s.Store.SaveOK,
+	}
+	return
+}
+
+func (a *App) exporterCmd(cmd *cobra.Command, args []string) {
+	s, rt, err := a.exporterRuntime()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	e := exporter.New(s.Exporter, rt, a.cfg.verbose)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		log.Printf("Received %s, stopping...", sig)
+		cancel()
+	}()
+
+	go a.watch(ctx, func() {
+		_, rt, err := a.exporterRuntime()
+		if err != nil {
+			log.Printf("Reload failed, keeping the current configuration: %s", err.Error())
+			return
+		}
+		e.Update(rt)
+		log.Println("Configuration reloaded")
+	})
+
+	err = e.Run(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (a *App) exporterRuntime() (s config.ConfigSection, rt exporter.Runtime, err error) {
+	s, i, r, b, err := a.load()
+	if err != nil {
+		return
+	}
+
+	rt = exporter.Runtime{
+		BP:            b,
+		Checker:       i,
+		Rules:         r,
+		CheckerConfig: s.Checker,
+	}
+	return
+}
+
+func (a *App) reportCmd(cmd *cobra.Command, args []string) {
+	s, _, _, b, err := a.load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	p, _, err := a.openStore(s.Store)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var slas []report.SLA
+	for _, bp := range b {
+		if len(args) > 0 && !contains(args, bp.ID) {
+			continue
+		}
+		// dates are meant as the days of the availability of the BP
+		start, end, err := report.ParsePeriod(a.cfg.reportStart, a.cfg.reportEnd, time.Now().In(bp.Availability.Zone()))
+		if err != nil {
+			log.Fatal(err)
+		}
+		sla, err := report.Get(bp, p, start, end, s.Daemon.Interval)
+		if err != nil {
+			msg := fmt.Sprintf("Could not read spans of business process %s: %s", bp.ID, err.Error())
+			log.Fatal(msg)
+		}
+		slas = append(slas, sla)
+	}
+
+	var out []byte
+	switch a.cfg.reportFormat {
+	case "yaml":
+		out, err = yaml.Marshal(slas)
+	case "json":
+		out, err = json.MarshalIndent(slas, "", "  ")
+	case "text":
+		out = reportText(slas)
+	default:
+		err = fmt.Errorf("format '%s' is not supported, use text, yaml or json", a.cfg.reportFormat)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(strings.TrimSuffix(string(out), "\n"))
+}
+
+// reportText formats the service levels as tables, one table per period as
+// the periods of business processes differ if their availabilities are
+// defined in different timezones.
+func reportText(slas []report.SLA) []byte {
+	var buf bytes.Buffer
+	var w *tabwriter.Writer
+	for i, sla := range slas {
+		if i == 0 || !sla.Start.Equal(slas[i-1].Start) || !sla.End.Equal(slas[i-1].End) {
+			if w != nil {
+				w.Flush()
+				fmt.Fprintln(&buf)
+			}
+			fmt.Fprintf(&buf, "Period from %s to %s\n\n", sla.Start.Format(time.RFC3339), sla.End.Format(time.RFC3339))
+			w = tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "BP\tAVAILABILITY\tACHIEVED\tSERVICE TIME\tMAINTENANCE\tDOWNTIME\tDOWNTIME MAINTENANCE\tDOWNTIME OUTSIDE\tUNKNOWN\tINCIDENTS")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.3f%%\t%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			sla.BP, sla.Availability, sla.Achieved,
+			seconds(sla.ServiceTime), seconds(sla.Maintenance), seconds(sla.Downtime), seconds(sla.DowntimeMaintenance), seconds(sla.DowntimeOutside), seconds(sla.Unknown),
+			sla.Incidents)
+	}
+	if w != nil {
+		w.Flush()
+	}
+	return buf.Bytes()
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *App) versionCmd(cmd *cobra.Command, args []string) {
 	fmt.Println(versionInfo())
 }
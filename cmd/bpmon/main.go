@@ -3,10 +3,12 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/unprofession-al/bpmon/internal/bpmon"
 	"github.com/unprofession-al/bpmon/internal/checker"
 	"github.com/unprofession-al/bpmon/internal/config"
+	"github.com/unprofession-al/bpmon/internal/maintenance"
 	"github.com/unprofession-al/bpmon/internal/rules"
 	"github.com/unprofession-al/bpmon/internal/store"
 )
@@ -19,6 +21,18 @@ func main() {
 }
 
 func fromSection(cnf config.Config, sectionName, cfgBase, bpPattern string) (s config.ConfigSection, c checker.Checker, r rules.Rules, b bpmon.BusinessProcesses, p store.Accessor, err error) {
+	s, c, r, b, err = readSection(cnf, sectionName, cfgBase, bpPattern)
+	if err != nil {
+		return
+	}
+
+	p, err = store.New(s.Store)
+	return
+}
+
+// readSection returns everything read from the section just as 'fromSection'
+// but does not set up the store.
+func readSection(cnf config.Config, sectionName, cfgBase, bpPattern string) (s config.ConfigSection, c checker.Checker, r rules.Rules, b bpmon.BusinessProcesses, err error) {
 	s, err = cnf.Section(sectionName)
 	if err != nil {
 		return
@@ -30,12 +44,21 @@ func fromSection(cnf config.Config, sectionName, cfgBase, bpPattern string) (s c
 	}
 
 	r = c.DefaultRules()
+	err = r.Merge(maintenance.DefaultRules())
+	if err != nil {
+		return
+	}
 	err = r.Merge(s.Rules)
 	if err != nil {
 		return
 	}
+	errs, err := r.Validate(append(c.Values(), maintenance.Value))
+	if err != nil {
+		err = fmt.Errorf("%s: %s", err.Error(), strings.Join(errs, " "))
+		return
+	}
 
-	a, err := s.Availabilities.Parse()
+	a, err := s.Availabilities.Parse(cfgBase)
 	if err != nil {
 		return
 	}
@@ -46,6 +69,22 @@ func fromSection(cnf config.Config, sectionName, cfgBase, bpPattern string) (s c
 		return
 	}
 
-	p, err = store.New(s.Store)
+	err = b.ValidateCheckers(c)
+	if err != nil {
+		return
+	}
+
+	errs, err = b.ValidateRules(append(c.Values(), maintenance.Value))
+	if err != nil {
+		err = fmt.Errorf("%s: %s", err.Error(), strings.Join(errs, " "))
+		return
+	}
+
+	maintenancePath := fmt.Sprintf("%s/%s", cfgBase, s.Env.Maintenance)
+	m, err := maintenance.Load(maintenancePath, cfgBase)
+	if err != nil {
+		return
+	}
+	err = b.Maintain(m)
 	return
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/config"
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/store"
+	"github.com/unprofession-al/bpmon/internal/watch"
+)
+
+// load reads and validates the configuration file and returns everything
+// read from the configured section except the store, see 'openStore'. In
+// contrast to the subcommands that run once, errors are returned rather than
+// logged in order to allow long running subcommands to keep their current
+// configuration.
+func (a *App) load() (s config.ConfigSection, c checker.Checker, r rules.Rules, b bpmon.BusinessProcesses, err error) {
+	cfg := fmt.Sprintf("%s/%s", a.cfg.cfgBase, a.cfg.cfgFile)
+	cnf, _, err := config.NewFromFile(cfg, a.cfg.injectDefaults)
+	if err != nil {
+		return
+	}
+
+	errs, err := cnf.Validate()
+	if err != nil {
+		err = fmt.Errorf("%s: %s", err.Error(), strings.Join(errs, ", "))
+		return
+	}
+
+	s, c, r, b, err = readSection(cnf, a.cfg.cfgSection, a.cfg.cfgBase, a.cfg.bpPattern)
+	if err != nil {
+		err = fmt.Errorf("Could not read section '%s' from file '%s':  %s", a.cfg.cfgSection, a.cfg.cfgFile, err.Error())
+		return
+	}
+
+	watched := []string{
+		cfg,
+		fmt.Sprintf("%s/%s/%s", a.cfg.cfgBase, s.Env.BP, a.cfg.bpPattern),
+		fmt.Sprintf("%s/%s/*.yaml", a.cfg.cfgBase, s.Env.Maintenance),
+	}
+	watched = append(watched, s.Availabilities.Calendars(a.cfg.cfgBase)...)
+	for _, bp := range b {
+		watched = append(watched, bp.Maintenance.Calendars(a.cfg.cfgBase)...)
+	}
+	a.state.Lock()
+	a.state.watched = watched
+	a.state.Unlock()
+	return
+}
+
+// openStore returns the store configured by 'c'. The store opened by the
+// previous call is reused as long as its configuration is unchanged, this
+// keeps eg. the results of a memory store across reloads. Otherwise a new
+// store is opened and the previous one is returned as 'replaced', the caller
+// must close it via 'store.Close' once it is no longer used.
+func (a *App) openStore(c store.Config) (p store.Accessor, replaced store.Accessor, err error) {
+	a.state.Lock()
+	defer a.state.Unlock()
+
+	if a.state.store != nil && reflect.DeepEqual(a.state.storeConfig, c) {
+		return a.state.store, nil, nil
+	}
+	p, err = store.New(c)
+	if err != nil {
+		return
+	}
+	replaced = a.state.store
+	a.state.store = p
+	a.state.storeConfig = c
+	return
+}
+
+// watched returns the glob patterns of the files watched, ie. the files read
+// by the last successful call of 'load'.
+func (a *App) watched() []string {
+	a.state.Lock()
+	defer a.state.Unlock()
+	return a.state.watched
+}
+
+// watch calls reload each time a SIGHUP is received or the configuration
+// file, a business process definition, a maintenance window or a holiday
+// calendar has changed. As the files watched are those read by the last
+// successful call of 'load', files added by a reload are watched as well.
+// It returns when the context is cancelled.
+func (a *App) watch(ctx context.Context, reload func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	changed := make(chan struct{})
+	if a.cfg.watchInterval > 0 {
+		w := watch.NewFunc(a.cfg.watchInterval, a.watched)
+		go w.Run(ctx, changed)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Println("Received SIGHUP, reloading configuration...")
+		case <-changed:
+			log.Println("Configuration files have changed, reloading configuration...")
+		}
+		reload()
+	}
+}
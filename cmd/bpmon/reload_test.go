@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWatchedAfterReload(t *testing.T) {
+	base, err := ioutil.TempDir("", "bpmon-reload")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(base)
+
+	conf := func(bp string, holidays string) string {
+		return "default:\n" +
+			"  env: { bp: " + bp + " }\n" +
+			"  checker: { connection: 'http://127.0.0.1:1/' }\n" +
+			"  availabilities:\n" +
+			"    high: { monday: [allday], holidays: [ " + holidays + " ] }\n"
+	}
+	for _, dir := range []string{"a.d", "b.d"} {
+		if err := os.Mkdir(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cal := range []string{"a.ics", "b.ics"} {
+		if err := ioutil.WriteFile(filepath.Join(base, cal), []byte("BEGIN:VCALENDAR\nEND:VCALENDAR\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := NewApp()
+	a.cfg.cfgBase = base
+	a.cfg.cfgFile = "config.yaml"
+	a.cfg.cfgSection = "default"
+	a.cfg.bpPattern = "*.yaml"
+	a.cfg.injectDefaults = true
+
+	tests := []struct {
+		conf     string
+		expected []string
+	}{
+		{
+			conf: conf("a.d", "a.ics"),
+			expected: []string{
+				base + "/config.yaml",
+				base + "/a.d/*.yaml",
+				base + "/maintenance.d//*.yaml",
+				base + "/a.ics",
+			},
+		},
+		{
+			conf: conf("b.d", "b.ics"),
+			expected: []string{
+				base + "/config.yaml",
+				base + "/b.d/*.yaml",
+				base + "/maintenance.d//*.yaml",
+				base + "/b.ics",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		if err := ioutil.WriteFile(filepath.Join(base, "config.yaml"), []byte(test.conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, _, err := a.load(); err != nil {
+			t.Fatalf("No error expected while loading configuration %d, got: %s", i, err.Error())
+		}
+		if watched := a.watched(); !reflect.DeepEqual(watched, test.expected) {
+			t.Errorf("Expected files watched after loading configuration %d to be '%v', got '%v'", i, test.expected, watched)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/maintenance"
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func (a *App) rulesExplainCmd(cmd *cobra.Command, args []string) {
+	s, c, r, b, err := a.load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	services := b.FindService(args[0], r)
+	if len(services) == 0 {
+		log.Fatalf("Service '%s' is not part of any business process", args[0])
+	}
+
+	ctx, cancel := s.Checker.RunContext(context.Background())
+	defer cancel()
+
+	results := make(map[string]checker.Result)
+	for _, sr := range services {
+		result, ok := results[sr.Service.Checker]
+		if !ok {
+			result = checker.StatusOf(ctx, c, sr.Service.Checker, sr.Service.Host, sr.Service.Service)
+			results[sr.Service.Checker] = result
+		}
+
+		fmt.Printf("%s in KPI '%s' of business process '%s'\n", args[0], sr.KPI, sr.BP)
+		if result.Message != "" {
+			fmt.Printf("message: %s\n", result.Message)
+		}
+		if result.Error != nil {
+			fmt.Printf("error: %s\n", result.Error.Error())
+		}
+		vals := sr.Values(result, time.Now())
+		fmt.Printf("values: %s\n", formatValues(vals))
+		fmt.Println(sr.Rules.Explain(vals).String())
+	}
+}
+
+// explainIssues writes the explanation of the status of each service which
+// is not ok to 'w'.
+func explainIssues(w io.Writer, sets []store.ResultSet, b bpmon.BusinessProcesses, r rules.Rules) {
+	for _, bp := range sets {
+		for _, kpi := range bp.Children {
+			for _, svc := range kpi.Children {
+				if svc.Kind() != store.KindService || svc.Status == status.StatusOK {
+					continue
+				}
+				for _, sr := range b.FindService(svc.ID, r) {
+					if sr.BP != bp.ID || sr.KPI != kpi.ID {
+						continue
+					}
+					fmt.Fprintf(w, "%s in KPI '%s' of business process '%s' is %s\n", svc.ID, kpi.ID, bp.ID, svc.Status)
+					fmt.Fprintf(w, "values: %s\n", formatValues(svc.Vals))
+					fmt.Fprintln(w, sr.Rules.Explain(svc.Vals).String())
+				}
+			}
+		}
+	}
+}
+
+// formatValues returns the values ordered by key.
+func formatValues(vals map[string]bool) string {
+	var keys []string
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out []string
+	for _, k := range keys {
+		out = append(out, fmt.Sprintf("%s=%t", k, vals[k]))
+	}
+	return strings.Join(out, " ")
+}
+
+// ruleTest is a test case of the 'rules test' subcommand.
+type ruleTest struct {
+	// Name describes the test case.
+	Name string `yaml:"name"`
+
+	// BP and Service select the rules to be tested. If 'Service' is set,
+	// the rules of each KPI the service is defined in are tested, limited
+	// to the business process 'BP' if set. If only 'BP' is set, the rules
+	// of the business process are tested. Otherwise the rules of the
+	// configuration section are tested.
+	BP      string `yaml:"bp"`
+	Service string `yaml:"service"`
+
+	// Values are the values of the check result. Values of the checker
+	// which are not listed are false.
+	Values map[string]bool `yaml:"values"`
+
+	// Expect is the status expected.
+	Expect *status.Status `yaml:"expect"`
+}
+
+func (a *App) rulesTestCmd(cmd *cobra.Command, args []string) {
+	_, c, r, b, err := a.load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		log.Fatalf("Could not read test cases from '%s': %s", args[0], err.Error())
+	}
+	var tests []ruleTest
+	err = yaml.Unmarshal(data, &tests)
+	if err != nil {
+		log.Fatalf("Could not parse test cases in '%s': %s", args[0], err.Error())
+	}
+
+	failed := 0
+	for _, test := range tests {
+		vals := make(map[string]bool)
+		for _, v := range append(c.Values(), maintenance.Value) {
+			vals[v] = false
+		}
+		var unknown []string
+		for k, v := range test.Values {
+			if _, ok := vals[k]; !ok {
+				unknown = append(unknown, k)
+			}
+			vals[k] = v
+		}
+
+		msgs, err := runRuleTest(test, vals, b, r)
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+		sort.Strings(unknown)
+		for _, k := range unknown {
+			msgs = append(msgs, fmt.Sprintf("value '%s' is not provided by the checker", k))
+		}
+		if len(msgs) > 0 {
+			failed++
+			fmt.Printf("FAIL %s\n", test.Name)
+			for _, msg := range msgs {
+				fmt.Printf("     %s\n", msg)
+			}
+			continue
+		}
+		fmt.Printf("ok   %s\n", test.Name)
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d tests failed\n", failed, len(tests))
+		os.Exit(1)
+	}
+}
+
+// runRuleTest evaluates the values against the rules selected by the test
+// case and returns a message for each status which is not as expected.
+func runRuleTest(test ruleTest, vals map[string]bool, b bpmon.BusinessProcesses, r rules.Rules) ([]string, error) {
+	if test.Expect == nil {
+		return nil, fmt.Errorf("field 'expect' is missing")
+	}
+
+	scopes := map[string]rules.Rules{}
+	switch {
+	case test.Service != "":
+		for _, sr := range b.FindService(test.Service, r) {
+			if test.BP == "" || sr.BP == test.BP {
+				scopes[fmt.Sprintf("KPI '%s' of business process '%s'", sr.KPI, sr.BP)] = sr.Rules
+			}
+		}
+		if len(scopes) == 0 {
+			return nil, fmt.Errorf("service '%s' is not part of any business process selected", test.Service)
+		}
+	case test.BP != "":
+		for _, bp := range b {
+			if bp.ID == test.BP {
+				scopes[fmt.Sprintf("business process '%s'", bp.ID)] = r.Override(bp.Rules)
+			}
+		}
+		if len(scopes) == 0 {
+			return nil, fmt.Errorf("business process '%s' does not exist", test.BP)
+		}
+	default:
+		scopes["configuration section"] = r
+	}
+
+	var msgs []string
+	for scope, sr := range scopes {
+		st, match, err := sr.Analyze(vals)
+		switch {
+		case err != nil:
+			msgs = append(msgs, fmt.Sprintf("%s: expected '%s', got '%s' (%s)", scope, *test.Expect, st, err.Error()))
+		case st != *test.Expect:
+			msgs = append(msgs, fmt.Sprintf("%s: expected '%s', got '%s' by %s", scope, *test.Expect, st, match.String()))
+		}
+	}
+	sort.Strings(msgs)
+	return msgs, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+var sectionRules = rules.Rules{
+	10: rules.Rule{Must: []string{"critical"}, MustNot: []string{}, Then: status.StatusNOK},
+	99: rules.Rule{Must: []string{}, MustNot: []string{}, Then: status.StatusOK},
+}
+
+var testBPs = bpmon.BusinessProcesses{
+	{
+		ID:    "app",
+		Rules: rules.Rules{20: rules.Rule{Must: []string{"warn"}, MustNot: []string{}, Then: status.StatusDegraded}},
+		Kpis: []bpmon.KPI{
+			{ID: "web", Services: []bpmon.Service{{Host: "web1", Service: "http"}}},
+			{
+				ID:       "db",
+				Rules:    rules.Rules{15: rules.Rule{Must: []string{"warn"}, MustNot: []string{}, Then: status.StatusNOK}},
+				Services: []bpmon.Service{{Host: "db1", Service: "ping"}},
+			},
+		},
+	},
+	{
+		ID:   "reporting",
+		Kpis: []bpmon.KPI{{ID: "db", Services: []bpmon.Service{{Host: "db1", Service: "ping"}}}},
+	},
+}
+
+func expect(st status.Status) *status.Status {
+	return &st
+}
+
+func TestRunRuleTest(t *testing.T) {
+	warn := map[string]bool{"critical": false, "warn": true}
+
+	tests := map[string]struct {
+		test ruleTest
+		vals map[string]bool
+		msgs int
+		err  bool
+	}{
+		"configuration section": {
+			test: ruleTest{Expect: expect(status.StatusNOK)},
+			vals: map[string]bool{"critical": true, "warn": false},
+		},
+		"business process": {
+			test: ruleTest{BP: "app", Expect: expect(status.StatusDegraded)},
+			vals: warn,
+		},
+		"service of one business process": {
+			test: ruleTest{BP: "app", Service: "db1!ping", Expect: expect(status.StatusDegraded)},
+			vals: warn,
+			msgs: 1,
+		},
+		"service of all business processes": {
+			test: ruleTest{Service: "db1!ping", Expect: expect(status.StatusDegraded)},
+			vals: warn,
+			msgs: 2,
+		},
+		"value missing": {
+			test: ruleTest{Expect: expect(status.StatusOK)},
+			vals: map[string]bool{"warn": false},
+			msgs: 1,
+		},
+		"expect missing": {
+			test: ruleTest{},
+			vals: warn,
+			err:  true,
+		},
+		"unknown service": {
+			test: ruleTest{Service: "db2!ping", Expect: expect(status.StatusOK)},
+			vals: warn,
+			err:  true,
+		},
+		"unknown business process": {
+			test: ruleTest{BP: "shop", Expect: expect(status.StatusOK)},
+			vals: warn,
+			err:  true,
+		},
+	}
+
+	for name, test := range tests {
+		msgs, err := runRuleTest(test.test, test.vals, testBPs, sectionRules)
+		if (err != nil) != test.err {
+			t.Errorf("Expected error for '%s' to be %t, got '%v'", name, test.err, err)
+		}
+		if len(msgs) != test.msgs {
+			t.Errorf("Expected %d messages for '%s', got %d: %v", test.msgs, name, len(msgs), msgs)
+		}
+	}
+}
+
+func TestExplainIssues(t *testing.T) {
+	svc := func(id string, st status.Status, vals map[string]bool) *store.ResultSet {
+		return &store.ResultSet{ID: id, Status: st, Vals: vals, Tags: map[store.Kind]string{store.KindService: id}}
+	}
+	sets := []store.ResultSet{
+		{
+			ID: "app",
+			Children: []*store.ResultSet{
+				{ID: "web", Children: []*store.ResultSet{svc("web1!http", status.StatusOK, map[string]bool{"critical": false, "warn": false})}},
+				{ID: "db", Children: []*store.ResultSet{svc("db1!ping", status.StatusNOK, map[string]bool{"critical": false, "warn": true})}},
+			},
+		},
+		{
+			ID: "reporting",
+			Children: []*store.ResultSet{
+				{ID: "db", Children: []*store.ResultSet{svc("db1!ping", status.StatusOK, map[string]bool{"critical": false, "warn": true})}},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	explainIssues(&buf, sets, testBPs, sectionRules)
+	out := buf.String()
+
+	expected := []string{
+		"db1!ping in KPI 'db' of business process 'app' is not ok\n",
+		"values: critical=false warn=true\n",
+		"rule 15: warn then not ok: matched\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("Expected explanation to contain '%s', got:\n%s", strings.TrimSpace(e), out)
+		}
+	}
+	if strings.Contains(out, "web1!http") || strings.Contains(out, "reporting") {
+		t.Errorf("Expected only services which are not ok to be explained, got:\n%s", out)
+	}
+}
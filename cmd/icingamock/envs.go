@@ -71,6 +71,26 @@ func (e Environments) SingleToIcinga(envN, hostN, serviceN string, t icinga.Time
 	return response, nil
 }
 
+func (e Environments) FilteredToIcinga(envN string, names []string, t icinga.Timestamp) (icinga.Response, error) {
+	response := icinga.Response{}
+
+	all, err := e.ToIcinga(envN, t)
+	if err != nil {
+		return response, err
+	}
+
+	requested := make(map[string]bool)
+	for _, name := range names {
+		requested[name] = true
+	}
+	for _, result := range all.Results {
+		if requested[result.Name] {
+			response.Results = append(response.Results, result)
+		}
+	}
+	return response, nil
+}
+
 func (e Environments) Get(name string) (*Hosts, error) {
 	for n, env := range e {
 		if n == name {
@@ -53,6 +53,55 @@ func MockIcingaServicesHandler(res http.ResponseWriter, req *http.Request) {
 	Respond(res, req, http.StatusOK, data)
 }
 
+// MockIcingaFilteredServicesHandler serves requests sent as POST with the
+// 'X-HTTP-Method-Override: GET' header where the filter is passed in the
+// request body. Only the filter used by BPMON to request services by their
+// full names is supported.
+func MockIcingaFilteredServicesHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	env, ok := vars["env"]
+	if !ok {
+		Respond(res, req, http.StatusNotFound, "No environment passed")
+		return
+	}
+
+	var q icinga.Query
+	err := json.NewDecoder(req.Body).Decode(&q)
+	if err != nil {
+		Respond(res, req, http.StatusBadRequest, fmt.Sprintf("Could not parse request body: %s", err.Error()))
+		return
+	}
+
+	if q.Filter != icinga.FilterServiceNames {
+		Respond(res, req, http.StatusBadRequest, fmt.Sprintf("Filter `%s` is not supported, use `%s`", q.Filter, icinga.FilterServiceNames))
+		return
+	}
+
+	rawNames, ok := q.FilterVars["names"].([]interface{})
+	if !ok {
+		Respond(res, req, http.StatusBadRequest, "Filter variable `names` must be a list of strings")
+		return
+	}
+	var names []string
+	for _, rawName := range rawNames {
+		name, ok := rawName.(string)
+		if !ok {
+			Respond(res, req, http.StatusBadRequest, "Filter variable `names` must be a list of strings")
+			return
+		}
+		names = append(names, name)
+	}
+
+	t := icinga.Timestamp(time.Now())
+
+	data, err := envs.FilteredToIcinga(env, names, t)
+	if err != nil {
+		Respond(res, req, http.StatusNotFound, "Environment not found ")
+		return
+	}
+	Respond(res, req, http.StatusOK, data)
+}
+
 func MockIcingaAcknowledgeHandler(res http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 
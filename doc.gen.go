@@ -222,7 +222,7 @@ func (f field) String() string {
 	return out
 }
 
-var yamlTagRE = regexp.MustCompile(`yaml:"(?P<tag>\w+)"`)
+var yamlTagRE = regexp.MustCompile(`yaml:"(?P<tag>\w+)[,"]`)
 
 func getYAMLTag(f *ast.Field) string {
 	out := ""
@@ -9,8 +9,10 @@ require (
 	github.com/influxdata/influxdb v1.6.3
 	github.com/justinas/alice v0.0.0-20171023064455-03f45bd4b7da
 	github.com/kylelemons/godebug v0.0.0-20170820004349-d65d576e9348
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-colorable v0.0.9 // indirect
 	github.com/mattn/go-isatty v0.0.4 // indirect
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/mgutz/ansi v0.0.0-20170206155736-9520e82c474b
 	github.com/spf13/cobra v0.0.3
 	github.com/spf13/pflag v1.0.3 // indirect
@@ -18,4 +20,4 @@ require (
 	gopkg.in/yaml.v2 v2.2.1
 )
 
-go 1.13
+go 1.16
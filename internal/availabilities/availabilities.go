@@ -1,8 +1,10 @@
 package availabilities
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -11,101 +13,404 @@ const timeformat = "15:04:05"
 
 type AvailabilitiesConfig map[string]AvailabilityConfig
 
-func (ac AvailabilitiesConfig) Parse() (Availabilities, error) {
+// Parse parses all availabilities. Holiday calendars are read relative to
+// 'base' unless their path is absolute.
+func (ac AvailabilitiesConfig) Parse(base string) (Availabilities, error) {
 	a := make(Availabilities)
 	for name, daysConf := range ac {
-		availability, err := daysConf.Parse()
+		availability, err := daysConf.Parse(base)
 		if err != nil {
-			return a, err
+			return a, fmt.Errorf("availability '%s' is invalid: %s", name, err.Error())
 		}
 		a[name] = availability
 	}
 	return a, nil
 }
 
+// Calendars returns the paths of the holiday calendars of all availabilities
+// as read by 'Parse'.
+func (ac AvailabilitiesConfig) Calendars(base string) []string {
+	var paths []string
+	for _, availability := range ac {
+		paths = append(paths, availability.Calendars(base)...)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Validate checks all availabilities for errors. In contrast to 'Parse' the
+// holiday calendars are not read.
+func (ac AvailabilitiesConfig) Validate() ([]string, error) {
+	errs := []string{}
+	var names []string
+	for name := range ac {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := ac[name].Validate()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("Availability '%s' is invalid: %s.", name, err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		err := errors.New("Config of 'availabilities' has errors")
+		return errs, err
+	}
+	return errs, nil
+}
+
 type Availabilities map[string]Availability
 
-type AvailabilityConfig map[string][]string
+// AvailabilityConfig holds the time ranges per weekday as well as the
+// timezone the time ranges are defined in.
+type AvailabilityConfig struct {
+	// timezone is the name of the timezone as in the IANA Time Zone database
+	// (eg. Europe/Zurich). If empty, the local timezone of BPMON is used.
+	Timezone string `yaml:"timezone,omitempty"`
+
+	// exclude lists dates (eg. 2017-12-25) and date ranges (eg.
+	// 2017-12-24/2017-12-26) on which the availability does not apply.
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	// holidays lists iCalendar files (.ics) relative to the base directory.
+	// The days of all events are excluded just as with exclude.
+	Holidays []string `yaml:"holidays,omitempty"`
+
+	// extra lists additional time ranges on specific dates (eg. 2017-03-25
+	// 10:00:00-14:00:00 or 2017-03-25 allday). They apply even if the date is
+	// excluded.
+	Extra []string `yaml:"extra,omitempty"`
+
+	// Days maps the name of the weekday to its time ranges.
+	Days map[string][]string `yaml:",inline"`
+}
+
+// Parse parses the availability. Holiday calendars are read relative to
+// 'base' unless their path is absolute.
+func (ac AvailabilityConfig) Parse(base string) (Availability, error) {
+	return ac.parse(func(path string, loc *time.Location) ([]DateRange, error) {
+		return readCalendar(base, path, loc)
+	})
+}
+
+// Calendars returns the paths of the holiday calendars as read by 'Parse'.
+func (ac AvailabilityConfig) Calendars(base string) []string {
+	var paths []string
+	for _, path := range ac.Holidays {
+		if path != "" {
+			paths = append(paths, calendarPath(base, path))
+		}
+	}
+	return paths
+}
+
+// Validate checks the availability for errors. In contrast to 'Parse' the
+// holiday calendars are not read.
+func (ac AvailabilityConfig) Validate() error {
+	_, err := ac.parse(nil)
+	return err
+}
+
+// MarshalJSON implements the Marshaler interface of package json. The days
+// are inlined just as in YAML.
+func (ac AvailabilityConfig) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{})
+	for day, ranges := range ac.Days {
+		out[day] = ranges
+	}
+	if ac.Timezone != "" {
+		out["timezone"] = ac.Timezone
+	}
+	if len(ac.Exclude) > 0 {
+		out["exclude"] = ac.Exclude
+	}
+	if len(ac.Holidays) > 0 {
+		out["holidays"] = ac.Holidays
+	}
+	if len(ac.Extra) > 0 {
+		out["extra"] = ac.Extra
+	}
+	return json.Marshal(out)
+}
+
+// parse parses the availability and reads the holiday calendars using
+// 'calendar' in the location of the availability. If 'calendar' is nil,
+// holiday calendars are ignored.
+func (ac AvailabilityConfig) parse(calendar func(path string, loc *time.Location) ([]DateRange, error)) (Availability, error) {
+	a := Availability{
+		Location: time.Local,
+		Days:     make(map[time.Weekday]AvailabilityTime),
+	}
+	if ac.Timezone != "" {
+		loc, err := time.LoadLocation(ac.Timezone)
+		if err != nil {
+			return a, fmt.Errorf("'%s' does not look like the name of a timezone: %s", ac.Timezone, err.Error())
+		}
+		a.Location = loc
+	}
 
-func (ac AvailabilityConfig) Parse() (Availability, error) {
-	a := make(Availability)
-	for day, tsString := range ac {
-		wd, err := toWeekday(day)
+	var days []string
+	for day := range ac.Days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days {
+		wds, err := toWeekdays(day)
 		if err != nil {
 			return a, err
 		}
 
-		at, err := toAvailabilityTime(tsString)
+		at, err := toAvailabilityTime(ac.Days[day])
 		if err != nil {
-			return a, err
+			return a, fmt.Errorf("%s: %s", day, err.Error())
+		}
+
+		for _, wd := range wds {
+			if _, ok := a.Days[wd]; ok {
+				return a, fmt.Errorf("%s: %s is defined more than once", day, wd)
+			}
+			a.Days[wd] = at
+		}
+	}
+
+	for _, str := range ac.Exclude {
+		dr, err := toDateRange(str)
+		if err != nil {
+			return a, fmt.Errorf("exclude: %s", err.Error())
+		}
+		a.Excluded = append(a.Excluded, dr)
+	}
+
+	for _, path := range ac.Holidays {
+		if path == "" {
+			return a, errors.New("holidays: path of holiday calendar cannot be empty")
+		}
+		if calendar == nil {
+			continue
+		}
+		drs, err := calendar(path, a.Location)
+		if err != nil {
+			return a, fmt.Errorf("holidays: %s", err.Error())
 		}
+		a.Excluded = append(a.Excluded, drs...)
+	}
 
-		a[wd] = at
+	for _, str := range ac.Extra {
+		tr, err := toExtraTimeRange(str, a.Location)
+		if err != nil {
+			return a, fmt.Errorf("extra: %s", err.Error())
+		}
+		a.Extra = append(a.Extra, tr)
 	}
 	return a, nil
 }
 
+// toWeekday parses the name of a weekday. Names can be abbreviated to their
+// first three letters, eg. 'mon'.
 func toWeekday(str string) (time.Weekday, error) {
-	switch strings.Title(strings.ToLower(str)) {
-	case time.Sunday.String():
-		return time.Sunday, nil
-	case time.Monday.String():
-		return time.Monday, nil
-	case time.Tuesday.String():
-		return time.Tuesday, nil
-	case time.Wednesday.String():
-		return time.Wednesday, nil
-	case time.Thursday.String():
-		return time.Thursday, nil
-	case time.Friday.String():
-		return time.Friday, nil
-	case time.Saturday.String():
-		return time.Saturday, nil
-	default:
-		return time.Monday, fmt.Errorf("'%s' does not look like the name of a weekday", str)
-	}
-}
-
-type Availability map[time.Weekday]AvailabilityTime
+	str = strings.ToLower(strings.TrimSpace(str))
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if str == name || str == name[:3] {
+			return wd, nil
+		}
+	}
+	return time.Monday, fmt.Errorf("'%s' does not look like the name of a weekday", str)
+}
 
+// toWeekdays parses the name of a weekday or a range of weekdays such as
+// 'mon-fri'. Ranges wrap around the end of the week, eg. 'fri-mon'.
+func toWeekdays(str string) ([]time.Weekday, error) {
+	dStrings := strings.Split(str, "-")
+	if len(dStrings) > 2 {
+		return nil, fmt.Errorf("'%s' does not look like a range of weekdays, ranges must be formated as in 'mon-fri'", str)
+	}
+
+	first, err := toWeekday(dStrings[0])
+	if err != nil {
+		return nil, err
+	}
+	last := first
+	if len(dStrings) == 2 {
+		last, err = toWeekday(dStrings[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := []time.Weekday{first}
+	for wd := first; wd != last; {
+		wd = (wd + 1) % 7
+		out = append(out, wd)
+	}
+	return out, nil
+}
+
+// Availability holds the time ranges per weekday in which a business process
+// must be available. The time ranges are evaluated in 'Location', or in the
+// local timezone if 'Location' is nil.
+type Availability struct {
+	Location *time.Location
+	Days     map[time.Weekday]AvailabilityTime
+
+	// Excluded holds the dates on which the time ranges of 'Days' do not
+	// apply, eg. public holidays.
+	Excluded []DateRange
+
+	// Extra holds additional time ranges which apply regardless of 'Days'
+	// and 'Excluded'.
+	Extra []TimeRange
+}
+
+// Zone returns the location the time ranges are evaluated in.
+func (a Availability) Zone() *time.Location {
+	if a.Location == nil {
+		return time.Local
+	}
+	return a.Location
+}
+
+// Contains checks whether the availability applies at 't'. The weekday and
+// the time of day of 't' are determined in the location of the availability.
+// The start of a time range is part of the range, its end is not.
 func (a Availability) Contains(t time.Time) bool {
-	wd := t.Weekday()
-	at, ok := a[wd]
+	t = t.In(a.Zone())
+	for _, tRange := range a.Extra {
+		if tRange.contains(t) {
+			return true
+		}
+	}
 
-	if !ok {
-		return false
+	// time ranges of the previous day may last until the day of 't'
+	today := midnight(t)
+	for _, day := range []time.Time{previousDay(today), today} {
+		for _, tRange := range a.day(day) {
+			if tRange.contains(t) {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	if at.AllDay {
-		return true
+// Windows returns the time ranges between 'start' and 'end' in which the
+// availability applies, ordered by time. In contrast to the time ranges of
+// 'AvailabilityTime' the ranges returned hold absolute points in time.
+// Consecutive ranges are merged, days are determined in the location of
+// the availability.
+func (a Availability) Windows(start time.Time, end time.Time) []TimeRange {
+	loc := a.Zone()
+	start = start.In(loc)
+	end = end.In(loc)
+
+	ranges := append([]TimeRange{}, a.Extra...)
+	// time ranges of the previous day may last until the day of 'start'
+	for day := previousDay(midnight(start)); day.Before(end); day = nextDay(day) {
+		ranges = append(ranges, a.day(day)...)
 	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start.Before(ranges[j].Start) })
 
-	for _, tRange := range a[wd].TimeRanges {
-		year, month, day := t.Date()
-		startH, startM, startS := tRange.Start.Clock()
-		endH, endM, endS := tRange.End.Clock()
-		loc := t.Location()
+	out := []TimeRange{}
+	for _, r := range ranges {
+		if r.Start.Before(start) {
+			r.Start = start
+		}
+		if r.End.After(end) {
+			r.End = end
+		}
+		if !r.Start.Before(r.End) {
+			continue
+		}
+		if last := len(out) - 1; last >= 0 && !r.Start.After(out[last].End) {
+			if r.End.After(out[last].End) {
+				out[last].End = r.End
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// day returns the time ranges starting on the day beginning at 'midnight'
+// as absolute points in time. Time ranges may end on the following day.
+func (a Availability) day(midnight time.Time) []TimeRange {
+	at, ok := a.Days[midnight.Weekday()]
+	if !ok || a.excluded(midnight) {
+		return nil
+	}
 
-		start := time.Date(year, month, day, startH, startM, startS, 0, loc)
-		end := time.Date(year, month, day, endH, endM, endS, 0, loc)
+	if at.AllDay {
+		return []TimeRange{{Start: midnight, End: nextDay(midnight)}}
+	}
+	var out []TimeRange
+	for _, tRange := range at.TimeRanges {
+		out = append(out, TimeRange{
+			Start: onDay(midnight, tRange.Start),
+			End:   onDay(midnight, tRange.End),
+		})
+	}
+	return out
+}
 
-		if t.After(start) && t.Before(end) {
+// excluded checks whether the date of 't' is excluded.
+func (a Availability) excluded(t time.Time) bool {
+	for _, dr := range a.Excluded {
+		if dr.Contains(t) {
 			return true
 		}
 	}
 	return false
 }
 
+// midnight returns midnight of the day of 't'.
+func midnight(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// nextDay returns midnight of the day following 't'.
+func nextDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+}
+
+// previousDay returns midnight of the day before 't'.
+func previousDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day-1, 0, 0, 0, 0, t.Location())
+}
+
+// onDay returns the point in time of the time of day 'clock' on the day
+// starting at 'midnight'. If 'clock' is on the following day (see
+// 'TimeRange'), the point in time is on the following day as well.
+func onDay(midnight time.Time, clock time.Time) time.Time {
+	year, month, day := midnight.Date()
+	h, m, s := clock.Clock()
+	return time.Date(year, month, day+clock.YearDay()-1, h, m, s, 0, midnight.Location())
+}
+
 type AvailabilityTime struct {
 	TimeRanges []TimeRange
 	AllDay     bool
 }
 
+// TimeRange holds the start and the end of a time range. Within an
+// 'AvailabilityTime' only the time of day is relevant: An end on the day
+// following the start (January 2nd, year 0) denotes a time range lasting
+// until 24:00:00 or over midnight.
 type TimeRange struct {
 	Start time.Time
 	End   time.Time
 }
 
+// contains checks whether 't' is within the time range. The start is part
+// of the time range, the end is not.
+func (tr TimeRange) contains(t time.Time) bool {
+	return !t.Before(tr.Start) && t.Before(tr.End)
+}
+
 func toAvailabilityTime(trStrings []string) (AvailabilityTime, error) {
 	out := AvailabilityTime{AllDay: false}
 	if len(trStrings) < 1 || trStrings[0] == "" {
@@ -125,10 +430,17 @@ func toAvailabilityTime(trStrings []string) (AvailabilityTime, error) {
 		if err != nil {
 			return out, err
 		}
-		end, err := toTime(tStrings[1])
+		end, err := toEndTime(tStrings[1])
 		if err != nil {
 			return out, err
 		}
+		if end.Equal(start) {
+			return out, fmt.Errorf("'%s' is an empty time range, start and end must differ", trString)
+		}
+		// a time range ending before it starts lasts over midnight
+		if end.Before(start) {
+			end = end.AddDate(0, 0, 1)
+		}
 		timeranges = append(timeranges, TimeRange{
 			Start: start,
 			End:   end,
@@ -138,6 +450,16 @@ func toAvailabilityTime(trStrings []string) (AvailabilityTime, error) {
 	return out, nil
 }
 
+// toEndTime parses the end of a time range. In addition to the times
+// understood by 'toTime', '24:00:00' denotes the end of the day.
+func toEndTime(tString string) (time.Time, error) {
+	if strings.TrimSpace(tString) == "24:00:00" {
+		t, err := time.Parse(timeformat, "00:00:00")
+		return t.AddDate(0, 0, 1), err
+	}
+	return toTime(tString)
+}
+
 func toTime(tString string) (time.Time, error) {
 	tString = strings.TrimSpace(tString)
 	t, err := time.Parse(timeformat, tString)
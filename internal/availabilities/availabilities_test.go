@@ -20,7 +20,10 @@ func TestStringToWeekday(t *testing.T) {
 		{str: "FRIDAY", day: time.Friday, errExpected: false},
 		{str: "Saturday", day: time.Saturday, errExpected: false},
 		{str: "Sunday", day: time.Sunday, errExpected: false},
+		{str: "mon", day: time.Monday, errExpected: false},
+		{str: "Sat", day: time.Saturday, errExpected: false},
 		{str: "Casual-Friday", errExpected: true},
+		{str: "mo", errExpected: true},
 	}
 
 	for _, test := range tests {
@@ -37,6 +40,33 @@ func TestStringToWeekday(t *testing.T) {
 	}
 }
 
+func TestStringToWeekdays(t *testing.T) {
+	tests := []struct {
+		str         string
+		days        []time.Weekday
+		errExpected bool
+	}{
+		{str: "monday", days: []time.Weekday{time.Monday}},
+		{str: "mon-fri", days: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}},
+		{str: "Saturday-Sunday", days: []time.Weekday{time.Saturday, time.Sunday}},
+		{str: "fri-mon", days: []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday}},
+		{str: "mon-mon", days: []time.Weekday{time.Monday}},
+		{str: "mon-someday", errExpected: true},
+		{str: "mon-wed-fri", errExpected: true},
+	}
+
+	for _, test := range tests {
+		days, err := toWeekdays(test.str)
+		if err == nil && test.errExpected {
+			t.Errorf("Error expected for '%s' but test succeeded", test.str)
+		} else if err != nil && !test.errExpected {
+			t.Errorf("No error expected for '%s' but test failed: %s", test.str, err.Error())
+		} else if err == nil && !reflect.DeepEqual(days, test.days) {
+			t.Errorf("Result not as expected for '%s': Should be '%v', is '%v'", test.str, test.days, days)
+		}
+	}
+}
+
 func ParseTime(str string) time.Time {
 	format := "15:04:05.000"
 	t, err := time.Parse(format, str)
@@ -78,6 +108,34 @@ func TestStringsToAvailabilityTime(t *testing.T) {
 			str:         []string{"13:00:00-bar"},
 			errExpected: true,
 		},
+		{
+			str: []string{"22:00:00-06:00:00"},
+			at: AvailabilityTime{
+				TimeRanges: []TimeRange{
+					{Start: ParseTime("22:00:00.000"), End: ParseTime("06:00:00.000").AddDate(0, 0, 1)},
+				},
+				AllDay: false,
+			},
+			errExpected: false,
+		},
+		{
+			str: []string{"18:00:00-24:00:00"},
+			at: AvailabilityTime{
+				TimeRanges: []TimeRange{
+					{Start: ParseTime("18:00:00.000"), End: ParseTime("00:00:00.000").AddDate(0, 0, 1)},
+				},
+				AllDay: false,
+			},
+			errExpected: false,
+		},
+		{
+			str:         []string{"08:00:00-08:00:00"},
+			errExpected: true,
+		},
+		{
+			str:         []string{"24:00:00-08:00:00"},
+			errExpected: true,
+		},
 		//{
 		//	str: []string{"ALLDAY", "09:00:00-12:00:00"},
 		//	at: AvailabilityTime{
@@ -113,14 +171,17 @@ func ParseDate(str string) time.Time {
 
 func TestContains(t *testing.T) {
 	a := Availability{
-		time.Monday: AvailabilityTime{
-			TimeRanges: []TimeRange{
-				{Start: ParseTime("09:00:00.000"), End: ParseTime("12:00:00.000")},
+		Location: time.UTC,
+		Days: map[time.Weekday]AvailabilityTime{
+			time.Monday: AvailabilityTime{
+				TimeRanges: []TimeRange{
+					{Start: ParseTime("09:00:00.000"), End: ParseTime("12:00:00.000")},
+				},
+				AllDay: false,
+			},
+			time.Friday: AvailabilityTime{
+				AllDay: true,
 			},
-			AllDay: false,
-		},
-		time.Friday: AvailabilityTime{
-			AllDay: true,
 		},
 	}
 
@@ -132,10 +193,22 @@ func TestContains(t *testing.T) {
 			timestamp:       ParseDate("Mon 2017/03/20 08:00:00.000"),
 			inAvalilability: false,
 		},
+		{
+			timestamp:       ParseDate("Mon 2017/03/20 09:00:00.000"),
+			inAvalilability: true,
+		},
 		{
 			timestamp:       ParseDate("Mon 2017/03/20 09:00:00.001"),
 			inAvalilability: true,
 		},
+		{
+			timestamp:       ParseDate("Mon 2017/03/20 11:59:59.999"),
+			inAvalilability: true,
+		},
+		{
+			timestamp:       ParseDate("Mon 2017/03/20 12:00:00.000"),
+			inAvalilability: false,
+		},
 		{
 			timestamp:       ParseDate("Fri 2017/03/17 09:00:00.001"),
 			inAvalilability: true,
@@ -158,3 +231,290 @@ func TestContains(t *testing.T) {
 	}
 
 }
+
+func TestWindows(t *testing.T) {
+	a := Availability{
+		Location: time.UTC,
+		Days: map[time.Weekday]AvailabilityTime{
+			time.Monday: AvailabilityTime{
+				TimeRanges: []TimeRange{
+					{Start: ParseTime("13:00:00.000"), End: ParseTime("17:00:00.000")},
+					{Start: ParseTime("08:00:00.000"), End: ParseTime("12:00:00.000")},
+				},
+				AllDay: false,
+			},
+			time.Thursday: AvailabilityTime{
+				TimeRanges: []TimeRange{
+					{Start: ParseTime("20:00:00.000"), End: ParseTime("23:59:59.000")},
+				},
+				AllDay: false,
+			},
+			time.Friday: AvailabilityTime{
+				AllDay: true,
+			},
+			time.Saturday: AvailabilityTime{
+				AllDay: true,
+			},
+		},
+	}
+
+	tests := []struct {
+		start   time.Time
+		end     time.Time
+		windows []TimeRange
+	}{
+		{
+			start: ParseDate("Mon 2017/03/13 10:00:00.000"),
+			end:   ParseDate("Mon 2017/03/20 09:00:00.000"),
+			windows: []TimeRange{
+				{Start: ParseDate("Mon 2017/03/13 10:00:00.000"), End: ParseDate("Mon 2017/03/13 12:00:00.000")},
+				{Start: ParseDate("Mon 2017/03/13 13:00:00.000"), End: ParseDate("Mon 2017/03/13 17:00:00.000")},
+				{Start: ParseDate("Thu 2017/03/16 20:00:00.000"), End: ParseDate("Thu 2017/03/16 23:59:59.000")},
+				{Start: ParseDate("Fri 2017/03/17 00:00:00.000"), End: ParseDate("Sun 2017/03/19 00:00:00.000")},
+				{Start: ParseDate("Mon 2017/03/20 08:00:00.000"), End: ParseDate("Mon 2017/03/20 09:00:00.000")},
+			},
+		},
+		{
+			start: ParseDate("Sat 2017/03/18 12:00:00.000"),
+			end:   ParseDate("Sat 2017/03/18 13:00:00.000"),
+			windows: []TimeRange{
+				{Start: ParseDate("Sat 2017/03/18 12:00:00.000"), End: ParseDate("Sat 2017/03/18 13:00:00.000")},
+			},
+		},
+		{
+			start:   ParseDate("Sun 2017/03/19 00:00:00.000"),
+			end:     ParseDate("Mon 2017/03/20 08:00:00.000"),
+			windows: []TimeRange{},
+		},
+	}
+
+	for _, test := range tests {
+		windows := a.Windows(test.start, test.end)
+		if !reflect.DeepEqual(windows, test.windows) {
+			t.Errorf("Windows from %v to %v do not match: '%v' vs. '%v'", test.start, test.end, windows, test.windows)
+		}
+	}
+}
+
+func loadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(fmt.Sprintf("Timezone '%s' required by test cannot be loaded: %s", name, err.Error()))
+	}
+	return loc
+}
+
+func TestParseTimezone(t *testing.T) {
+	tests := []struct {
+		conf        AvailabilityConfig
+		location    *time.Location
+		errExpected bool
+	}{
+		{
+			conf:     AvailabilityConfig{Days: map[string][]string{"monday": {"allday"}}},
+			location: time.Local,
+		},
+		{
+			conf:     AvailabilityConfig{Timezone: "Asia/Singapore", Days: map[string][]string{"monday": {"allday"}}},
+			location: loadLocation("Asia/Singapore"),
+		},
+		{
+			conf:        AvailabilityConfig{Timezone: "Europe/Bern", Days: map[string][]string{"monday": {"allday"}}},
+			errExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		a, err := test.conf.Parse("")
+		if err == nil && test.errExpected {
+			t.Errorf("Error expected for '%s' but test succeeded", test.conf.Timezone)
+		} else if err != nil && !test.errExpected {
+			t.Errorf("No error expected for '%s' but test failed: %s", test.conf.Timezone, err.Error())
+		} else if err == nil && a.Location.String() != test.location.String() {
+			t.Errorf("Location of '%s' should be '%s', is '%s'", test.conf.Timezone, test.location, a.Location)
+		}
+	}
+}
+
+func TestContainsTimezone(t *testing.T) {
+	officeHours := map[time.Weekday]AvailabilityTime{
+		time.Monday: AvailabilityTime{
+			TimeRanges: []TimeRange{
+				{Start: ParseTime("08:00:00.000"), End: ParseTime("17:00:00.000")},
+			},
+		},
+	}
+	zurich := Availability{Location: loadLocation("Europe/Zurich"), Days: officeHours}
+	singapore := Availability{Location: loadLocation("Asia/Singapore"), Days: officeHours}
+
+	tests := []struct {
+		a               Availability
+		inAvalilability bool
+		timestamp       time.Time
+	}{
+		// Zurich, standard time (UTC+1)
+		{a: zurich, timestamp: ParseDate("Mon 2017/03/20 06:30:00.000"), inAvalilability: false},
+		{a: zurich, timestamp: ParseDate("Mon 2017/03/20 07:30:00.000"), inAvalilability: true},
+		{a: zurich, timestamp: ParseDate("Mon 2017/03/20 15:30:00.000"), inAvalilability: true},
+		{a: zurich, timestamp: ParseDate("Mon 2017/03/20 16:30:00.000"), inAvalilability: false},
+		// Zurich, daylight saving time (UTC+2) after the transition on 2017/03/26
+		{a: zurich, timestamp: ParseDate("Mon 2017/03/27 06:30:00.000"), inAvalilability: true},
+		{a: zurich, timestamp: ParseDate("Mon 2017/03/27 15:30:00.000"), inAvalilability: false},
+		// Singapore (UTC+8), the weekday differs from UTC
+		{a: singapore, timestamp: ParseDate("Sun 2017/03/19 23:30:00.000"), inAvalilability: false},
+		{a: singapore, timestamp: ParseDate("Mon 2017/03/20 00:30:00.000"), inAvalilability: true},
+		{a: singapore, timestamp: ParseDate("Mon 2017/03/20 08:30:00.000"), inAvalilability: true},
+		{a: singapore, timestamp: ParseDate("Mon 2017/03/20 09:30:00.000"), inAvalilability: false},
+	}
+
+	for _, test := range tests {
+		contained := test.a.Contains(test.timestamp)
+		if !contained && test.inAvalilability {
+			t.Errorf("Time %v is not in avalability of %s but should", test.timestamp, test.a.Location)
+		}
+		if contained && !test.inAvalilability {
+			t.Errorf("Time %v is in avalability of %s but should not", test.timestamp, test.a.Location)
+		}
+	}
+}
+
+func TestWindowsDST(t *testing.T) {
+	a := Availability{
+		Location: loadLocation("Europe/Zurich"),
+		Days: map[time.Weekday]AvailabilityTime{
+			time.Sunday: AvailabilityTime{AllDay: true},
+			time.Monday: AvailabilityTime{
+				TimeRanges: []TimeRange{
+					{Start: ParseTime("08:00:00.000"), End: ParseTime("17:00:00.000")},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		start   time.Time
+		end     time.Time
+		windows []TimeRange
+	}{
+		{
+			// 2017/03/26 lasts 23 hours in Zurich
+			start: ParseDate("Sat 2017/03/25 00:00:00.000"),
+			end:   ParseDate("Tue 2017/03/28 00:00:00.000"),
+			windows: []TimeRange{
+				{Start: ParseDate("Sat 2017/03/25 23:00:00.000"), End: ParseDate("Sun 2017/03/26 22:00:00.000")},
+				{Start: ParseDate("Mon 2017/03/27 06:00:00.000"), End: ParseDate("Mon 2017/03/27 15:00:00.000")},
+			},
+		},
+		{
+			// 2017/10/29 lasts 25 hours in Zurich
+			start: ParseDate("Sat 2017/10/28 00:00:00.000"),
+			end:   ParseDate("Tue 2017/10/31 00:00:00.000"),
+			windows: []TimeRange{
+				{Start: ParseDate("Sat 2017/10/28 22:00:00.000"), End: ParseDate("Sun 2017/10/29 23:00:00.000")},
+				{Start: ParseDate("Mon 2017/10/30 07:00:00.000"), End: ParseDate("Mon 2017/10/30 16:00:00.000")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		windows := a.Windows(test.start, test.end)
+		if len(windows) != len(test.windows) {
+			t.Errorf("Expected %d windows from %v to %v, got %d: %v", len(test.windows), test.start, test.end, len(windows), windows)
+			continue
+		}
+		for i, w := range windows {
+			if !w.Start.Equal(test.windows[i].Start) || !w.End.Equal(test.windows[i].End) {
+				t.Errorf("Window %d from %v to %v should last from %v to %v, lasts from %v to %v", i, test.start, test.end, test.windows[i].Start, test.windows[i].End, w.Start, w.End)
+			}
+		}
+	}
+}
+
+func TestOvernight(t *testing.T) {
+	conf := AvailabilityConfig{
+		Timezone: "UTC",
+		Days: map[string][]string{
+			"mon-thu": {"22:00:00-06:00:00"},
+			"friday":  {"18:00:00-24:00:00"},
+		},
+	}
+	a, err := conf.Parse("")
+	if err != nil {
+		t.Fatalf("No error expected but got: %s", err.Error())
+	}
+
+	contains := []struct {
+		inAvalilability bool
+		timestamp       time.Time
+	}{
+		{timestamp: ParseDate("Mon 2017/03/20 05:00:00.000"), inAvalilability: false},
+		{timestamp: ParseDate("Mon 2017/03/20 22:00:00.000"), inAvalilability: true},
+		{timestamp: ParseDate("Tue 2017/03/21 00:00:00.000"), inAvalilability: true},
+		{timestamp: ParseDate("Tue 2017/03/21 05:59:59.999"), inAvalilability: true},
+		{timestamp: ParseDate("Tue 2017/03/21 06:00:00.000"), inAvalilability: false},
+		{timestamp: ParseDate("Fri 2017/03/24 05:00:00.000"), inAvalilability: true},
+		{timestamp: ParseDate("Fri 2017/03/24 23:59:59.999"), inAvalilability: true},
+		{timestamp: ParseDate("Sat 2017/03/25 00:00:00.000"), inAvalilability: false},
+	}
+	for _, test := range contains {
+		contained := a.Contains(test.timestamp)
+		if !contained && test.inAvalilability {
+			t.Errorf("Time %v is not in avalability but should", test.timestamp)
+		}
+		if contained && !test.inAvalilability {
+			t.Errorf("Time %v is in avalability but should not", test.timestamp)
+		}
+	}
+
+	windows := a.Windows(ParseDate("Mon 2017/03/20 03:00:00.000"), ParseDate("Sat 2017/03/25 12:00:00.000"))
+	expected := []TimeRange{
+		{Start: ParseDate("Mon 2017/03/20 22:00:00.000"), End: ParseDate("Tue 2017/03/21 06:00:00.000")},
+		{Start: ParseDate("Tue 2017/03/21 22:00:00.000"), End: ParseDate("Wed 2017/03/22 06:00:00.000")},
+		{Start: ParseDate("Wed 2017/03/22 22:00:00.000"), End: ParseDate("Thu 2017/03/23 06:00:00.000")},
+		{Start: ParseDate("Thu 2017/03/23 22:00:00.000"), End: ParseDate("Fri 2017/03/24 06:00:00.000")},
+		{Start: ParseDate("Fri 2017/03/24 18:00:00.000"), End: ParseDate("Sat 2017/03/25 00:00:00.000")},
+	}
+	if len(windows) != len(expected) {
+		t.Fatalf("Expected %d windows, got %d: %v", len(expected), len(windows), windows)
+	}
+	for i, w := range windows {
+		if !w.Start.Equal(expected[i].Start) || !w.End.Equal(expected[i].End) {
+			t.Errorf("Window %d should last from %v to %v, lasts from %v to %v", i, expected[i].Start, expected[i].End, w.Start, w.End)
+		}
+	}
+
+	// the night from sunday to monday is not part of the availability
+	windows = a.Windows(ParseDate("Tue 2017/03/21 03:00:00.000"), ParseDate("Tue 2017/03/21 04:00:00.000"))
+	if len(windows) != 1 || !windows[0].Start.Equal(ParseDate("Tue 2017/03/21 03:00:00.000")) {
+		t.Errorf("Window carried over from monday expected, got %v", windows)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		conf AvailabilitiesConfig
+		msg  string
+	}{
+		{
+			conf: AvailabilitiesConfig{"office": {Days: map[string][]string{"tuesday": {"08:00:00-08:00:00"}}}},
+			msg:  "availability 'office' is invalid: tuesday: '08:00:00-08:00:00' is an empty time range, start and end must differ",
+		},
+		{
+			conf: AvailabilitiesConfig{"office": {Days: map[string][]string{"mon-fri": {"allday"}, "wednesday": {"allday"}}}},
+			msg:  "availability 'office' is invalid: wednesday: Wednesday is defined more than once",
+		},
+		{
+			conf: AvailabilitiesConfig{"night": {Days: map[string][]string{"mon-sunday-ish": {"allday"}}}},
+			msg:  "availability 'night' is invalid: 'mon-sunday-ish' does not look like a range of weekdays, ranges must be formated as in 'mon-fri'",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := test.conf.Parse("")
+		if err == nil {
+			t.Errorf("Error expected for %v but test succeeded", test.conf)
+		} else if err.Error() != test.msg {
+			t.Errorf("Error message not as expected: Should be '%s', is '%s'", test.msg, err.Error())
+		}
+	}
+}
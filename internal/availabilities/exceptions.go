@@ -0,0 +1,101 @@
+package availabilities
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const dateformat = "2006-01-02"
+
+// DateRange holds a range of dates, 'First' and 'Last' are both included.
+// Only the dates are relevant, they are stored as midnight UTC.
+type DateRange struct {
+	First time.Time
+	Last  time.Time
+}
+
+// Contains checks whether the date of 't' in its location is within the
+// range.
+func (dr DateRange) Contains(t time.Time) bool {
+	date := toDate(t)
+	return !date.Before(dr.First) && !date.After(dr.Last)
+}
+
+// toDate returns the date of 't' in its location as midnight UTC.
+func toDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// toDateRange parses a date (eg. '2017-12-25') or a range of dates (eg.
+// '2017-12-24/2017-12-26').
+func toDateRange(str string) (DateRange, error) {
+	dr := DateRange{}
+	dStrings := strings.Split(str, "/")
+	if len(dStrings) > 2 {
+		return dr, fmt.Errorf("'%s' does not look like a date range, date ranges must be formated as in '%s/%s'", str, dateformat, dateformat)
+	}
+
+	var err error
+	dr.First, err = toDateOnly(dStrings[0])
+	if err != nil {
+		return dr, err
+	}
+	dr.Last = dr.First
+	if len(dStrings) == 2 {
+		dr.Last, err = toDateOnly(dStrings[1])
+		if err != nil {
+			return dr, err
+		}
+	}
+
+	if dr.Last.Before(dr.First) {
+		return dr, fmt.Errorf("date range '%s' ends before it starts", str)
+	}
+	return dr, nil
+}
+
+func toDateOnly(dString string) (time.Time, error) {
+	dString = strings.TrimSpace(dString)
+	d, err := time.Parse(dateformat, dString)
+	if err != nil {
+		return d, fmt.Errorf("'%s' does not look like a date, dates must be formated as in '%s'", dString, dateformat)
+	}
+	return d, nil
+}
+
+// toExtraTimeRange parses a time range on a specific date (eg. '2017-03-25
+// 10:00:00-14:00:00') in the location provided. If no time range or
+// 'allday' is provided, the whole day is returned.
+func toExtraTimeRange(str string, loc *time.Location) (TimeRange, error) {
+	tr := TimeRange{}
+	fields := strings.Fields(str)
+	if len(fields) < 1 || len(fields) > 2 {
+		return tr, fmt.Errorf("'%s' does not look like a time range on a date, it must be formated as in '%s %s-%s'", str, dateformat, timeformat, timeformat)
+	}
+
+	date, err := toDateOnly(fields[0])
+	if err != nil {
+		return tr, err
+	}
+	year, month, day := date.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, loc)
+
+	if len(fields) == 1 {
+		fields = append(fields, "allday")
+	}
+	at, err := toAvailabilityTime(fields[1:])
+	if err != nil {
+		return tr, err
+	}
+	if at.AllDay {
+		tr.Start = midnight
+		tr.End = nextDay(midnight)
+		return tr, nil
+	}
+
+	tr.Start = onDay(midnight, at.TimeRanges[0].Start)
+	tr.End = onDay(midnight, at.TimeRanges[0].End)
+	return tr, nil
+}
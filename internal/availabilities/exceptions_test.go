@@ -0,0 +1,154 @@
+package availabilities
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestToDateRange(t *testing.T) {
+	tests := []struct {
+		str         string
+		dr          DateRange
+		errExpected bool
+	}{
+		{str: "2017-12-25", dr: DateRange{First: date("2017-12-25"), Last: date("2017-12-25")}},
+		{str: "2017-12-24/2017-12-26", dr: DateRange{First: date("2017-12-24"), Last: date("2017-12-26")}},
+		{str: "2017-12-26/2017-12-24", errExpected: true},
+		{str: "2017-12-24/2017-12-26/2017-12-28", errExpected: true},
+		{str: "christmas", errExpected: true},
+	}
+
+	for _, test := range tests {
+		dr, err := toDateRange(test.str)
+		if err == nil && test.errExpected {
+			t.Errorf("Error expected for '%s' but test succeeded", test.str)
+		} else if err != nil && !test.errExpected {
+			t.Errorf("No error expected for '%s' but test failed: %s", test.str, err.Error())
+		} else if err == nil && (!dr.First.Equal(test.dr.First) || !dr.Last.Equal(test.dr.Last)) {
+			t.Errorf("Result not as expected for '%s': Should be '%v', is '%v'", test.str, test.dr, dr)
+		}
+	}
+}
+
+func TestToExtraTimeRange(t *testing.T) {
+	tests := []struct {
+		str         string
+		tr          TimeRange
+		errExpected bool
+	}{
+		{str: "2017-03-25 10:00:00-14:00:00", tr: TimeRange{Start: ParseDate("Sat 2017/03/25 10:00:00.000"), End: ParseDate("Sat 2017/03/25 14:00:00.000")}},
+		{str: "2017-03-25 allday", tr: TimeRange{Start: ParseDate("Sat 2017/03/25 00:00:00.000"), End: ParseDate("Sun 2017/03/26 00:00:00.000")}},
+		{str: "2017-03-25", tr: TimeRange{Start: ParseDate("Sat 2017/03/25 00:00:00.000"), End: ParseDate("Sun 2017/03/26 00:00:00.000")}},
+		{str: "2017-03-25 10:00:00", errExpected: true},
+		{str: "saturday 10:00:00-14:00:00", errExpected: true},
+		{str: "2017-03-25 10:00:00-12:00:00 13:00:00-14:00:00", errExpected: true},
+	}
+
+	for _, test := range tests {
+		tr, err := toExtraTimeRange(test.str, time.UTC)
+		if err == nil && test.errExpected {
+			t.Errorf("Error expected for '%s' but test succeeded", test.str)
+		} else if err != nil && !test.errExpected {
+			t.Errorf("No error expected for '%s' but test failed: %s", test.str, err.Error())
+		} else if err == nil && (!tr.Start.Equal(test.tr.Start) || !tr.End.Equal(test.tr.End)) {
+			t.Errorf("Result not as expected for '%s': Should be '%v', is '%v'", test.str, test.tr, tr)
+		}
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	base, err := ioutil.TempDir("", "bpmon-availabilities")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(base)
+	err = ioutil.WriteFile(filepath.Join(base, "holidays.ics"), []byte(calendar), 0644)
+	if err != nil {
+		t.Fatalf("Could not write calendar: %s", err.Error())
+	}
+
+	weekdays := []string{"08:00:00-17:00:00"}
+	conf := AvailabilitiesConfig{
+		"office": AvailabilityConfig{
+			Timezone: "UTC",
+			Exclude:  []string{"2017-03-17", "2017-03-21/2017-03-22"},
+			Holidays: []string{"holidays.ics"},
+			Extra:    []string{"2017-03-18 10:00:00-14:00:00", "2017-03-22 12:00:00-13:00:00"},
+			Days: map[string][]string{
+				"monday": weekdays, "tuesday": weekdays, "wednesday": weekdays, "thursday": weekdays, "friday": weekdays,
+			},
+		},
+	}
+
+	availabilities, err := conf.Parse(base)
+	if err != nil {
+		t.Fatalf("No error expected but availabilities could not be parsed: %s", err.Error())
+	}
+	a := availabilities["office"]
+
+	tests := []struct {
+		inAvalilability bool
+		timestamp       time.Time
+	}{
+		{timestamp: ParseDate("Thu 2017/03/16 09:00:00.000"), inAvalilability: true},
+		{timestamp: ParseDate("Fri 2017/03/17 09:00:00.000"), inAvalilability: false},
+		{timestamp: ParseDate("Sat 2017/03/18 09:00:00.000"), inAvalilability: false},
+		{timestamp: ParseDate("Sat 2017/03/18 11:00:00.000"), inAvalilability: true},
+		{timestamp: ParseDate("Tue 2017/03/21 09:00:00.000"), inAvalilability: false},
+		{timestamp: ParseDate("Wed 2017/03/22 09:00:00.000"), inAvalilability: false},
+		{timestamp: ParseDate("Wed 2017/03/22 12:30:00.000"), inAvalilability: true},
+		{timestamp: ParseDate("Mon 2017/12/25 09:00:00.000"), inAvalilability: false},
+		{timestamp: ParseDate("Tue 2017/12/26 09:00:00.000"), inAvalilability: true},
+		{timestamp: ParseDate("Mon 2018/04/02 09:00:00.000"), inAvalilability: false},
+	}
+
+	for _, test := range tests {
+		contained := a.Contains(test.timestamp)
+		if !contained && test.inAvalilability {
+			t.Errorf("Time %v is not in avalability but should", test.timestamp)
+		}
+		if contained && !test.inAvalilability {
+			t.Errorf("Time %v is in avalability but should not", test.timestamp)
+		}
+	}
+
+	windows := a.Windows(ParseDate("Thu 2017/03/16 00:00:00.000"), ParseDate("Thu 2017/03/23 00:00:00.000"))
+	expected := []TimeRange{
+		{Start: ParseDate("Thu 2017/03/16 08:00:00.000"), End: ParseDate("Thu 2017/03/16 17:00:00.000")},
+		{Start: ParseDate("Sat 2017/03/18 10:00:00.000"), End: ParseDate("Sat 2017/03/18 14:00:00.000")},
+		{Start: ParseDate("Mon 2017/03/20 08:00:00.000"), End: ParseDate("Mon 2017/03/20 17:00:00.000")},
+		{Start: ParseDate("Wed 2017/03/22 12:00:00.000"), End: ParseDate("Wed 2017/03/22 13:00:00.000")},
+	}
+	if len(windows) != len(expected) {
+		t.Fatalf("Expected %d windows, got %d: %v", len(expected), len(windows), windows)
+	}
+	for i, w := range windows {
+		if !w.Start.Equal(expected[i].Start) || !w.End.Equal(expected[i].End) {
+			t.Errorf("Window %d should last from %v to %v, lasts from %v to %v", i, expected[i].Start, expected[i].End, w.Start, w.End)
+		}
+	}
+}
+
+func TestValidateAvailabilities(t *testing.T) {
+	conf := AvailabilitiesConfig{
+		"valid":   AvailabilityConfig{Holidays: []string{"does/not/exist.ics"}, Days: map[string][]string{"monday": {"allday"}}},
+		"exclude": AvailabilityConfig{Exclude: []string{"christmas"}},
+		"extra":   AvailabilityConfig{Extra: []string{"2017-03-18 noon"}},
+		"day":     AvailabilityConfig{Days: map[string][]string{"someday": {"allday"}}},
+	}
+	errs, err := conf.Validate()
+	if err == nil {
+		t.Fatalf("Error expected but got nil")
+	}
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %d: %v", len(errs), errs)
+	}
+
+	_, err = AvailabilitiesConfig{"valid": conf["valid"]}.Parse("")
+	if err == nil {
+		t.Errorf("Error expected for holiday calendar which does not exist but got nil")
+	}
+}
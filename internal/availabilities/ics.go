@@ -0,0 +1,404 @@
+package availabilities
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxOccurrences limits the number of occurrences of a recurring event
+// without end.
+const maxOccurrences = 100
+
+// readCalendar reads an iCalendar file relative to 'base' unless 'path' is
+// absolute and returns the dates of all its events in the location 'loc'.
+// Events which cannot be interpreted are skipped and logged.
+func readCalendar(base string, path string, loc *time.Location) ([]DateRange, error) {
+	path = calendarPath(base, path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading holiday calendar '%s': %s", path, err.Error())
+	}
+	drs, skipped, err := parseCalendar(data, loc)
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing holiday calendar '%s': %s", path, err.Error())
+	}
+	for _, msg := range skipped {
+		log.Printf("Skipping %s of holiday calendar '%s'", msg, path)
+	}
+	return drs, nil
+}
+
+// calendarPath returns 'path' relative to 'base' unless it is absolute.
+func calendarPath(base string, path string) string {
+	if !filepath.IsAbs(path) && base != "" {
+		return filepath.Join(base, path)
+	}
+	return path
+}
+
+// property is a property of an event such as 'DTSTART;TZID=Europe/Zurich:
+// 20171225T000000'.
+type property struct {
+	params map[string]string
+	value  string
+}
+
+// event holds the properties of an event by their name. Properties such as
+// EXDATE may occur several times.
+type event map[string][]property
+
+func (e event) get(name string) (property, bool) {
+	props, ok := e[name]
+	if !ok || len(props) == 0 {
+		return property{}, false
+	}
+	return props[0], true
+}
+
+// unsupportedError is returned if an event uses features of iCalendar which
+// are not supported. Such events are skipped rather than rejecting the
+// calendar as a whole.
+type unsupportedError struct {
+	msg string
+}
+
+func (e unsupportedError) Error() string { return e.msg }
+
+// parseCalendar returns the dates of all events of an iCalendar (RFC 5545).
+// Only the dates of the events in the location 'loc' are considered. Events
+// with a STATUS of CANCELLED are ignored. Recurring events are supported if
+// they recur yearly, optionally by month and weekday, EXDATE is respected.
+// Events which use features not supported are skipped, a message for each
+// event skipped is returned.
+func parseCalendar(data []byte, loc *time.Location) (drs []DateRange, skipped []string, err error) {
+	var e event
+	events := 0
+
+	for _, line := range unfold(data) {
+		switch {
+		case line == "BEGIN:VEVENT":
+			e = make(event)
+			events++
+		case line == "END:VEVENT":
+			if e == nil {
+				return nil, nil, fmt.Errorf("END:VEVENT without BEGIN:VEVENT after event %d", events)
+			}
+			summary, _ := e.get("SUMMARY")
+			dates, err := eventDates(e, loc)
+			if _, ok := err.(unsupportedError); ok {
+				skipped = append(skipped, fmt.Sprintf("event %d (%s): %s", events, summary.value, err.Error()))
+			} else if err != nil {
+				return nil, nil, fmt.Errorf("event %d (%s): %s", events, summary.value, err.Error())
+			}
+			drs = append(drs, dates...)
+			e = nil
+		case e != nil:
+			sep := strings.Index(line, ":")
+			if sep < 0 {
+				continue
+			}
+			// parameters such as in 'DTSTART;VALUE=DATE:20171225'
+			parts := strings.Split(line[:sep], ";")
+			p := property{params: make(map[string]string), value: line[sep+1:]}
+			for _, param := range parts[1:] {
+				kv := strings.SplitN(param, "=", 2)
+				if len(kv) == 2 {
+					p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+				}
+			}
+			name := strings.ToUpper(parts[0])
+			e[name] = append(e[name], p)
+		}
+	}
+	return drs, skipped, nil
+}
+
+// unfold returns the logical lines of an iCalendar, lines starting with a
+// space or a tab continue the previous line.
+func unfold(data []byte) []string {
+	var out []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(out) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			out[len(out)-1] += line[1:]
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// eventDates returns the dates of an event and its recurrences in the
+// location 'loc'.
+func eventDates(e event, loc *time.Location) ([]DateRange, error) {
+	if st, ok := e.get("STATUS"); ok && strings.ToUpper(strings.TrimSpace(st.value)) == "CANCELLED" {
+		return nil, nil
+	}
+
+	dtstart, ok := e.get("DTSTART")
+	if !ok {
+		return nil, fmt.Errorf("DTSTART is missing")
+	}
+	start, _, err := toICSDate(dtstart, loc)
+	if err != nil {
+		return nil, fmt.Errorf("DTSTART is invalid: %s", err.Error())
+	}
+
+	last := start
+	if dtend, ok := e.get("DTEND"); ok {
+		end, midnight, err := toICSDate(dtend, loc)
+		if err != nil {
+			return nil, fmt.Errorf("DTEND is invalid: %s", err.Error())
+		}
+		// the end of an event is not part of the event
+		if midnight {
+			end = end.AddDate(0, 0, -1)
+		}
+		if end.After(last) {
+			last = end
+		}
+	}
+	first := DateRange{First: start, Last: last}
+
+	rrule, ok := e.get("RRULE")
+	if !ok {
+		return []DateRange{first}, nil
+	}
+	occurrences, err := recur(first, rrule.value, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[time.Time]bool)
+	for _, exdate := range e["EXDATE"] {
+		for _, value := range strings.Split(exdate.value, ",") {
+			date, _, err := toICSDate(property{params: exdate.params, value: value}, loc)
+			if err != nil {
+				return nil, fmt.Errorf("EXDATE is invalid: %s", err.Error())
+			}
+			excluded[date] = true
+		}
+	}
+	var out []DateRange
+	for _, dr := range occurrences {
+		if !excluded[dr.First] {
+			out = append(out, dr)
+		}
+	}
+	return out, nil
+}
+
+// toICSDate parses a value of type DATE or DATE-TIME and returns its date in
+// the location 'loc'. DATE-TIME values in UTC (eg. '20171225T230000Z') or
+// with a TZID parameter are converted to 'loc', all others are considered to
+// be in 'loc' already. 'midnight' is true if the value is a DATE or a
+// DATE-TIME at midnight in 'loc'.
+func toICSDate(p property, loc *time.Location) (date time.Time, midnight bool, err error) {
+	str := strings.TrimSpace(p.value)
+	if len(str) == 8 {
+		date, err = time.Parse("20060102", str)
+		if err != nil {
+			return date, false, fmt.Errorf("'%s' does not look like a date", str)
+		}
+		return date, true, nil
+	}
+
+	in := loc
+	if strings.HasSuffix(str, "Z") {
+		in = time.UTC
+		str = strings.TrimSuffix(str, "Z")
+	} else if tzid, ok := p.params["TZID"]; ok {
+		in, err = time.LoadLocation(tzid)
+		if err != nil {
+			return date, false, unsupportedError{msg: fmt.Sprintf("TZID '%s' is not supported: %s", tzid, err.Error())}
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", str, in)
+	if err != nil {
+		return date, false, fmt.Errorf("'%s' does not look like a date", p.value)
+	}
+	t = t.In(loc)
+	midnight = t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
+	return toDate(t), midnight, nil
+}
+
+// weekdays maps the weekdays of BYDAY to their time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// byDay is an entry of BYDAY such as '4TH' (the fourth thursday) or '-1MO'
+// (the last monday). If 'n' is 0, every such weekday matches.
+type byDay struct {
+	n       int
+	weekday time.Weekday
+}
+
+func parseByDay(str string) (byDay, error) {
+	str = strings.ToUpper(strings.TrimSpace(str))
+	if len(str) < 2 {
+		return byDay{}, fmt.Errorf("BYDAY '%s' is invalid", str)
+	}
+	wd, ok := weekdays[str[len(str)-2:]]
+	if !ok {
+		return byDay{}, fmt.Errorf("BYDAY '%s' is invalid", str)
+	}
+	bd := byDay{weekday: wd}
+	if n := str[:len(str)-2]; n != "" {
+		var err error
+		bd.n, err = strconv.Atoi(n)
+		if err != nil || bd.n == 0 || bd.n < -5 || bd.n > 5 {
+			return byDay{}, fmt.Errorf("BYDAY '%s' is invalid", str)
+		}
+	}
+	return bd, nil
+}
+
+// dates returns the dates of the month matching the entry.
+func (bd byDay) dates(year int, month time.Month) []time.Time {
+	var all []time.Time
+	for d := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC); d.Month() == month; d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == bd.weekday {
+			all = append(all, d)
+		}
+	}
+	switch {
+	case bd.n == 0:
+		return all
+	case bd.n > 0 && bd.n <= len(all):
+		return all[bd.n-1 : bd.n]
+	case bd.n < 0 && -bd.n <= len(all):
+		return all[len(all)+bd.n : len(all)+bd.n+1]
+	}
+	return nil
+}
+
+// recur returns all occurrences of an event according to 'rrule'. Only
+// yearly recurrences are supported, optionally limited to the months in
+// BYMONTH and the weekdays in BYDAY. BYDAY requires BYMONTH. Recurrences
+// without COUNT and UNTIL are limited to 'maxOccurrences'.
+func recur(first DateRange, rrule string, loc *time.Location) ([]DateRange, error) {
+	yearly := false
+	count := 0
+	interval := 1
+	var until time.Time
+	var months []time.Month
+	var days []byDay
+	for _, part := range strings.Split(rrule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("RRULE '%s' is invalid", rrule)
+		}
+		var err error
+		switch strings.ToUpper(kv[0]) {
+		case "FREQ":
+			yearly = strings.ToUpper(kv[1]) == "YEARLY"
+		case "COUNT":
+			count, err = strconv.Atoi(kv[1])
+		case "INTERVAL":
+			interval, err = strconv.Atoi(kv[1])
+		case "UNTIL":
+			until, _, err = toICSDate(property{value: kv[1]}, loc)
+		case "BYMONTH":
+			for _, str := range strings.Split(kv[1], ",") {
+				var m int
+				m, err = strconv.Atoi(str)
+				if err != nil || m < 1 || m > 12 {
+					err = fmt.Errorf("BYMONTH '%s' is invalid", str)
+					break
+				}
+				months = append(months, time.Month(m))
+			}
+		case "BYDAY":
+			for _, str := range strings.Split(kv[1], ",") {
+				var bd byDay
+				bd, err = parseByDay(str)
+				if err != nil {
+					break
+				}
+				days = append(days, bd)
+			}
+		case "WKST":
+			// only relevant for weekly recurrences
+		default:
+			return nil, unsupportedError{msg: fmt.Sprintf("RRULE '%s' is not supported, %s is unknown", rrule, kv[0])}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("RRULE '%s' is invalid: %s", rrule, err.Error())
+		}
+	}
+	if !yearly {
+		return nil, unsupportedError{msg: fmt.Sprintf("RRULE '%s' is not supported, only FREQ=YEARLY is", rrule)}
+	}
+	if len(days) > 0 && len(months) == 0 {
+		return nil, unsupportedError{msg: fmt.Sprintf("RRULE '%s' is not supported, BYDAY requires BYMONTH", rrule)}
+	}
+	if interval < 1 || count < 0 {
+		return nil, fmt.Errorf("RRULE '%s' is invalid: COUNT and INTERVAL must be positive", rrule)
+	}
+	if count == 0 && until.IsZero() {
+		count = maxOccurrences
+	}
+
+	length := int(first.Last.Sub(first.First).Hours() / 24)
+	occurrence := func(date time.Time) DateRange {
+		return DateRange{First: date, Last: date.AddDate(0, 0, length)}
+	}
+
+	out := []DateRange{first}
+	for i := 0; i < maxOccurrences*interval && (count == 0 || len(out) < count); i += interval {
+		for _, date := range yearlyDates(first.First, first.First.Year()+i, months, days) {
+			if !date.After(first.First) {
+				continue
+			}
+			if !until.IsZero() && date.After(until) {
+				return out, nil
+			}
+			if count > 0 && len(out) >= count {
+				return out, nil
+			}
+			out = append(out, occurrence(date))
+		}
+	}
+	return out, nil
+}
+
+// yearlyDates returns the dates of 'year' matching the months and weekdays
+// provided, ordered by date. If no months are provided, the month of 'start'
+// is used. If no weekdays are provided, the day of 'start' is used.
+func yearlyDates(start time.Time, year int, months []time.Month, days []byDay) []time.Time {
+	if len(months) == 0 {
+		months = []time.Month{start.Month()}
+	}
+	var out []time.Time
+	for _, month := range months {
+		if len(days) == 0 {
+			date := time.Date(year, month, start.Day(), 0, 0, 0, 0, time.UTC)
+			// skip days which do not exist such as 02-29 in common years
+			if date.Month() == month {
+				out = append(out, date)
+			}
+			continue
+		}
+		for _, bd := range days {
+			out = append(out, bd.dates(year, month)...)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
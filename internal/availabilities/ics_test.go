@@ -0,0 +1,189 @@
+package availabilities
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func date(str string) time.Time {
+	d, err := time.Parse(dateformat, str)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+const calendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//Holidays//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Christmas Day\r\n" +
+	"DTSTART;VALUE=DATE:20171225\r\n" +
+	"DTEND;VALUE=DATE:20171226\r\n" +
+	"RRULE:FREQ=YEARLY;COUNT=2\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Easter\r\n" +
+	" Monday\r\n" +
+	"DTSTART;VALUE=DATE:20180330\r\n" +
+	"DTEND;VALUE=DATE:20180403\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Company Event\r\n" +
+	"DTSTART:20180615T120000Z\r\n" +
+	"DTEND:20180615T180000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Labour Day\r\n" +
+	"DTSTART;VALUE=DATE:20170501\r\n" +
+	"RRULE:FREQ=YEARLY;INTERVAL=2;UNTIL=20211231\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseCalendar(t *testing.T) {
+	drs, skipped, err := parseCalendar([]byte(calendar), time.UTC)
+	if err != nil {
+		t.Fatalf("No error expected but calendar could not be parsed: %s", err.Error())
+	}
+
+	expected := []DateRange{
+		{First: date("2017-12-25"), Last: date("2017-12-25")},
+		{First: date("2018-12-25"), Last: date("2018-12-25")},
+		{First: date("2018-03-30"), Last: date("2018-04-02")},
+		{First: date("2018-06-15"), Last: date("2018-06-15")},
+		{First: date("2017-05-01"), Last: date("2017-05-01")},
+		{First: date("2019-05-01"), Last: date("2019-05-01")},
+		{First: date("2021-05-01"), Last: date("2021-05-01")},
+	}
+	if !reflect.DeepEqual(drs, expected) {
+		t.Errorf("Dates of calendar do not match: '%v' vs. '%v'", drs, expected)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("Expected no events to be skipped, got '%v'", skipped)
+	}
+
+	drs, _, err = parseCalendar([]byte("BEGIN:VEVENT\nDTSTART;VALUE=DATE:20170101\nRRULE:FREQ=YEARLY\nEND:VEVENT\n"), time.UTC)
+	if err != nil || len(drs) != maxOccurrences {
+		t.Errorf("Expected %d occurrences of an endless event, got %d (%v)", maxOccurrences, len(drs), err)
+	}
+}
+
+func TestParseCalendarErrors(t *testing.T) {
+	tests := map[string]string{
+		"no start":       "BEGIN:VEVENT\nSUMMARY:x\nEND:VEVENT\n",
+		"invalid start":  "BEGIN:VEVENT\nDTSTART:2017\nEND:VEVENT\n",
+		"invalid end":    "BEGIN:VEVENT\nDTSTART:20170101\nDTEND:tomorrow\nEND:VEVENT\n",
+		"invalid count":  "BEGIN:VEVENT\nDTSTART:20170101\nRRULE:FREQ=YEARLY;COUNT=x\nEND:VEVENT\n",
+		"invalid by day": "BEGIN:VEVENT\nDTSTART:20170101\nRRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4XX\nEND:VEVENT\n",
+		"invalid exdate": "BEGIN:VEVENT\nDTSTART:20170101\nRRULE:FREQ=YEARLY\nEXDATE:never\nEND:VEVENT\n",
+		"unexpected end": "END:VEVENT\n",
+	}
+
+	for name, cal := range tests {
+		_, _, err := parseCalendar([]byte(cal), time.UTC)
+		if err == nil {
+			t.Errorf("Error expected for '%s' but got nil", name)
+		}
+	}
+}
+
+func TestParseCalendarRecurrences(t *testing.T) {
+	tests := map[string]struct {
+		cal      string
+		expected []DateRange
+	}{
+		"by month and day": {
+			cal: "BEGIN:VEVENT\nSUMMARY:Thanksgiving\nDTSTART;VALUE=DATE:20171123\nRRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=3\nEND:VEVENT\n",
+			expected: []DateRange{
+				{First: date("2017-11-23"), Last: date("2017-11-23")},
+				{First: date("2018-11-22"), Last: date("2018-11-22")},
+				{First: date("2019-11-28"), Last: date("2019-11-28")},
+			},
+		},
+		"last weekday": {
+			cal: "BEGIN:VEVENT\nSUMMARY:Memorial Day\nDTSTART;VALUE=DATE:20180528\nRRULE:FREQ=YEARLY;BYMONTH=5;BYDAY=-1MO;UNTIL=20191231\nEND:VEVENT\n",
+			expected: []DateRange{
+				{First: date("2018-05-28"), Last: date("2018-05-28")},
+				{First: date("2019-05-27"), Last: date("2019-05-27")},
+			},
+		},
+		"excluded": {
+			cal: "BEGIN:VEVENT\nDTSTART;VALUE=DATE:20170101\nRRULE:FREQ=YEARLY;COUNT=4\nEXDATE;VALUE=DATE:20180101,20190101\nEXDATE:20200101T000000\nEND:VEVENT\n",
+			expected: []DateRange{
+				{First: date("2017-01-01"), Last: date("2017-01-01")},
+			},
+		},
+		"cancelled": {
+			cal:      "BEGIN:VEVENT\nSTATUS:CANCELLED\nDTSTART;VALUE=DATE:20170101\nEND:VEVENT\n",
+			expected: nil,
+		},
+	}
+
+	for name, test := range tests {
+		drs, skipped, err := parseCalendar([]byte(test.cal), time.UTC)
+		if err != nil {
+			t.Errorf("No error expected for '%s' but calendar could not be parsed: %s", name, err.Error())
+			continue
+		}
+		if len(skipped) != 0 {
+			t.Errorf("Expected no events of '%s' to be skipped, got '%v'", name, skipped)
+		}
+		if !reflect.DeepEqual(drs, test.expected) {
+			t.Errorf("Dates of '%s' do not match: '%v' vs. '%v'", name, drs, test.expected)
+		}
+	}
+}
+
+func TestParseCalendarLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Skipf("Timezone database not available: %s", err.Error())
+	}
+	cal := "BEGIN:VEVENT\nDTSTART:20180615T120000Z\nDTEND:20180615T130000Z\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nDTSTART;TZID=Europe/Zurich:20180701T230000\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nDTSTART:20180801T230000\nEND:VEVENT\n"
+	drs, _, err := parseCalendar([]byte(cal), loc)
+	if err != nil {
+		t.Fatalf("No error expected but calendar could not be parsed: %s", err.Error())
+	}
+	expected := []DateRange{
+		{First: date("2018-06-16"), Last: date("2018-06-16")},
+		{First: date("2018-07-02"), Last: date("2018-07-02")},
+		{First: date("2018-08-01"), Last: date("2018-08-01")},
+	}
+	if !reflect.DeepEqual(drs, expected) {
+		t.Errorf("Dates of calendar do not match: '%v' vs. '%v'", drs, expected)
+	}
+}
+
+func TestParseCalendarSkipped(t *testing.T) {
+	cal := "BEGIN:VEVENT\nSUMMARY:Weekly\nDTSTART:20170101\nRRULE:FREQ=WEEKLY\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nSUMMARY:Monthly\nDTSTART:20170101\nRRULE:FREQ=YEARLY;BYMONTHDAY=1\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nSUMMARY:Mondays\nDTSTART:20170101\nRRULE:FREQ=YEARLY;BYDAY=MO\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nSUMMARY:New Year\nDTSTART:20170101\nEND:VEVENT\n"
+	drs, skipped, err := parseCalendar([]byte(cal), time.UTC)
+	if err != nil {
+		t.Fatalf("No error expected but calendar could not be parsed: %s", err.Error())
+	}
+	expected := []DateRange{{First: date("2017-01-01"), Last: date("2017-01-01")}}
+	if !reflect.DeepEqual(drs, expected) {
+		t.Errorf("Dates of calendar do not match: '%v' vs. '%v'", drs, expected)
+	}
+	if len(skipped) != 3 {
+		t.Errorf("Expected 3 events to be skipped, got '%v'", skipped)
+	}
+}
+
+func TestCalendars(t *testing.T) {
+	ac := AvailabilitiesConfig{
+		"a": {Holidays: []string{"holidays/ch.ics", "/etc/bpmon/zh.ics"}},
+		"b": {Holidays: []string{"holidays/de.ics"}},
+		"c": {},
+	}
+	expected := []string{"/etc/bpmon/zh.ics", "base/holidays/ch.ics", "base/holidays/de.ics"}
+	calendars := ac.Calendars("base")
+	if !reflect.DeepEqual(calendars, expected) {
+		t.Errorf("Expected calendars to be '%v', got '%v'", expected, calendars)
+	}
+}
@@ -1,14 +1,19 @@
 package bpmon
 
 import (
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/unprofession-al/bpmon/internal/availabilities"
 	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/maintenance"
 	"github.com/unprofession-al/bpmon/internal/math"
 	"github.com/unprofession-al/bpmon/internal/rules"
 	"github.com/unprofession-al/bpmon/internal/status"
@@ -44,6 +49,16 @@ func LoadBP(bpPath string, bpPattern string, a availabilities.Availabilities, gr
 		bps = append(bps, bp)
 	}
 
+	err = bps.resolve()
+	if err != nil {
+		return bps, fmt.Errorf("error while resolving business processes in %s: %s", bpPath, err.Error())
+	}
+
+	errs, err := bps.Validate()
+	if err != nil {
+		return bps, fmt.Errorf("%s: %s", err.Error(), strings.Join(errs, " "))
+	}
+
 	return bps, nil
 }
 
@@ -74,6 +89,211 @@ func parseBP(bpconf []byte, a availabilities.Availabilities, gr []string) (BP, e
 // BusinessProcesses keepes a list of BusinessProcess.
 type BusinessProcesses []BP
 
+// Validate checks the business processes for errors which cannot be detected
+// while parsing a single business process, such as duplicate IDs or invalid
+// operations.
+func (bps BusinessProcesses) Validate() ([]string, error) {
+	errs := []string{}
+	bpIDs := make(map[string]bool)
+	for _, bp := range bps {
+		if bp.ID == "" {
+			errs = append(errs, fmt.Sprintf("Field 'id' of business process '%s' cannot be empty.", bp.Name))
+		} else if bpIDs[bp.ID] {
+			errs = append(errs, fmt.Sprintf("Business process id '%s' is not unique.", bp.ID))
+		}
+		bpIDs[bp.ID] = true
+
+		if bp.SLO != nil {
+			for _, msg := range bp.SLO.Validate() {
+				errs = append(errs, fmt.Sprintf("SLO of business process '%s' is invalid: %s", bp.ID, msg))
+			}
+		}
+
+		if err := math.Validate(bp.operation()); err != nil {
+			errs = append(errs, fmt.Sprintf("Field 'operation' of business process '%s' is invalid: %s.", bp.ID, err.Error()))
+		}
+		if err := bp.Unknown.Validate(); err != nil {
+			errs = append(errs, fmt.Sprintf("Field 'unknown' of business process '%s' is invalid: %s.", bp.ID, err.Error()))
+		}
+
+		kpiIDs := make(map[string]bool)
+		for _, k := range bp.Kpis {
+			if k.ID == "" {
+				errs = append(errs, fmt.Sprintf("Field 'id' of KPI '%s' in business process '%s' cannot be empty.", k.Name, bp.ID))
+			} else if kpiIDs[k.ID] {
+				errs = append(errs, fmt.Sprintf("KPI id '%s' in business process '%s' is not unique.", k.ID, bp.ID))
+			}
+			kpiIDs[k.ID] = true
+
+			if err := math.Validate(k.Operation); err != nil {
+				errs = append(errs, fmt.Sprintf("Field 'operation' of KPI '%s' in business process '%s' is invalid: %s.", k.ID, bp.ID, err.Error()))
+			}
+			if err := k.Unknown.Validate(); err != nil {
+				errs = append(errs, fmt.Sprintf("Field 'unknown' of KPI '%s' in business process '%s' is invalid: %s.", k.ID, bp.ID, err.Error()))
+			}
+
+			for _, s := range k.Services {
+				if s.Host == "" || s.Service == "" {
+					errs = append(errs, fmt.Sprintf("Fields 'host' and 'service' of services in KPI '%s' in business process '%s' cannot be empty.", k.ID, bp.ID))
+				}
+				if s.Weight != nil && *s.Weight < 0 {
+					errs = append(errs, fmt.Sprintf("Field 'weight' of service '%s!%s' in KPI '%s' in business process '%s' cannot be negative.", s.Host, s.Service, k.ID, bp.ID))
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		err := errors.New("business processes have errors")
+		return errs, err
+	}
+	return errs, nil
+}
+
+// resolve links the business processes referenced by KPIs. An error is
+// returned if a reference cannot be resolved or if the references form a
+// cycle.
+func (bps BusinessProcesses) resolve() error {
+	index := make(map[string]*BP)
+	for i := range bps {
+		index[bps[i].ID] = &bps[i]
+	}
+
+	for i := range bps {
+		for j := range bps[i].Kpis {
+			k := &bps[i].Kpis[j]
+			k.processes = []*BP{}
+			for _, id := range k.BPs {
+				ref, ok := index[id]
+				if !ok {
+					return fmt.Errorf("business process '%s' referenced by KPI '%s' of business process '%s' does not exist", id, k.ID, bps[i].ID)
+				}
+				k.processes = append(k.processes, ref)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(bp *BP, path []string) error
+	visit = func(bp *BP, path []string) error {
+		path = append(path, bp.ID)
+		switch state[bp.ID] {
+		case visiting:
+			return fmt.Errorf("business processes reference each other in a cycle: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		state[bp.ID] = visiting
+		for _, k := range bp.Kpis {
+			for _, ref := range k.processes {
+				if err := visit(ref, path); err != nil {
+					return err
+				}
+			}
+		}
+		state[bp.ID] = visited
+		return nil
+	}
+	for i := range bps {
+		if err := visit(&bps[i], []string{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Maintain assigns the maintenance windows to the business processes they
+// may apply to. An error is returned if a maintenance window references a
+// business process or a KPI which does not exist.
+func (bps BusinessProcesses) Maintain(windows maintenance.Windows) error {
+	for _, w := range windows {
+		if err := bps.CheckMaintenance(w); err != nil {
+			return err
+		}
+	}
+	for i := range bps {
+		bps[i].Maintenance = windows.For(bps[i].ID)
+	}
+	return nil
+}
+
+// CheckMaintenance verifies that the business process and the KPI
+// referenced by the maintenance window exist.
+func (bps BusinessProcesses) CheckMaintenance(w maintenance.Window) error {
+	if w.BP == "" {
+		return nil
+	}
+	for _, bp := range bps {
+		if bp.ID != w.BP {
+			continue
+		}
+		if w.KPI == "" {
+			return nil
+		}
+		for _, k := range bp.Kpis {
+			if k.ID == w.KPI {
+				return nil
+			}
+		}
+		return fmt.Errorf("KPI '%s' of business process '%s' referenced by maintenance window '%s' does not exist", w.KPI, w.BP, w.ID)
+	}
+	return fmt.Errorf("business process '%s' referenced by maintenance window '%s' does not exist", w.BP, w.ID)
+}
+
+// Services returns a distinct list of all services used by the business
+// processes.
+func (bps BusinessProcesses) Services() []checker.Service {
+	var out []checker.Service
+	seen := make(map[checker.Service]bool)
+	for _, bp := range bps {
+		for _, k := range bp.Kpis {
+			for _, s := range k.Services {
+				svc := checker.Service{Host: s.Host, Service: s.Service, Checker: s.Checker}
+				if !seen[svc] {
+					seen[svc] = true
+					out = append(out, svc)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// ValidateCheckers verifies that the checker backends referenced by the
+// services exist in the checker provided.
+func (bps BusinessProcesses) ValidateCheckers(chk checker.Checker) error {
+	var missing []string
+	seen := make(map[string]bool)
+	for _, svc := range bps.Services() {
+		if seen[svc.Checker] {
+			continue
+		}
+		seen[svc.Checker] = true
+		if !checker.HasBackend(chk, svc.Checker) {
+			missing = append(missing, svc.Checker)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("checker backends referenced by services do not exist: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Prefetch passes all services used by the business processes to the checker
+// if it implements the 'checker.Prefetcher' interface. This allows the
+// checker to reduce the number of requests required.
+func (bps BusinessProcesses) Prefetch(ctx context.Context, chk checker.Checker) error {
+	p, ok := chk.(checker.Prefetcher)
+	if !ok {
+		return nil
+	}
+	return p.Prefetch(ctx, bps.Services())
+}
+
 func (bps BusinessProcesses) GenerateRecipientHashes(pepper string) map[string]string {
 	recipientList := make(map[string]struct{})
 	for _, bp := range bps {
@@ -110,13 +330,85 @@ type BP struct {
 	Name             string                      `yaml:"name"`
 	ID               string                      `yaml:"id"`
 	Kpis             []KPI                       `yaml:"kpis"`
+	Operation        string                      `yaml:"operation,omitempty"`
+	Unknown          UnknownPolicy               `yaml:"unknown,omitempty"`
+	Rules            rules.Rules                 `yaml:"rules,omitempty"`
 	AvailabilityName string                      `yaml:"availability"`
 	Availability     availabilities.Availability `yaml:"-"`
 	Responsible      string                      `yaml:"responsible"`
 	Recipients       []string                    `yaml:"recipients"`
+	SLO              *SLO                        `yaml:"slo,omitempty"`
+	Maintenance      maintenance.Windows         `yaml:"-"`
+}
+
+// DefaultOperation is the operation applied to the KPIs of a business
+// process which does not define an operation.
+const DefaultOperation = "AND"
+
+// operation returns the operation applied to the KPIs of the business
+// process.
+func (bp BP) operation() string {
+	if bp.Operation == "" {
+		return DefaultOperation
+	}
+	return bp.Operation
+}
+
+// Status evaluates all business processes. Each business process is evaluated
+// once, KPIs referencing a business process reuse its result.
+func (bps BusinessProcesses) Status(ctx context.Context, chk checker.Checker, pp store.Accessor, r rules.Rules) []store.ResultSet {
+	e := newEvaluation()
+	sets := make([]store.ResultSet, len(bps))
+	for i, bp := range bps {
+		sets[i] = e.status(ctx, bp, chk, pp, r)
+	}
+	return sets
+}
+
+// evaluation holds the results of the business processes evaluated during a
+// single run. This allows to evaluate each business process once even though
+// it is referenced by several KPIs.
+type evaluation struct {
+	mu      sync.Mutex
+	results map[string]*evaluated
+}
+
+type evaluated struct {
+	once sync.Once
+	rs   store.ResultSet
 }
 
-func (bp BP) Status(chk checker.Checker, pp store.Accessor, r rules.Rules) store.ResultSet {
+func newEvaluation() *evaluation {
+	return &evaluation{results: make(map[string]*evaluated)}
+}
+
+// status returns the result of the business process, it is evaluated only if
+// not evaluated before.
+func (e *evaluation) status(ctx context.Context, bp BP, chk checker.Checker, pp store.Accessor, r rules.Rules) store.ResultSet {
+	e.mu.Lock()
+	res, ok := e.results[bp.ID]
+	if !ok {
+		res = &evaluated{}
+		e.results[bp.ID] = res
+	}
+	e.mu.Unlock()
+
+	res.once.Do(func() {
+		res.rs = bp.status(ctx, chk, pp, r, e)
+	})
+	return res.rs
+}
+
+// Status evaluates the business process. Checks which are not completed
+// when the context is done are considered 'unknown'. The rules of the
+// business process, its KPIs and services override the rules 'r' provided.
+// Use 'BusinessProcesses.Status' to evaluate several business processes
+// which reference each other.
+func (bp BP) Status(ctx context.Context, chk checker.Checker, pp store.Accessor, r rules.Rules) store.ResultSet {
+	return newEvaluation().status(ctx, bp, chk, pp, r)
+}
+
+func (bp BP) status(ctx context.Context, chk checker.Checker, pp store.Accessor, r rules.Rules, e *evaluation) store.ResultSet {
 	rs := store.ResultSet{
 		Responsible: bp.Responsible,
 		Name:        bp.Name,
@@ -127,47 +419,100 @@ func (bp BP) Status(chk checker.Checker, pp store.Accessor, r rules.Rules) store
 	}
 
 	ch := make(chan *store.ResultSet)
-	var calcValues []bool
+	var calcValues []math.Value
+	var stati []status.Status
 	for _, k := range bp.Kpis {
 		if k.Responsible == "" {
 			k.Responsible = bp.Responsible
 		}
+		k.maintenance = bp.Maintenance
+		k.section = r
 		go func(k KPI, parentTags map[store.Kind]string, chk checker.Checker, pp store.Accessor, r rules.Rules) {
-			childRs := k.Status(rs.Tags, chk, pp, r)
+			childRs := k.status(ctx, rs.Tags, chk, pp, r, e)
 			ch <- &childRs
-		}(k, rs.Tags, chk, pp, r)
+		}(k, rs.Tags, chk, pp, r.Override(bp.Rules))
 	}
 
-	for {
+	for range bp.Kpis {
 		childRs := <-ch
-		calcValues = append(calcValues, childRs.Status.Bool())
+		calcValues = append(calcValues, bp.Unknown.value(childRs.Status, 1))
+		stati = append(stati, childRs.Status)
 		rs.Children = append(rs.Children, childRs)
-		if len(calcValues) == len(bp.Kpis) {
-			ch = nil
-		}
-		if ch == nil {
-			break
-		}
 	}
 
-	ok, _ := math.Calculate("AND", calcValues)
-	rs.Status = status.FromBool(ok)
+	res, err := math.Evaluate(bp.operation(), calcValues)
+	rs.Status = bp.Unknown.aggregate(res, stati)
 	rs.Was = status.StatusUnknown
 	rs.StatusChanged = false
 	rs.Start = time.Now()
 	rs.Vals["in_availability"] = bp.Availability.Contains(rs.Start)
+	rs.Vals[maintenance.Value] = bp.Maintenance.Active(rs.Tags, rs.Start)
+	if err != nil {
+		rs.Err = err
+		rs.Status = status.StatusUnknown
+	}
+	return rs
+}
+
+// reference returns the result of the business process as child of the KPI
+// referencing it. The business process is evaluated only if not evaluated
+// before during the same evaluation. The children of the business process
+// are not part of the result since they are already persisted when the
+// business process itself is evaluated.
+func (bp BP) reference(ctx context.Context, parentTags map[store.Kind]string, chk checker.Checker, pp store.Accessor, r rules.Rules, e *evaluation) store.ResultSet {
+	tags := make(map[store.Kind]string)
+	for k, v := range parentTags {
+		tags[k] = v
+	}
+	tags[store.KindReference] = bp.ID
+
+	nested := e.status(ctx, bp, chk, pp, r)
+	vals := make(map[string]bool)
+	for k, v := range nested.Vals {
+		vals[k] = v
+	}
+	rs := store.ResultSet{
+		Name:          bp.Name,
+		ID:            bp.ID,
+		Responsible:   bp.Responsible,
+		Start:         nested.Start,
+		Tags:          tags,
+		Vals:          vals,
+		Status:        nested.Status,
+		Was:           status.StatusUnknown,
+		StatusChanged: false,
+		Err:           nested.Err,
+	}
+	for _, childRs := range nested.Children {
+		if childRs.Status != status.StatusOK {
+			rs.AppendOutput(fmt.Sprintf("%s is %s", childRs.Name, childRs.Status))
+		}
+	}
 	return rs
 }
 
 type KPI struct {
-	Name        string    `yaml:"name"`
-	ID          string    `yaml:"id"`
-	Operation   string    `yaml:"operation"`
-	Services    []Service `yaml:"services"`
-	Responsible string    `yaml:"responsible"`
+	Name        string        `yaml:"name"`
+	ID          string        `yaml:"id"`
+	Operation   string        `yaml:"operation"`
+	Unknown     UnknownPolicy `yaml:"unknown,omitempty"`
+	Rules       rules.Rules   `yaml:"rules,omitempty"`
+	Services    []Service     `yaml:"services"`
+	BPs         []string      `yaml:"bps"`
+	Responsible string        `yaml:"responsible"`
+	processes   []*BP
+	maintenance maintenance.Windows
+	// section holds the rules of the configuration section which apply to
+	// the business processes referenced, regardless of the rules of the
+	// business process the KPI belongs to.
+	section rules.Rules
+}
+
+func (k KPI) Status(ctx context.Context, parentTags map[store.Kind]string, chk checker.Checker, pp store.Accessor, r rules.Rules) store.ResultSet {
+	return k.status(ctx, parentTags, chk, pp, r, newEvaluation())
 }
 
-func (k KPI) Status(parentTags map[store.Kind]string, chk checker.Checker, pp store.Accessor, r rules.Rules) store.ResultSet {
+func (k KPI) status(ctx context.Context, parentTags map[store.Kind]string, chk checker.Checker, pp store.Accessor, r rules.Rules, e *evaluation) store.ResultSet {
 	tags := make(map[store.Kind]string)
 	for k, v := range parentTags {
 		tags[k] = v
@@ -183,35 +528,51 @@ func (k KPI) Status(parentTags map[store.Kind]string, chk checker.Checker, pp st
 		Tags:        tags,
 	}
 
-	ch := make(chan *store.ResultSet)
-	var calcValues []bool
+	type child struct {
+		rs     *store.ResultSet
+		weight float64
+	}
+	section := k.section
+	if section == nil {
+		section = r
+	}
+
+	ch := make(chan child)
+	var calcValues []math.Value
+	var stati []status.Status
 	for _, s := range k.Services {
 		if s.Responsible == "" {
 			s.Responsible = k.Responsible
 		}
+		s.maintenance = k.maintenance
 		go func(s Service, parentTags map[store.Kind]string, chk checker.Checker, pp store.Accessor, r rules.Rules) {
-			childRs := s.Status(rs.Tags, chk, pp, r)
-			ch <- &childRs
-		}(s, rs.Tags, chk, pp, r)
+			childRs := s.Status(ctx, rs.Tags, chk, pp, r)
+			ch <- child{rs: &childRs, weight: s.weight()}
+		}(s, rs.Tags, chk, pp, r.Override(k.Rules))
+	}
+	for _, p := range k.processes {
+		go func(p *BP, parentTags map[store.Kind]string, chk checker.Checker, pp store.Accessor, r rules.Rules) {
+			childRs := p.reference(ctx, parentTags, chk, pp, r, e)
+			if childRs.Responsible == "" {
+				childRs.Responsible = k.Responsible
+			}
+			ch <- child{rs: &childRs, weight: 1}
+		}(p, rs.Tags, chk, pp, section)
 	}
 
-	for {
-		childRs := <-ch
-		calcValues = append(calcValues, childRs.Status.Bool())
-		rs.Children = append(rs.Children, childRs)
-		if len(calcValues) == len(k.Services) {
-			ch = nil
-		}
-		if ch == nil {
-			break
-		}
+	for i := 0; i < len(k.Services)+len(k.processes); i++ {
+		c := <-ch
+		calcValues = append(calcValues, k.Unknown.value(c.rs.Status, c.weight))
+		stati = append(stati, c.rs.Status)
+		rs.Children = append(rs.Children, c.rs)
 	}
 
-	ok, err := math.Calculate(k.Operation, calcValues)
-	rs.Status = status.FromBool(ok)
+	res, err := math.Evaluate(k.Operation, calcValues)
+	rs.Status = k.Unknown.aggregate(res, stati)
 	rs.Was = status.StatusUnknown
 	rs.StatusChanged = false
 	rs.Start = time.Now()
+	rs.Vals[maintenance.Value] = k.maintenance.Active(rs.Tags, rs.Start)
 	if err != nil {
 		rs.Err = err
 		rs.Status = status.StatusUnknown
@@ -219,13 +580,45 @@ func (k KPI) Status(parentTags map[store.Kind]string, chk checker.Checker, pp st
 	return rs
 }
 
+// values returns a copy of the values of a checker result extended by the
+// value 'maintenance' at the time of the evaluation 't', which may be later
+// than the time the checker has checked the service. The values may be shared
+// with other services by the checker and must therefore not be modified.
+func values(vals map[string]bool, windows maintenance.Windows, tags map[store.Kind]string, t time.Time) map[string]bool {
+	out := make(map[string]bool)
+	for k, v := range vals {
+		out[k] = v
+	}
+	out[maintenance.Value] = windows.Active(tags, t)
+	return out
+}
+
 type Service struct {
 	Host        string `yaml:"host"`
 	Service     string `yaml:"service"`
+	Checker     string `yaml:"checker"`
 	Responsible string `yaml:"responsible"`
+	// Weight is the weight of the service in weighted operations of its
+	// KPI. If not set, a weight of 1 is assumed. A weight of 0 excludes the
+	// service from weighted operations.
+	Weight      *float64    `yaml:"weight,omitempty"`
+	Rules       rules.Rules `yaml:"rules,omitempty"`
+	maintenance maintenance.Windows
 }
 
-func (s Service) Status(parentTags map[store.Kind]string, chk checker.Checker, pp store.Accessor, r rules.Rules) store.ResultSet {
+// weight returns the weight of the service in weighted operations.
+func (s Service) weight() float64 {
+	if s.Weight == nil {
+		return 1
+	}
+	return *s.Weight
+}
+
+// Status checks the service. If the context is done before the checker
+// returns, the status is 'unknown' and 'Err' explains why. The values of the
+// checker are extended with the value 'maintenance' before the rules are
+// applied. The rules of the service override the rules 'r' provided.
+func (s Service) Status(ctx context.Context, parentTags map[store.Kind]string, chk checker.Checker, pp store.Accessor, r rules.Rules) store.ResultSet {
 	name := fmt.Sprintf("%s!%s", s.Host, s.Service)
 
 	tags := make(map[store.Kind]string)
@@ -240,14 +633,30 @@ func (s Service) Status(parentTags map[store.Kind]string, chk checker.Checker, p
 		ID:          name,
 		Tags:        tags,
 	}
-	result := chk.Status(s.Host, s.Service)
-	rs.Err = result.Error
-	rs.Start = result.Timestamp
-	rs.AppendOutput(result.Message)
-	rs.Vals = result.Values
-	st, _ := r.Analyze(result.Values)
-	rs.Status = st
 	rs.Was = status.StatusUnknown
 	rs.StatusChanged = false
+
+	results := make(chan checker.Result, 1)
+	go func() {
+		results <- checker.StatusOf(ctx, chk, s.Checker, s.Host, s.Service)
+	}()
+
+	select {
+	case result := <-results:
+		rs.Err = result.Error
+		rs.Start = result.Timestamp
+		rs.AppendOutput(result.Message)
+		rs.Vals = values(result.Values, s.maintenance, rs.Tags, time.Now())
+		st, match, err := r.Override(s.Rules).Analyze(rs.Vals)
+		rs.Status = st
+		if err == nil {
+			rs.Rule = match.String()
+		}
+	case <-ctx.Done():
+		rs.Err = fmt.Errorf("check was not completed in time: %s", ctx.Err().Error())
+		rs.Start = time.Now()
+		rs.Vals = map[string]bool{maintenance.Value: s.maintenance.Active(rs.Tags, rs.Start)}
+		rs.Status = status.StatusUnknown
+	}
 	return rs
 }
@@ -1,10 +1,14 @@
 package bpmon
 
 import (
+	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/unprofession-al/bpmon/internal/availabilities"
+	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/maintenance"
 	"github.com/unprofession-al/bpmon/internal/status"
 	"github.com/unprofession-al/bpmon/internal/store"
 )
@@ -14,14 +18,20 @@ type bpTestSet struct {
 	status status.Status
 }
 
+func weightOf(w float64) *float64 {
+	return &w
+}
+
 var allDayLong = availabilities.Availability{
-	time.Monday:    availabilities.AvailabilityTime{AllDay: true},
-	time.Tuesday:   availabilities.AvailabilityTime{AllDay: true},
-	time.Wednesday: availabilities.AvailabilityTime{AllDay: true},
-	time.Thursday:  availabilities.AvailabilityTime{AllDay: true},
-	time.Friday:    availabilities.AvailabilityTime{AllDay: true},
-	time.Saturday:  availabilities.AvailabilityTime{AllDay: true},
-	time.Sunday:    availabilities.AvailabilityTime{AllDay: true},
+	Days: map[time.Weekday]availabilities.AvailabilityTime{
+		time.Monday:    availabilities.AvailabilityTime{AllDay: true},
+		time.Tuesday:   availabilities.AvailabilityTime{AllDay: true},
+		time.Wednesday: availabilities.AvailabilityTime{AllDay: true},
+		time.Thursday:  availabilities.AvailabilityTime{AllDay: true},
+		time.Friday:    availabilities.AvailabilityTime{AllDay: true},
+		time.Saturday:  availabilities.AvailabilityTime{AllDay: true},
+		time.Sunday:    availabilities.AvailabilityTime{AllDay: true},
+	},
 }
 
 var BpTestSets = []bpTestSet{
@@ -43,13 +53,108 @@ var BpTestSets = []bpTestSet{
 		},
 		status: status.StatusOK,
 	},
+	{
+		bp: BP{
+			Name:         "WeightedBP",
+			ID:           "weighted_bp",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{
+					Name:      "WeightedKPI",
+					ID:        "weighted_kpi",
+					Operation: "WEIGHTED 3",
+					Services: []Service{
+						{Host: "Host1", Service: "good", Weight: weightOf(2)},
+						{Host: "Host2", Service: "good"},
+						{Host: "Host3", Service: "bad", Weight: weightOf(5)},
+					},
+				},
+			},
+		},
+		status: status.StatusDegraded,
+	},
+	{
+		bp: BP{
+			Name:         "UnderweightBP",
+			ID:           "underweight_bp",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{
+					Name:      "UnderweightKPI",
+					ID:        "underweight_kpi",
+					Operation: "WEIGHTED 3",
+					Services: []Service{
+						{Host: "Host1", Service: "good", Weight: weightOf(1.5)},
+						{Host: "Host2", Service: "bad", Weight: weightOf(5)},
+					},
+				},
+			},
+		},
+		status: status.StatusNOK,
+	},
+	{
+		bp: BP{
+			Name:         "WeightlessBP",
+			ID:           "weightless_bp",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{
+					Name:      "WeightlessKPI",
+					ID:        "weightless_kpi",
+					Operation: "WEIGHTED 1",
+					Services: []Service{
+						{Host: "Host1", Service: "good", Weight: weightOf(0)},
+						{Host: "Host2", Service: "bad"},
+					},
+				},
+			},
+		},
+		status: status.StatusNOK,
+	},
+	{
+		bp: BP{
+			Name:         "RedundantBP",
+			ID:           "redundant_bp",
+			Operation:    "MIN 1",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{ID: "primary", Operation: "AND", Services: []Service{{Host: "Host1", Service: "bad"}}},
+				{ID: "secondary", Operation: "AND", Services: []Service{{Host: "Host2", Service: "good"}}},
+			},
+		},
+		status: status.StatusDegraded,
+	},
+	{
+		bp: BP{
+			Name:         "DefaultOperationBP",
+			ID:           "default_operation_bp",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{ID: "primary", Operation: "AND", Services: []Service{{Host: "Host1", Service: "bad"}}},
+				{ID: "secondary", Operation: "AND", Services: []Service{{Host: "Host2", Service: "good"}}},
+			},
+		},
+		status: status.StatusNOK,
+	},
+	{
+		bp: BP{
+			Name:         "WarningBP",
+			ID:           "warning_bp",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{ID: "db", Operation: "AND", Services: []Service{{Host: "Host1", Service: "good"}, {Host: "Host2", Service: "warn"}}},
+				{ID: "web", Operation: "AND", Services: []Service{{Host: "Host3", Service: "good"}}},
+			},
+		},
+		status: status.StatusDegraded,
+	},
 }
 
 func TestBusinessProcess(t *testing.T) {
 	chk := CheckerMock{}
 	pp := StoreMock{}
 	for _, bp := range BpTestSets {
-		rs := bp.bp.Status(chk, pp, chk.DefaultRules())
+		rs := bp.bp.Status(context.Background(), chk, pp, chk.DefaultRules())
 		if rs.Status != bp.status {
 			t.Errorf("Expected status to be '%s', got '%s'", bp.status, rs.Status)
 		}
@@ -90,7 +195,7 @@ func TestServices(t *testing.T) {
 	chk := CheckerMock{}
 	parentTags := map[store.Kind]string{store.KindBusinessProcess: "BP", store.KindKeyPerformanceIndicator: "KPI"}
 	for _, s := range SvcTestSets {
-		rs := s.svc.Status(parentTags, chk, pp, chk.DefaultRules())
+		rs := s.svc.Status(context.Background(), parentTags, chk, pp, chk.DefaultRules())
 		if s.errExpected && rs.Err == nil {
 			t.Errorf("Error expected but got nil")
 		} else if !s.errExpected && rs.Err != nil {
@@ -101,3 +206,415 @@ func TestServices(t *testing.T) {
 		}
 	}
 }
+
+func TestValidate(t *testing.T) {
+	svc := []Service{{Host: "Host", Service: "good"}}
+	tests := map[string]struct {
+		bps         BusinessProcesses
+		errExpected bool
+	}{
+		"valid": {
+			bps: BusinessProcesses{
+				{ID: "a", Kpis: []KPI{{ID: "k1", Operation: "AND", Services: svc}, {ID: "k2", Operation: "MIN 1", Services: svc}}},
+				{ID: "b", Kpis: []KPI{{ID: "k1", Operation: "OR", Services: svc}}},
+			},
+			errExpected: false,
+		},
+		"duplicate bp id": {
+			bps:         BusinessProcesses{{ID: "a"}, {ID: "a"}},
+			errExpected: true,
+		},
+		"empty bp id": {
+			bps:         BusinessProcesses{{Name: "A"}},
+			errExpected: true,
+		},
+		"duplicate kpi id": {
+			bps:         BusinessProcesses{{ID: "a", Kpis: []KPI{{ID: "k", Operation: "AND"}, {ID: "k", Operation: "AND"}}}},
+			errExpected: true,
+		},
+		"invalid operation": {
+			bps:         BusinessProcesses{{ID: "a", Kpis: []KPI{{ID: "k", Operation: "SOME"}}}},
+			errExpected: true,
+		},
+		"valid slo": {
+			bps:         BusinessProcesses{{ID: "a", SLO: &SLO{Target: 99.9}}},
+			errExpected: false,
+		},
+		"invalid slo target": {
+			bps:         BusinessProcesses{{ID: "a", SLO: &SLO{Target: 100}}},
+			errExpected: true,
+		},
+		"negative slo window": {
+			bps:         BusinessProcesses{{ID: "a", SLO: &SLO{Target: 99, Window: Window(-time.Hour)}}},
+			errExpected: true,
+		},
+		"invalid bp operation": {
+			bps:         BusinessProcesses{{ID: "a", Operation: "SOME"}},
+			errExpected: true,
+		},
+		"invalid unknown policy": {
+			bps:         BusinessProcesses{{ID: "a", Kpis: []KPI{{ID: "k", Operation: "AND", Unknown: "maybe"}}}},
+			errExpected: true,
+		},
+		"negative weight": {
+			bps:         BusinessProcesses{{ID: "a", Kpis: []KPI{{ID: "k", Operation: "WEIGHTED 1", Services: []Service{{Host: "Host", Service: "good", Weight: weightOf(-1)}}}}}},
+			errExpected: true,
+		},
+		"empty service": {
+			bps:         BusinessProcesses{{ID: "a", Kpis: []KPI{{ID: "k", Operation: "AND", Services: []Service{{Host: "Host"}}}}}},
+			errExpected: true,
+		},
+	}
+
+	for name, test := range tests {
+		errs, err := test.bps.Validate()
+		if test.errExpected && err == nil {
+			t.Errorf("Error expected for '%s' but got nil", name)
+		} else if !test.errExpected && err != nil {
+			t.Errorf("No error expected for '%s' but got error: %s %v", name, err.Error(), errs)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := map[string]struct {
+		bps         BusinessProcesses
+		errExpected bool
+	}{
+		"nested": {
+			bps: BusinessProcesses{
+				{ID: "a", Kpis: []KPI{{ID: "k", BPs: []string{"b", "c"}}}},
+				{ID: "b", Kpis: []KPI{{ID: "k", BPs: []string{"c"}}}},
+				{ID: "c"},
+			},
+			errExpected: false,
+		},
+		"unknown reference": {
+			bps: BusinessProcesses{
+				{ID: "a", Kpis: []KPI{{ID: "k", BPs: []string{"x"}}}},
+			},
+			errExpected: true,
+		},
+		"self reference": {
+			bps: BusinessProcesses{
+				{ID: "a", Kpis: []KPI{{ID: "k", BPs: []string{"a"}}}},
+			},
+			errExpected: true,
+		},
+		"cycle": {
+			bps: BusinessProcesses{
+				{ID: "a", Kpis: []KPI{{ID: "k", BPs: []string{"b"}}}},
+				{ID: "b", Kpis: []KPI{{ID: "k", BPs: []string{"c"}}}},
+				{ID: "c", Kpis: []KPI{{ID: "k", BPs: []string{"a"}}}},
+			},
+			errExpected: true,
+		},
+	}
+
+	for name, test := range tests {
+		err := test.bps.resolve()
+		if test.errExpected && err == nil {
+			t.Errorf("Error expected for '%s' but got nil", name)
+		} else if !test.errExpected && err != nil {
+			t.Errorf("No error expected for '%s' but got error: %s", name, err.Error())
+		}
+	}
+}
+
+func TestNestedBusinessProcess(t *testing.T) {
+	chk := CheckerMock{}
+	pp := StoreMock{}
+	bps := BusinessProcesses{
+		{
+			ID:           "checkout",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{ID: "payments", Operation: "AND", BPs: []string{"payments"}},
+			},
+		},
+		{
+			ID:           "payments",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{ID: "psp", Operation: "AND", Services: []Service{{Host: "Host", Service: "bad"}}},
+			},
+		},
+	}
+	err := bps.resolve()
+	if err != nil {
+		t.Fatalf("No error expected but got error: %s", err.Error())
+	}
+
+	rs := bps[0].Status(context.Background(), chk, pp, chk.DefaultRules())
+	if rs.Status != status.StatusNOK {
+		t.Errorf("Expected status to be '%s', got '%s'", status.StatusNOK, rs.Status)
+	}
+
+	ref := rs.Children[0].Children[0]
+	if ref.Kind() != store.KindReference {
+		t.Errorf("Expected kind of nested business process to be '%s', got '%s'", store.KindReference, ref.Kind())
+	}
+	if ref.Tags[store.KindBusinessProcess] != "checkout" || ref.Tags[store.KindReference] != "payments" {
+		t.Errorf("Tags of nested business process are not as expected: %v", ref.Tags)
+	}
+	if len(ref.Children) != 0 {
+		t.Errorf("Nested business process must not contain children, got %d", len(ref.Children))
+	}
+}
+
+// countingChecker counts the services checked.
+type countingChecker struct {
+	CheckerMock
+	checked *int32
+}
+
+func (chk countingChecker) Status(ctx context.Context, host, service string) checker.Result {
+	atomic.AddInt32(chk.checked, 1)
+	return chk.CheckerMock.Status(ctx, host, service)
+}
+
+func TestNestedBusinessProcessEvaluatedOnce(t *testing.T) {
+	var checked int32
+	chk := countingChecker{checked: &checked}
+	pp := StoreMock{}
+	ref := func(id string, refs ...string) BP {
+		return BP{ID: id, Availability: allDayLong, Kpis: []KPI{{ID: "refs", Operation: "AND", BPs: refs}}}
+	}
+	bps := BusinessProcesses{
+		ref("top", "left", "right"),
+		ref("left", "base"),
+		ref("right", "base"),
+		{
+			ID:           "base",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{ID: "svc", Operation: "AND", Services: []Service{{Host: "Host", Service: "bad"}}},
+			},
+		},
+	}
+	err := bps.resolve()
+	if err != nil {
+		t.Fatalf("No error expected but got error: %s", err.Error())
+	}
+
+	sets := bps.Status(context.Background(), chk, pp, chk.DefaultRules())
+	if checked != 1 {
+		t.Errorf("Expected service to be checked once, got %d", checked)
+	}
+	if len(sets) != len(bps) {
+		t.Fatalf("Expected %d results, got %d", len(bps), len(sets))
+	}
+	for i, rs := range sets {
+		if rs.ID != bps[i].ID || rs.Status != status.StatusNOK {
+			t.Errorf("Expected '%s' to be '%s', got '%s' with status '%s'", bps[i].ID, status.StatusNOK, rs.ID, rs.Status)
+		}
+	}
+	base := sets[3]
+	for _, nested := range sets[1].Children[0].Children {
+		if nested.Start != base.Start || nested.Status != base.Status {
+			t.Errorf("Expected referenced business process to match its result, got '%s' at %s vs. '%s' at %s", nested.Status, nested.Start, base.Status, base.Start)
+		}
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	chk := CheckerMock{}
+	pp := StoreMock{}
+	bp := BP{
+		ID:           "test_bp",
+		Availability: allDayLong,
+		Kpis: []KPI{
+			{
+				ID:        "test_kpi",
+				Operation: "AND",
+				Services: []Service{
+					{Host: "Host", Service: "good"},
+					{Host: "Host", Service: "hang"},
+				},
+			},
+			{ID: "empty_kpi", Operation: "AND"},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan store.ResultSet)
+	go func() {
+		done <- bp.Status(ctx, chk, pp, chk.DefaultRules())
+	}()
+
+	var rs store.ResultSet
+	select {
+	case rs = <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Evaluation did not return after the deadline")
+	}
+
+	for _, kpi := range rs.Children {
+		if kpi.ID != "test_kpi" {
+			continue
+		}
+		for _, svc := range kpi.Children {
+			switch svc.ID {
+			case "Host!good":
+				if svc.Status != status.StatusOK || svc.Err != nil {
+					t.Errorf("Expected completed service to be '%s' without error, got '%s' (%v)", status.StatusOK, svc.Status, svc.Err)
+				}
+			case "Host!hang":
+				if svc.Status != status.StatusUnknown || svc.Err == nil {
+					t.Errorf("Expected service exceeding the deadline to be '%s' with error, got '%s' (%v)", status.StatusUnknown, svc.Status, svc.Err)
+				}
+			}
+		}
+	}
+}
+
+func TestValidateCheckers(t *testing.T) {
+	chk := CheckerMock{}
+	bps := BusinessProcesses{
+		{
+			ID: "test_bp",
+			Kpis: []KPI{
+				{
+					ID:        "test_kpi",
+					Operation: "AND",
+					Services: []Service{
+						{Host: "Host", Service: "good"},
+						{Host: "Host", Service: "good", Checker: "dc2"},
+					},
+				},
+			},
+		},
+	}
+
+	err := bps.ValidateCheckers(chk)
+	if err == nil {
+		t.Errorf("Error expected for a backend not provided by the checker but got nil")
+	}
+
+	rs := bps[0].Kpis[0].Services[1].Status(context.Background(), map[store.Kind]string{}, chk, StoreMock{}, chk.DefaultRules())
+	if rs.Status != status.StatusUnknown || rs.Err == nil {
+		t.Errorf("Expected service of a nonexistent backend to be '%s' with error, got '%s' (%v)", status.StatusUnknown, rs.Status, rs.Err)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	chk := CheckerMock{}
+	pp := StoreMock{}
+	r := chk.DefaultRules()
+	r.Merge(maintenance.DefaultRules())
+
+	bps := BusinessProcesses{
+		{
+			ID:           "app",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{ID: "db", Operation: "AND", Services: []Service{{Host: "db1", Service: "bad"}}},
+				{ID: "web", Operation: "AND", Services: []Service{{Host: "web1", Service: "good"}}},
+			},
+		},
+	}
+
+	now := time.Now()
+	tests := map[string]struct {
+		windows     maintenance.Windows
+		status      status.Status
+		bp          bool
+		errExpected bool
+	}{
+		"none": {
+			windows: maintenance.Windows{},
+			status:  status.StatusNOK,
+		},
+		"expired": {
+			windows: maintenance.Windows{{ID: "m", BP: "app", Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)}},
+			status:  status.StatusNOK,
+		},
+		"kpi": {
+			windows: maintenance.Windows{{ID: "m", BP: "app", KPI: "db", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+			status:  status.StatusOK,
+		},
+		"service": {
+			windows: maintenance.Windows{{ID: "m", Service: "db1!bad", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+			status:  status.StatusOK,
+		},
+		"business process": {
+			windows: maintenance.Windows{{ID: "m", BP: "app", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+			status:  status.StatusOK,
+			bp:      true,
+		},
+		"unknown kpi": {
+			windows:     maintenance.Windows{{ID: "m", BP: "app", KPI: "cache", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+			errExpected: true,
+		},
+		"unknown business process": {
+			windows:     maintenance.Windows{{ID: "m", BP: "shop", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+			errExpected: true,
+		},
+	}
+
+	for name, test := range tests {
+		err := bps.Maintain(test.windows)
+		if test.errExpected && err == nil {
+			t.Errorf("Error expected for '%s' but got nil", name)
+			continue
+		} else if !test.errExpected && err != nil {
+			t.Errorf("No error expected for '%s' but got error: %s", name, err.Error())
+			continue
+		} else if err != nil {
+			continue
+		}
+
+		rs := bps[0].Status(context.Background(), chk, pp, r)
+		if rs.Status != test.status {
+			t.Errorf("Expected status of '%s' to be '%s', got '%s'", name, test.status, rs.Status)
+		}
+		if rs.Vals[maintenance.Value] != test.bp {
+			t.Errorf("Expected value '%s' of business process in '%s' to be %t, got %t", maintenance.Value, name, test.bp, rs.Vals[maintenance.Value])
+		}
+		for _, kpi := range rs.Children {
+			for _, svc := range kpi.Children {
+				if _, ok := svc.Vals[maintenance.Value]; !ok {
+					t.Errorf("Value '%s' of service %s in '%s' is missing", maintenance.Value, svc.Name, name)
+				}
+			}
+		}
+	}
+}
+
+func TestMaintenanceOfStaleResult(t *testing.T) {
+	chk := CheckerMock{}
+	r := chk.DefaultRules()
+	r.Merge(maintenance.DefaultRules())
+
+	bps := BusinessProcesses{
+		{
+			ID:           "app",
+			Availability: allDayLong,
+			Kpis:         []KPI{{ID: "db", Operation: "AND", Services: []Service{{Host: "db1", Service: "stale"}}}},
+		},
+	}
+	now := time.Now()
+	err := bps.Maintain(maintenance.Windows{{ID: "m", Service: "db1!stale", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}})
+	if err != nil {
+		t.Fatalf("Could not set up maintenance: %s", err.Error())
+	}
+
+	rs := *bps[0].Status(context.Background(), chk, StoreMock{}, r).Children[0].Children[0]
+	if !rs.Vals[maintenance.Value] || rs.Status != status.StatusOK {
+		t.Errorf("Expected service checked before the maintenance started to be under maintenance, got '%s' with values %v", rs.Status, rs.Vals)
+	}
+	if !rs.Start.Before(now.Add(-time.Hour)) {
+		t.Errorf("Expected start of service to be the time of the check, got %s", rs.Start)
+	}
+
+	services := bps.FindService("db1!stale", r)
+	if len(services) != 1 {
+		t.Fatalf("Expected service to be found once, got %d", len(services))
+	}
+	result := chk.Status(context.Background(), "db1", "stale")
+	if !services[0].Values(result, now)[maintenance.Value] {
+		t.Errorf("Expected values of service checked before the maintenance started to be under maintenance")
+	}
+}
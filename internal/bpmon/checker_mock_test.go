@@ -1,6 +1,7 @@
 package bpmon
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -11,7 +12,7 @@ import (
 
 type CheckerMock struct{}
 
-func (chk CheckerMock) Status(host, service string) checker.Result {
+func (chk CheckerMock) Status(ctx context.Context, host, service string) checker.Result {
 	out := checker.Result{
 		Timestamp: time.Now(),
 	}
@@ -19,6 +20,7 @@ func (chk CheckerMock) Status(host, service string) checker.Result {
 	out.Values = map[string]bool{
 		"good":    false,
 		"bad":     false,
+		"warn":    false,
 		"error":   false,
 		"unknown": false,
 	}
@@ -28,9 +30,18 @@ func (chk CheckerMock) Status(host, service string) checker.Result {
 		out.Values["good"] = true
 	case "bad":
 		out.Values["bad"] = true
+	case "stale":
+		// simulate a result of a check run long before the request
+		out.Timestamp = out.Timestamp.Add(-2 * time.Hour)
+		out.Values["bad"] = true
+	case "warn":
+		out.Values["warn"] = true
 	case "error":
 		out.Values["error"] = true
 		out.Error = errors.New("Error occurred")
+	case "hang":
+		// simulate a checker that does not respect the context
+		select {}
 	default:
 		out.Values["unknown"] = true
 	}
@@ -38,7 +49,7 @@ func (chk CheckerMock) Status(host, service string) checker.Result {
 }
 
 func (chk CheckerMock) Values() []string {
-	return []string{"good", "bad", "unknown", "error"}
+	return []string{"good", "bad", "warn", "unknown", "error"}
 }
 
 func (chk CheckerMock) Health() (string, error) {
@@ -57,6 +68,11 @@ func (chk CheckerMock) DefaultRules() rules.Rules {
 			MustNot: []string{},
 			Then:    status.StatusUnknown,
 		},
+		30: rules.Rule{
+			Must:    []string{"warn"},
+			MustNot: []string{},
+			Then:    status.StatusDegraded,
+		},
 		9999: rules.Rule{
 			Must:    []string{},
 			MustNot: []string{},
@@ -0,0 +1,118 @@
+package bpmon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/maintenance"
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// ValidateRules checks the rules defined by the business processes, their
+// KPIs and services for errors, see 'rules.Rules.Validate'. 'values' lists
+// the values available to the rules.
+func (bps BusinessProcesses) ValidateRules(values []string) ([]string, error) {
+	errs := []string{}
+	add := func(scope string, r rules.Rules) {
+		msgs, _ := r.Validate(values)
+		for _, msg := range msgs {
+			errs = append(errs, fmt.Sprintf("Rules of %s are invalid: %s", scope, msg))
+		}
+	}
+	for _, bp := range bps {
+		add(fmt.Sprintf("business process '%s'", bp.ID), bp.Rules)
+		for _, k := range bp.Kpis {
+			add(fmt.Sprintf("KPI '%s' in business process '%s'", k.ID, bp.ID), k.Rules)
+			for _, s := range k.Services {
+				add(fmt.Sprintf("service '%s!%s' in KPI '%s' in business process '%s'", s.Host, s.Service, k.ID, bp.ID), s.Rules)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs, errors.New("rules of business processes have errors")
+	}
+	return errs, nil
+}
+
+// EffectiveRules holds the rules which apply to a business process, a KPI or
+// a service once merged with the rules of its parents.
+type EffectiveRules struct {
+	// Rules are the rules which apply.
+	Rules rules.Rules `yaml:"rules"`
+
+	// KPIs holds the rules of the KPIs which differ from the rules of the
+	// business process, by ID.
+	KPIs map[string]EffectiveRules `yaml:"kpis,omitempty"`
+
+	// Services holds the rules of the services which differ from the rules
+	// of the KPI, by name as in 'host!service'.
+	Services map[string]EffectiveRules `yaml:"services,omitempty"`
+}
+
+// EffectiveRules returns the rules which apply to the business process, its
+// KPIs and services given the rules 'r' of the configuration section.
+func (bp BP) EffectiveRules(r rules.Rules) EffectiveRules {
+	out := EffectiveRules{Rules: r.Override(bp.Rules), KPIs: map[string]EffectiveRules{}}
+	for _, k := range bp.Kpis {
+		kpi := EffectiveRules{Rules: out.Rules.Override(k.Rules), Services: map[string]EffectiveRules{}}
+		for _, s := range k.Services {
+			if len(s.Rules) > 0 {
+				kpi.Services[fmt.Sprintf("%s!%s", s.Host, s.Service)] = EffectiveRules{Rules: kpi.Rules.Override(s.Rules)}
+			}
+		}
+		if len(k.Rules) > 0 || len(kpi.Services) > 0 {
+			out.KPIs[k.ID] = kpi
+		}
+	}
+	return out
+}
+
+// ServiceRules is a service as defined in a KPI of a business process along
+// with the rules which apply to it.
+type ServiceRules struct {
+	BP      string
+	KPI     string
+	Service Service
+	Rules   rules.Rules
+
+	maintenance maintenance.Windows
+}
+
+// Values returns the values of the checker result as seen by the rules when
+// evaluated at 'at', extended by the value 'maintenance'.
+func (sr ServiceRules) Values(result checker.Result, at time.Time) map[string]bool {
+	tags := map[store.Kind]string{
+		store.KindBusinessProcess:         sr.BP,
+		store.KindKeyPerformanceIndicator: sr.KPI,
+		store.KindService:                 fmt.Sprintf("%s!%s", sr.Service.Host, sr.Service.Service),
+	}
+	return values(result.Values, sr.maintenance, tags, at)
+}
+
+// FindService returns the service 'name', formated as in 'host!service',
+// for each KPI it is defined in. 'r' are the rules of the configuration
+// section, which are overridden by the rules of the business process, the
+// KPI and the service.
+func (bps BusinessProcesses) FindService(name string, r rules.Rules) []ServiceRules {
+	var out []ServiceRules
+	for _, bp := range bps {
+		for _, k := range bp.Kpis {
+			for _, s := range k.Services {
+				if fmt.Sprintf("%s!%s", s.Host, s.Service) != name {
+					continue
+				}
+				out = append(out, ServiceRules{
+					BP:          bp.ID,
+					KPI:         k.ID,
+					Service:     s,
+					Rules:       r.Override(bp.Rules).Override(k.Rules).Override(s.Rules),
+					maintenance: bp.Maintenance,
+				})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package bpmon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/status"
+)
+
+func TestRuleOverrides(t *testing.T) {
+	chk := CheckerMock{}
+	pp := StoreMock{}
+
+	warnIsNOK := rules.Rules{25: rules.Rule{Must: []string{"warn"}, MustNot: []string{}, Then: status.StatusNOK}}
+	warnIsOK := rules.Rules{25: rules.Rule{Must: []string{"warn"}, MustNot: []string{}, Then: status.StatusOK}}
+
+	bps := BusinessProcesses{
+		{
+			ID:           "strict",
+			Availability: allDayLong,
+			Rules:        warnIsNOK,
+			Kpis: []KPI{
+				{ID: "db", Operation: "AND", Services: []Service{{Host: "Host1", Service: "warn"}}},
+				{ID: "web", Operation: "AND", Rules: warnIsOK, Services: []Service{
+					{Host: "Host2", Service: "warn"},
+					{Host: "Host3", Service: "warn", Rules: warnIsNOK},
+				}},
+				{ID: "shop", Operation: "AND", BPs: []string{"lenient"}},
+			},
+		},
+		{
+			ID:           "lenient",
+			Availability: allDayLong,
+			Kpis: []KPI{
+				{ID: "db", Operation: "AND", Services: []Service{{Host: "Host1", Service: "warn"}}},
+			},
+		},
+	}
+	if err := bps.resolve(); err != nil {
+		t.Fatalf("No error expected but got error: %s", err.Error())
+	}
+
+	rs := bps[0].Status(context.Background(), chk, pp, chk.DefaultRules())
+	expected := map[string]status.Status{
+		"db":         status.StatusNOK,
+		"web":        status.StatusNOK,
+		"shop":       status.StatusDegraded,
+		"Host2!warn": status.StatusOK,
+		"Host3!warn": status.StatusNOK,
+	}
+	for _, kpi := range rs.Children {
+		if st, ok := expected[kpi.ID]; ok && kpi.Status != st {
+			t.Errorf("Expected status of KPI '%s' to be '%s', got '%s'", kpi.ID, st, kpi.Status)
+		}
+		for _, svc := range kpi.Children {
+			if st, ok := expected[svc.ID]; ok && svc.Status != st {
+				t.Errorf("Expected status of service '%s' to be '%s', got '%s'", svc.ID, st, svc.Status)
+			}
+		}
+	}
+}
+
+func TestEffectiveRules(t *testing.T) {
+	section := rules.Rules{10: rules.Rule{Must: []string{"bad"}, Then: status.StatusNOK}}
+	override := rules.Rules{10: rules.Rule{Must: []string{"bad"}, Then: status.StatusDegraded}}
+
+	bp := BP{
+		ID: "bp",
+		Kpis: []KPI{
+			{ID: "plain", Services: []Service{{Host: "Host", Service: "svc"}}},
+			{ID: "special", Services: []Service{{Host: "Host", Service: "svc", Rules: override}}},
+		},
+	}
+
+	out := bp.EffectiveRules(section)
+	if out.Rules[10].Then != status.StatusNOK {
+		t.Errorf("Expected rules of business process to be the rules of the section, got %v", out.Rules)
+	}
+	if _, ok := out.KPIs["plain"]; ok {
+		t.Errorf("KPI without rules must not be listed, got %v", out.KPIs)
+	}
+	svc, ok := out.KPIs["special"].Services["Host!svc"]
+	if !ok || svc.Rules[10].Then != status.StatusDegraded {
+		t.Errorf("Expected overridden rules of service, got %v", out.KPIs)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	bps := BusinessProcesses{
+		{ID: "a", Rules: rules.Rules{10: rules.Rule{Must: []string{"bad"}}}, Kpis: []KPI{
+			{ID: "k", Services: []Service{{Host: "Host", Service: "svc", Rules: rules.Rules{10: rules.Rule{Expression: "bad or worse"}}}}},
+		}},
+	}
+
+	errs, err := bps.ValidateRules(CheckerMock{}.Values())
+	if err == nil || len(errs) != 1 {
+		t.Errorf("Expected 1 error, got %d: %v", len(errs), errs)
+	}
+}
@@ -0,0 +1,100 @@
+package bpmon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSLOWindow is the rolling window of an SLO if none is configured.
+const DefaultSLOWindow = Window(30 * 24 * time.Hour)
+
+// SLO defines the service level objective of a business process: The
+// percentage of its availability the business process must be ok within a
+// rolling window.
+type SLO struct {
+	// Target is the percentage of the availability the business process
+	// must be ok, eg. 99.9.
+	Target float64 `yaml:"target"`
+
+	// Window is the rolling window the target applies to, eg. '30d'.
+	Window Window `yaml:"window"`
+}
+
+// Validate checks the SLO for errors and returns a list of messages.
+func (s SLO) Validate() []string {
+	errs := []string{}
+	if s.Target <= 0 || s.Target >= 100 {
+		errs = append(errs, fmt.Sprintf("Field 'target' must be greater than 0 and less than 100, is %g.", s.Target))
+	}
+	if s.Window < 0 {
+		errs = append(errs, fmt.Sprintf("Field 'window' cannot be negative, is %s.", s.Window))
+	}
+	return errs
+}
+
+// Duration returns the window of the SLO, 'DefaultSLOWindow' if none is
+// configured.
+func (s SLO) Duration() time.Duration {
+	if s.Window == 0 {
+		return time.Duration(DefaultSLOWindow)
+	}
+	return time.Duration(s.Window)
+}
+
+// Window is a duration which can be expressed in days ('30d') and weeks
+// ('4w') in addition to the units understood by 'time.ParseDuration'.
+type Window time.Duration
+
+// ParseWindow parses a duration such as '30d', '2w' or '12h'.
+func ParseWindow(str string) (Window, error) {
+	str = strings.TrimSpace(str)
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if strings.HasSuffix(str, suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(str, suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("'%s' does not look like a duration", str)
+			}
+			return Window(n * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' does not look like a duration", str)
+	}
+	return Window(d), nil
+}
+
+// String returns the window in days if possible, as formated by
+// 'time.Duration' otherwise.
+func (w Window) String() string {
+	d := time.Duration(w)
+	day := 24 * time.Hour
+	if d != 0 && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
+
+// UnmarshalYAML implements the Unmarshaler interface of package yaml.
+// https://godoc.org/gopkg.in/yaml.v2#Unmarshaler
+func (w *Window) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var aux string
+	if err := unmarshal(&aux); err != nil {
+		return err
+	}
+	parsed, err := ParseWindow(aux)
+	if err != nil {
+		return err
+	}
+	*w = parsed
+	return nil
+}
+
+// MarshalYAML implements the Marshaler interface of package yaml.
+// https://godoc.org/gopkg.in/yaml.v2#Marshaler
+func (w Window) MarshalYAML() (interface{}, error) {
+	return w.String(), nil
+}
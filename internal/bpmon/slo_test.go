@@ -0,0 +1,61 @@
+package bpmon
+
+import (
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		str         string
+		window      Window
+		errExpected bool
+	}{
+		{str: "30d", window: Window(30 * 24 * time.Hour)},
+		{str: "2w", window: Window(14 * 24 * time.Hour)},
+		{str: "0.5d", window: Window(12 * time.Hour)},
+		{str: "90m", window: Window(90 * time.Minute)},
+		{str: "d", errExpected: true},
+		{str: "a month", errExpected: true},
+	}
+
+	for _, test := range tests {
+		w, err := ParseWindow(test.str)
+		if err == nil && test.errExpected {
+			t.Errorf("Error expected for '%s' but test succeeded", test.str)
+		} else if err != nil && !test.errExpected {
+			t.Errorf("No error expected for '%s' but test failed: %s", test.str, err.Error())
+		} else if err == nil && w != test.window {
+			t.Errorf("Result not as expected for '%s': Should be '%s', is '%s'", test.str, test.window, w)
+		}
+	}
+}
+
+func TestSLOFromYAML(t *testing.T) {
+	bp := BP{}
+	err := yaml.Unmarshal([]byte("id: app\nslo:\n  target: 99.5\n  window: 7d\n"), &bp)
+	if err != nil {
+		t.Fatalf("No error expected but got: %s", err.Error())
+	}
+	if bp.SLO == nil || bp.SLO.Target != 99.5 || bp.SLO.Duration() != 7*24*time.Hour {
+		t.Errorf("SLO not parsed as expected: %+v", bp.SLO)
+	}
+
+	out, err := yaml.Marshal(bp.SLO)
+	if err != nil || string(out) != "target: 99.5\nwindow: 7d\n" {
+		t.Errorf("SLO not marshalled as expected: %s %v", out, err)
+	}
+
+	bp = BP{}
+	err = yaml.Unmarshal([]byte("id: app\nslo:\n  target: 99.5\n"), &bp)
+	if err != nil || bp.SLO.Duration() != time.Duration(DefaultSLOWindow) {
+		t.Errorf("Expected default window if none is configured, got %v %v", bp.SLO, err)
+	}
+
+	err = yaml.Unmarshal([]byte("id: app\nslo:\n  target: 99.5\n  window: a month\n"), &bp)
+	if err == nil {
+		t.Errorf("Error expected for invalid window but got nil")
+	}
+}
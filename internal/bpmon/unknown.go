@@ -0,0 +1,94 @@
+package bpmon
+
+import (
+	"fmt"
+
+	"github.com/unprofession-al/bpmon/internal/math"
+	"github.com/unprofession-al/bpmon/internal/status"
+)
+
+// UnknownPolicy defines how children of a KPI or a business process with
+// status 'unknown' are considered, eg. if their checker is not available.
+type UnknownPolicy string
+
+const (
+	// UnknownOK considers unknown children ok.
+	UnknownOK UnknownPolicy = "ok"
+
+	// UnknownNOK considers unknown children not ok.
+	UnknownNOK UnknownPolicy = "not ok"
+
+	// UnknownAny results in status 'unknown' if any child is unknown.
+	UnknownAny UnknownPolicy = "any"
+
+	// UnknownAll results in status 'unknown' if all children are unknown,
+	// otherwise unknown children are considered ok.
+	UnknownAll UnknownPolicy = "all"
+)
+
+// DefaultUnknownPolicy is the policy of KPIs and business processes which do
+// not define a policy.
+const DefaultUnknownPolicy = UnknownOK
+
+// Validate returns an error if the policy is not known.
+func (p UnknownPolicy) Validate() error {
+	switch p.orDefault() {
+	case UnknownOK, UnknownNOK, UnknownAny, UnknownAll:
+		return nil
+	}
+	return fmt.Errorf("policy '%s' is unknown, use one of '%s', '%s', '%s' or '%s'", p, UnknownOK, UnknownNOK, UnknownAny, UnknownAll)
+}
+
+// orDefault returns 'DefaultUnknownPolicy' if the policy is not set.
+func (p UnknownPolicy) orDefault() UnknownPolicy {
+	if p == "" {
+		return DefaultUnknownPolicy
+	}
+	return p
+}
+
+// propagate checks whether the status of the children results in status
+// 'unknown' regardless of the operation.
+func (p UnknownPolicy) propagate(stati []status.Status) bool {
+	unknown := 0
+	for _, st := range stati {
+		if st == status.StatusUnknown {
+			unknown++
+		}
+	}
+	switch p.orDefault() {
+	case UnknownAny:
+		return unknown > 0
+	case UnknownAll:
+		return unknown > 0 && unknown == len(stati)
+	}
+	return false
+}
+
+// aggregate returns the status of a KPI or a business process given the
+// result of its operation and the status of its children. If the policy
+// propagates the unknown children, the status is unknown unless the operation
+// is not met, following the precedence defined by 'status.Worse'. Otherwise
+// unknown children are treated as defined by the policy.
+func (p UnknownPolicy) aggregate(res math.Result, stati []status.Status) status.Status {
+	out := status.StatusNOK
+	switch res {
+	case math.OK:
+		out = status.StatusOK
+	case math.Degraded:
+		out = status.StatusDegraded
+	}
+	if p.propagate(stati) {
+		out = status.Worst(out, status.StatusUnknown)
+	}
+	return out
+}
+
+// value returns the status of a child as input of an operation.
+func (p UnknownPolicy) value(st status.Status, weight float64) math.Value {
+	ok := st.Bool()
+	if st == status.StatusUnknown && p.orDefault() == UnknownNOK {
+		ok = false
+	}
+	return math.Value{OK: ok, Degraded: st == status.StatusDegraded, Weight: weight}
+}
@@ -0,0 +1,140 @@
+package bpmon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/unprofession-al/bpmon/internal/status"
+)
+
+func TestUnknownPolicy(t *testing.T) {
+	chk := CheckerMock{}
+	pp := StoreMock{}
+
+	partly := []Service{{Host: "Host1", Service: "good"}, {Host: "Host2", Service: "other"}}
+	fully := []Service{{Host: "Host1", Service: "other"}, {Host: "Host2", Service: "other"}}
+
+	tests := []struct {
+		policy   UnknownPolicy
+		services []Service
+		kpi      status.Status
+	}{
+		{policy: "", services: fully, kpi: status.StatusOK},
+		{policy: UnknownOK, services: partly, kpi: status.StatusOK},
+		{policy: UnknownNOK, services: partly, kpi: status.StatusNOK},
+		{policy: UnknownNOK, services: fully, kpi: status.StatusNOK},
+		{policy: UnknownAny, services: partly, kpi: status.StatusUnknown},
+		{policy: UnknownAll, services: partly, kpi: status.StatusOK},
+		{policy: UnknownAll, services: fully, kpi: status.StatusUnknown},
+	}
+
+	for _, test := range tests {
+		bp := BP{
+			ID:           "bp",
+			Availability: allDayLong,
+			Kpis:         []KPI{{ID: "kpi", Operation: "AND", Unknown: test.policy, Services: test.services}},
+		}
+		rs := bp.Status(context.Background(), chk, pp, chk.DefaultRules())
+		if rs.Children[0].Status != test.kpi {
+			t.Errorf("Expected status of KPI with policy '%s' to be '%s', got '%s'", test.policy, test.kpi, rs.Children[0].Status)
+		}
+	}
+}
+
+func TestUnknownPolicyOfBusinessProcess(t *testing.T) {
+	chk := CheckerMock{}
+	pp := StoreMock{}
+
+	kpis := []KPI{
+		{ID: "db", Operation: "AND", Unknown: UnknownAll, Services: []Service{{Host: "Host1", Service: "other"}}},
+		{ID: "web", Operation: "AND", Services: []Service{{Host: "Host2", Service: "good"}}},
+	}
+
+	tests := map[UnknownPolicy]status.Status{
+		UnknownOK:  status.StatusOK,
+		UnknownNOK: status.StatusNOK,
+		UnknownAny: status.StatusUnknown,
+		UnknownAll: status.StatusOK,
+	}
+
+	for policy, expected := range tests {
+		bp := BP{ID: "bp", Availability: allDayLong, Unknown: policy, Kpis: kpis}
+		rs := bp.Status(context.Background(), chk, pp, chk.DefaultRules())
+		if rs.Status != expected {
+			t.Errorf("Expected status of business process with policy '%s' to be '%s', got '%s'", policy, expected, rs.Status)
+		}
+	}
+}
+
+func TestStatusPrecedence(t *testing.T) {
+	chk := CheckerMock{}
+	pp := StoreMock{}
+
+	tests := []struct {
+		name      string
+		operation string
+		policy    UnknownPolicy
+		services  []Service
+		kpi       status.Status
+	}{
+		{
+			name:      "unknown considered ok by default",
+			operation: "AND",
+			services:  []Service{{Host: "Host1", Service: "warn"}, {Host: "Host2", Service: "other"}},
+			kpi:       status.StatusDegraded,
+		},
+		{
+			name:      "unknown outranks degraded",
+			operation: "AND",
+			policy:    UnknownAny,
+			services:  []Service{{Host: "Host1", Service: "warn"}, {Host: "Host2", Service: "other"}},
+			kpi:       status.StatusUnknown,
+		},
+		{
+			name:      "failed but met with unknown considered ok by default",
+			operation: "MIN 1",
+			services:  []Service{{Host: "Host1", Service: "bad"}, {Host: "Host2", Service: "other"}},
+			kpi:       status.StatusDegraded,
+		},
+		{
+			name:      "unknown outranks failed but met",
+			operation: "MIN 1",
+			policy:    UnknownAny,
+			services:  []Service{{Host: "Host1", Service: "bad"}, {Host: "Host2", Service: "other"}},
+			kpi:       status.StatusUnknown,
+		},
+		{
+			name:      "unknown considered ok if not all are unknown",
+			operation: "MIN 1",
+			policy:    UnknownAll,
+			services:  []Service{{Host: "Host1", Service: "bad"}, {Host: "Host2", Service: "other"}},
+			kpi:       status.StatusDegraded,
+		},
+		{
+			name:      "unknown considered not ok",
+			operation: "MIN 1",
+			policy:    UnknownNOK,
+			services:  []Service{{Host: "Host1", Service: "warn"}, {Host: "Host2", Service: "other"}},
+			kpi:       status.StatusDegraded,
+		},
+		{
+			name:      "not ok outranks unknown",
+			operation: "AND",
+			policy:    UnknownAny,
+			services:  []Service{{Host: "Host1", Service: "bad"}, {Host: "Host2", Service: "other"}},
+			kpi:       status.StatusNOK,
+		},
+	}
+
+	for _, test := range tests {
+		bp := BP{
+			ID:           "bp",
+			Availability: allDayLong,
+			Kpis:         []KPI{{ID: "kpi", Operation: test.operation, Unknown: test.policy, Services: test.services}},
+		}
+		rs := bp.Status(context.Background(), chk, pp, chk.DefaultRules())
+		if rs.Children[0].Status != test.kpi {
+			t.Errorf("Expected status of KPI '%s' to be '%s', got '%s'", test.name, test.kpi, rs.Children[0].Status)
+		}
+	}
+}
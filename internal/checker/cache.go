@@ -0,0 +1,129 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/rules"
+)
+
+// Cache wraps a Checker implementation. Concurrent requests for the same
+// host and service are coalesced into a single request to the checker and
+// successful results are kept for the time to live configured. If the time
+// to live is 0, results are not kept but concurrent requests are still
+// coalesced. This ensures that a service referenced by many business
+// processes is only fetched once per run.
+type Cache struct {
+	chk Checker
+	ttl time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]cacheEntry
+	inflight map[string]*cacheCall
+
+	hits   uint64
+	misses uint64
+}
+
+type cacheEntry struct {
+	result  Result
+	expires time.Time
+}
+
+type cacheCall struct {
+	done   chan struct{}
+	result Result
+}
+
+// NewCache returns a Cache wrapping the Checker provided.
+func NewCache(chk Checker, ttl time.Duration) *Cache {
+	return &Cache{
+		chk:      chk,
+		ttl:      ttl,
+		entries:  make(map[string]cacheEntry),
+		inflight: make(map[string]*cacheCall),
+	}
+}
+
+// Status implements the 'Checker' interface. Results containing an error are
+// shared with concurrent requests but not cached.
+func (c *Cache) Status(ctx context.Context, host string, service string) Result {
+	key := host + "!" + service
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.expires) {
+		c.mu.Unlock()
+		atomic.AddUint64(&c.hits, 1)
+		return e.result
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		atomic.AddUint64(&c.hits, 1)
+		select {
+		case <-call.done:
+			return call.result
+		case <-ctx.Done():
+			return Result{
+				Timestamp: time.Now(),
+				Error:     fmt.Errorf("check of %s was not completed: %s", key, ctx.Err().Error()),
+			}
+		}
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	atomic.AddUint64(&c.misses, 1)
+	call.result = c.chk.Status(ctx, host, service)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.result.Error == nil && c.ttl > 0 {
+		c.entries[key] = cacheEntry{
+			result:  call.result,
+			expires: time.Now().Add(c.ttl),
+		}
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.result
+}
+
+// Prefetch implements the 'Prefetcher' interface if the checker wrapped does.
+// All cached results are dropped in order to answer the following requests
+// from the prefetched data.
+func (c *Cache) Prefetch(ctx context.Context, services []Service) error {
+	p, ok := c.chk.(Prefetcher)
+	if !ok {
+		return nil
+	}
+	c.mu.Lock()
+	c.entries = make(map[string]cacheEntry)
+	c.mu.Unlock()
+	return p.Prefetch(ctx, services)
+}
+
+// Stats implements the 'StatsReporter' interface. Coalesced requests are
+// counted as answered from the cache.
+func (c *Cache) Stats() (hits uint64, misses uint64) {
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses)
+}
+
+// Health implements the 'Checker' interface.
+func (c *Cache) Health() (string, error) {
+	return c.chk.Health()
+}
+
+// Values implements the 'Checker' interface.
+func (c *Cache) Values() []string {
+	return c.chk.Values()
+}
+
+// DefaultRules implements the 'Checker' interface.
+func (c *Cache) DefaultRules() rules.Rules {
+	return c.chk.DefaultRules()
+}
@@ -0,0 +1,109 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/rules"
+)
+
+type countingChecker struct {
+	calls uint64
+	delay time.Duration
+}
+
+func (c *countingChecker) Status(ctx context.Context, host, service string) Result {
+	atomic.AddUint64(&c.calls, 1)
+	time.Sleep(c.delay)
+	r := Result{Timestamp: time.Now(), Values: map[string]bool{"ok": true}}
+	if service == "error" {
+		r.Error = errors.New("Error occurred")
+	}
+	return r
+}
+
+func (c *countingChecker) Values() []string          { return []string{"ok"} }
+func (c *countingChecker) Health() (string, error)   { return "all fine", nil }
+func (c *countingChecker) DefaultRules() rules.Rules { return rules.Rules{} }
+
+func TestCacheCoalescesConcurrentRequests(t *testing.T) {
+	chk := &countingChecker{delay: 50 * time.Millisecond}
+	c := NewCache(chk, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Status(context.Background(), "host", "service")
+		}()
+	}
+	wg.Wait()
+
+	if chk.calls != 1 {
+		t.Errorf("Expected 1 call to the checker, got %d", chk.calls)
+	}
+	hits, misses := c.Stats()
+	if hits != 9 || misses != 1 {
+		t.Errorf("Expected 9 hits and 1 miss, got %d hits and %d misses", hits, misses)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	chk := &countingChecker{}
+	c := NewCache(chk, 20*time.Millisecond)
+
+	c.Status(context.Background(), "host", "service")
+	c.Status(context.Background(), "host", "service")
+	c.Status(context.Background(), "host", "other")
+	if chk.calls != 2 {
+		t.Errorf("Expected 2 calls to the checker, got %d", chk.calls)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	c.Status(context.Background(), "host", "service")
+	if chk.calls != 3 {
+		t.Errorf("Expected 3 calls to the checker after expiry, got %d", chk.calls)
+	}
+}
+
+func TestCacheWithoutTTL(t *testing.T) {
+	chk := &countingChecker{delay: 50 * time.Millisecond}
+	c := NewCache(chk, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Status(context.Background(), "host", "service")
+		}()
+	}
+	wg.Wait()
+	if chk.calls != 1 {
+		t.Errorf("Expected concurrent requests to be coalesced into 1 call, got %d", chk.calls)
+	}
+
+	c.Status(context.Background(), "host", "service")
+	if chk.calls != 2 {
+		t.Errorf("Expected results not to be reused without time to live, got %d calls", chk.calls)
+	}
+}
+
+func TestCacheSkipsErrors(t *testing.T) {
+	chk := &countingChecker{}
+	c := NewCache(chk, time.Minute)
+
+	r := c.Status(context.Background(), "host", "error")
+	if r.Error == nil {
+		t.Errorf("Error expected but got nil")
+	}
+	c.Status(context.Background(), "host", "error")
+	if chk.calls != 2 {
+		t.Errorf("Expected failed results not to be cached, got %d calls", chk.calls)
+	}
+}
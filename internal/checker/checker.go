@@ -5,7 +5,9 @@
 package checker
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -22,12 +24,28 @@ var (
 // The field 'Kind' is used to determine which provider is requested.
 type Config struct {
 	// kind defines the checker implementation to be used by BPMON. Currently
-	// only icinga is implemented.
+	// icinga and prometheus are implemented. Use multi in order to check the
+	// services using several checkers, see backends.
 	Kind string `yaml:"kind"`
 
+	// name identifies a backend if kind is multi. Services of a business
+	// process refer to the backend via its name.
+	Name string `yaml:"name,omitempty"`
+
+	// backends is a list of checkers used if kind is multi. Each backend is
+	// configured as a checker itself and must have a unique name. Services
+	// which do not specify a backend are checked by the first backend. The
+	// fields timeout, cache_ttl and max_parallel are inherited if not set in
+	// a backend. The connection of the multi checker itself is not used.
+	Backends []Config `yaml:"backends,omitempty"`
+
 	// The connection string describes how to connect to your Icinga API. The
 	// string needs to follow the pattern:
 	//   [protocol]://[user]:[passwd]@[hostname]:[port]
+	// If kind is prometheus, the connection string points to the Prometheus
+	// API. The Alertmanager can be added as parameter to read silences and
+	// inhibitions:
+	//   [protocol]://[hostname]:[port]?alertmanager=[protocol]://[hostname]:[port]
 	Connection string `yaml:"connection"`
 
 	// BPMON verifies if a https connection is trusted. If you wont to trust a
@@ -39,6 +57,23 @@ type Config struct {
 	// its documentation for more details:
 	//   https://golang.org/pkg/time/#ParseDuration
 	Timeout time.Duration `yaml:"timeout"`
+
+	// cache_ttl defines how long the result of a check is reused when the
+	// same host and service is referenced multiple times, eg. by several
+	// business processes. Concurrent requests for the same check are always
+	// merged into one request. Set to 0 to disable the reuse of results. This
+	// should be shorter than the interval of the serve subcommand.
+	CacheTTL *time.Duration `yaml:"cache_ttl"`
+
+	// max_parallel limits the number of requests sent to the checker at the
+	// same time. Set to 0 to send all requests at once.
+	MaxParallel int `yaml:"max_parallel"`
+
+	// run_timeout defines how long BPMON waits for all checks of a single run
+	// to complete. Checks not completed in time are considered 'unknown'.
+	// Set to 0 to wait until all checks are completed. The string is parsed as
+	// a golang duration.
+	RunTimeout time.Duration `yaml:"run_timeout"`
 }
 
 func Defaults() Config {
@@ -51,6 +86,13 @@ var configDefaults = ConfigDefaulted{
 	Kind:          "icinga",
 	TLSSkipVerify: false,
 	Timeout:       time.Duration(10 * time.Second),
+	CacheTTL:      duration(1 * time.Minute),
+	MaxParallel:   20,
+	RunTimeout:    time.Duration(1 * time.Minute),
+}
+
+func duration(d time.Duration) *time.Duration {
+	return &d
 }
 
 func (c Config) Validate() ([]string, error) {
@@ -58,8 +100,24 @@ func (c Config) Validate() ([]string, error) {
 	if c.Kind == "" {
 		errs = append(errs, "Field 'kind' cannot be empty.")
 	}
-	if c.Connection == "" {
-		errs = append(errs, "Field 'connection' cannot be empty.")
+	if c.Kind == KindMulti {
+		errs = append(errs, c.validateBackends()...)
+	} else {
+		if c.Connection == "" {
+			errs = append(errs, "Field 'connection' cannot be empty.")
+		}
+		if len(c.Backends) > 0 {
+			errs = append(errs, fmt.Sprintf("Field 'backends' is only allowed if kind is '%s'.", KindMulti))
+		}
+	}
+	if c.CacheTTL != nil && *c.CacheTTL < 0 {
+		errs = append(errs, "Field 'cache_ttl' cannot be negative.")
+	}
+	if c.MaxParallel < 0 {
+		errs = append(errs, "Field 'max_parallel' cannot be negative.")
+	}
+	if c.RunTimeout < 0 {
+		errs = append(errs, "Field 'run_timeout' cannot be negative.")
 	}
 	if len(errs) > 0 {
 		err := errors.New("Config of 'checker' has errors")
@@ -68,6 +126,40 @@ func (c Config) Validate() ([]string, error) {
 	return errs, nil
 }
 
+func (c Config) validateBackends() []string {
+	errs := []string{}
+	if len(c.Backends) < 1 {
+		errs = append(errs, "Field 'backends' cannot be empty.")
+	}
+	names := make(map[string]bool)
+	for _, b := range c.Backends {
+		if b.Name == "" {
+			errs = append(errs, "Field 'name' of backends cannot be empty.")
+		} else if names[b.Name] {
+			errs = append(errs, fmt.Sprintf("Backend name '%s' is not unique.", b.Name))
+		}
+		names[b.Name] = true
+		if b.Kind == KindMulti {
+			errs = append(errs, fmt.Sprintf("Backend '%s' cannot be of kind '%s'.", b.Name, KindMulti))
+			continue
+		}
+		bErrs, _ := b.Validate()
+		for _, e := range bErrs {
+			errs = append(errs, fmt.Sprintf("Backend '%s': %s", b.Name, e))
+		}
+	}
+	return errs
+}
+
+// RunContext returns a context derived from 'parent' which is done once the
+// 'RunTimeout' is exceeded. It must be used for all checks of a single run.
+func (c Config) RunContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if c.RunTimeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, c.RunTimeout)
+}
+
 // Checker interface needs to be implemented in order to provide a Checker
 // backend such as Icinga.
 type Checker interface {
@@ -76,8 +168,9 @@ type Checker interface {
 	Health() (string, error)
 
 	// Status takes a host string as well as a service string and returns
-	// 'Result' of the stuct of the check.
-	Status(host string, service string) Result
+	// 'Result' of the stuct of the check. Implementations should return as
+	// soon as the context is done.
+	Status(ctx context.Context, host string, service string) Result
 
 	// Values returns a lists of value names that a 'Result' stuct will contain
 	// when 'Status()' is called.
@@ -88,6 +181,30 @@ type Checker interface {
 	DefaultRules() rules.Rules
 }
 
+// Prefetcher can be implemented by checkers that are able to fetch the status
+// of many services at once. Once 'Prefetch' is called, 'Status' is expected
+// to answer requests for the services provided from the prefetched data until
+// 'Prefetch' is called again.
+type Prefetcher interface {
+	Prefetch(ctx context.Context, services []Service) error
+}
+
+// StatsReporter can be implemented by checkers that keep track of the
+// requests answered from a cache, such as 'Cache' and 'Multi'.
+type StatsReporter interface {
+	// Stats returns the number of requests answered from the cache and
+	// the number of requests passed to the checker.
+	Stats() (hits uint64, misses uint64)
+}
+
+// Service identifies a service to be checked. 'Checker' names the backend
+// responsible for the service if the checker is of kind 'multi'.
+type Service struct {
+	Host    string
+	Service string
+	Checker string
+}
+
 // Register must be called in the init function of each checker implementation.
 // The Register function will panic if two checker impelmentations with the
 // same name try to register themselves.
@@ -102,13 +219,34 @@ func Register(name string, setupFunc func(Config) (Checker, error)) {
 
 // New well return a configured instance of a checker implementation. The
 // implementation requested is determined by the 'Kind' field of the
-// configuration struct.
+// configuration struct. If 'MaxParallel' is set the implementation is
+// wrapped in a 'Limit'. The implementation is always wrapped in a 'Cache' in
+// order to coalesce concurrent requests, results are reused as configured
+// via 'CacheTTL'. If the kind is 'multi', a 'Multi' is returned.
 func New(conf Config) (Checker, error) {
+	if conf.Kind == KindMulti {
+		m, err := NewMulti(conf)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
 	setupFunc, ok := c[conf.Kind]
 	if !ok {
 		return nil, errors.New("checker: checker '" + conf.Kind + "' does not exist")
 	}
-	return setupFunc(conf)
+	chk, err := setupFunc(conf)
+	if err != nil {
+		return chk, err
+	}
+	if conf.MaxParallel > 0 {
+		chk = NewLimit(chk, conf.MaxParallel)
+	}
+	ttl := time.Duration(0)
+	if conf.CacheTTL != nil {
+		ttl = *conf.CacheTTL
+	}
+	return NewCache(chk, ttl), nil
 }
 
 // Result is returned on a service status check. It contains all relevant
@@ -1,13 +1,17 @@
 package icinga
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/unprofession-al/bpmon/internal/checker"
@@ -39,7 +43,8 @@ func Setup(conf checker.Config) (checker.Checker, error) {
 	}
 
 	i := Icinga{
-		f: fetcher,
+		f:        fetcher,
+		snapshot: &snapshot{},
 	}
 
 	return i, nil
@@ -48,7 +53,41 @@ func Setup(conf checker.Config) (checker.Checker, error) {
 // Icinga holds the 'Checker' implementation. It allows BPMON to fetch the status
 // configured via the Icinga2 API
 type Icinga struct {
-	f fetcher
+	f        fetcher
+	snapshot *snapshot
+}
+
+// bulkSize is the maximum number of services requested at once when services
+// are prefetched.
+const bulkSize = 500
+
+// snapshot keeps the results of prefetched services indexed by their full
+// name (eg. 'host!service').
+type snapshot struct {
+	sync.RWMutex
+	requested map[string]bool
+	results   map[string]Result
+}
+
+// get returns the response for a service. If the service was not requested
+// when the snapshot was taken, 'ok' is false.
+func (s *snapshot) get(name string) (response Response, ok bool) {
+	s.RLock()
+	defer s.RUnlock()
+	if !s.requested[name] {
+		return response, false
+	}
+	if result, found := s.results[name]; found {
+		response.Results = []Result{result}
+	}
+	return response, true
+}
+
+func (s *snapshot) set(requested map[string]bool, results map[string]Result) {
+	s.Lock()
+	defer s.Unlock()
+	s.requested = requested
+	s.results = results
 }
 
 // DefaultRules implements the 'Checker' interface.
@@ -93,13 +132,13 @@ func (i Icinga) Health() (string, error) {
 }
 
 // Status implements the 'Checker' interface.
-func (i Icinga) Status(host string, service string) checker.Result {
+func (i Icinga) Status(ctx context.Context, host string, service string) checker.Result {
 	r := checker.Result{
 		Timestamp: time.Now(),
 		Values:    flagDefaults.ToValues(),
 	}
 
-	response, err := i.f.Fetch(host, service)
+	response, err := i.fetch(ctx, host, service)
 	if err != nil {
 		r.Error = err
 		return r
@@ -109,6 +148,53 @@ func (i Icinga) Status(host string, service string) checker.Result {
 	return r
 }
 
+// Prefetch implements the 'Prefetcher' interface. It fetches the status of
+// all services provided using as few requests as possible. If an error
+// occurs, all services are fetched one by one when requested.
+func (i Icinga) Prefetch(ctx context.Context, services []checker.Service) error {
+	if i.snapshot == nil {
+		return errors.New("icinga checker is not set up to prefetch services")
+	}
+
+	requested := make(map[string]bool)
+	var names []string
+	for _, s := range services {
+		name := fmt.Sprintf("%s!%s", s.Host, s.Service)
+		if !requested[name] {
+			requested[name] = true
+			names = append(names, name)
+		}
+	}
+
+	results := make(map[string]Result)
+	for start := 0; start < len(names); start += bulkSize {
+		end := start + bulkSize
+		if end > len(names) {
+			end = len(names)
+		}
+		response, err := i.f.FetchAll(ctx, names[start:end])
+		if err != nil {
+			i.snapshot.set(nil, nil)
+			return err
+		}
+		for _, result := range response.Results {
+			results[result.Name] = result
+		}
+	}
+
+	i.snapshot.set(requested, results)
+	return nil
+}
+
+func (i Icinga) fetch(ctx context.Context, host, service string) (Response, error) {
+	if i.snapshot != nil {
+		if response, ok := i.snapshot.get(fmt.Sprintf("%s!%s", host, service)); ok {
+			return response, nil
+		}
+	}
+	return i.f.Fetch(ctx, host, service)
+}
+
 func (r Response) status() (at time.Time, msg string, vals map[string]bool, err error) {
 	at = time.Now()
 	msg = ""
@@ -146,7 +232,8 @@ func (r Response) status() (at time.Time, msg string, vals map[string]bool, err
 }
 
 type fetcher interface {
-	Fetch(string, string) (Response, error)
+	Fetch(context.Context, string, string) (Response, error)
+	FetchAll(context.Context, []string) (Response, error)
 	Health() (string, error)
 }
 
@@ -158,7 +245,7 @@ type api struct {
 	tlsSkipVerify bool
 }
 
-func (a api) Fetch(host, service string) (Response, error) {
+func (a api) Fetch(ctx context.Context, host, service string) (Response, error) {
 	var response Response
 
 	// proper encoding for the host string
@@ -169,7 +256,33 @@ func (a api) Fetch(host, service string) (Response, error) {
 	service = serviceURL.String()
 	// build url
 	url := fmt.Sprintf("%s/objects/services?service=%s!%s", a.baseURL, host, service)
-	body, err := a.get(url)
+	body, err := a.do(ctx, "GET", url, nil)
+	if err != nil {
+		return response, err
+	}
+
+	err = json.Unmarshal(body, &response)
+	return response, err
+}
+
+// FetchAll requests all services by their full name (eg. 'host!service') in
+// a single request. Since the list of services can be long, the filter is
+// sent as request body via POST as described in
+// https://icinga.com/docs/icinga2/latest/doc/12-icinga2-api/#x-http-method-override
+func (a api) FetchAll(ctx context.Context, names []string) (Response, error) {
+	var response Response
+
+	q := Query{
+		Filter:     FilterServiceNames,
+		FilterVars: map[string]interface{}{"names": names},
+	}
+	data, err := json.Marshal(q)
+	if err != nil {
+		return response, err
+	}
+
+	url := fmt.Sprintf("%s/objects/services", a.baseURL)
+	body, err := a.do(ctx, "POST", url, data)
 	if err != nil {
 		return response, err
 	}
@@ -180,11 +293,14 @@ func (a api) Fetch(host, service string) (Response, error) {
 
 func (a api) Health() (string, error) {
 	url := fmt.Sprintf("%s/status", a.baseURL)
-	body, err := a.get(url)
+	body, err := a.do(context.Background(), "GET", url, nil)
 	return string(body), err
 }
 
-func (a api) get(url string) ([]byte, error) {
+// do performs a request. Requests with a body are sent as POST request with
+// the 'X-HTTP-Method-Override: GET' header since the Icinga2 API requires
+// queries to be GET requests.
+func (a api) do(ctx context.Context, method, url string, data []byte) ([]byte, error) {
 	var body []byte
 
 	tr := &http.Transport{
@@ -194,15 +310,25 @@ func (a api) get(url string) ([]byte, error) {
 		Transport: tr,
 		Timeout:   a.timeout,
 	}
-	req, err := http.NewRequest("GET", url, nil)
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
 	if err != nil {
 		return body, err
 	}
+	req = req.WithContext(ctx)
 	req.SetBasicAuth(a.user, a.pass)
+	req.Header.Set("Accept", "application/json")
+	if method != "GET" {
+		req.Header.Set("X-HTTP-Method-Override", "GET")
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		return body, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		err = errors.New("HTTP error " + resp.Status)
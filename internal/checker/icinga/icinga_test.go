@@ -1,10 +1,16 @@
 package icinga
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/checker"
 )
 
 type testset struct {
@@ -88,7 +94,7 @@ func (i IcingaMock) Health() (string, error) {
 	return "all fine", nil
 }
 
-func (i IcingaMock) Fetch(host, service string) (Response, error) {
+func (i IcingaMock) Fetch(ctx context.Context, host, service string) (Response, error) {
 	var response Response
 	for _, ep := range i.endpoints {
 		if ep.host == host && ep.service == service {
@@ -99,10 +105,28 @@ func (i IcingaMock) Fetch(host, service string) (Response, error) {
 	return response, errors.New("Service not found")
 }
 
+func (i IcingaMock) FetchAll(ctx context.Context, names []string) (Response, error) {
+	var response Response
+	for _, name := range names {
+		for _, ep := range i.endpoints {
+			if ep.host+"!"+ep.service == name {
+				var r Response
+				err := json.Unmarshal(ep.response, &r)
+				if err != nil {
+					return response, err
+				}
+				r.Results[0].Name = name
+				response.Results = append(response.Results, r.Results...)
+			}
+		}
+	}
+	return response, nil
+}
+
 func TestStatusInterpreter(t *testing.T) {
 	i := Icinga{f: IcingaMock{endpoints: TestSets}}
 	for _, test := range TestSets {
-		result := i.Status(test.host, test.service)
+		result := i.Status(context.Background(), test.host, test.service)
 		if result.Error != nil {
 			t.Errorf("Error returned: %s", result.Error.Error())
 		}
@@ -115,3 +139,91 @@ func TestStatusInterpreter(t *testing.T) {
 		}
 	}
 }
+
+type countingFetcher struct {
+	IcingaMock
+	fetched *int
+}
+
+func (f countingFetcher) Fetch(ctx context.Context, host, service string) (Response, error) {
+	*f.fetched++
+	return f.IcingaMock.Fetch(ctx, host, service)
+}
+
+func TestPrefetch(t *testing.T) {
+	fetched := 0
+	i := Icinga{
+		f:        countingFetcher{IcingaMock: IcingaMock{endpoints: TestSets}, fetched: &fetched},
+		snapshot: &snapshot{},
+	}
+
+	services := []checker.Service{
+		{Host: "Test Host", Service: "All Fine"},
+		{Host: "Test Host", Service: "Ack, Downtime, Critical"},
+		{Host: "Test Host", Service: "Missing"},
+	}
+	err := i.Prefetch(context.Background(), services)
+	if err != nil {
+		t.Fatalf("No error expected but got error: %s", err.Error())
+	}
+
+	for _, test := range TestSets {
+		result := i.Status(context.Background(), test.host, test.service)
+		if result.Error != nil {
+			t.Errorf("Error returned: %s", result.Error.Error())
+		}
+		if !reflect.DeepEqual(result.Values, test.result) {
+			t.Errorf("Results do not match: '%v' vs. '%v'", result.Values, test.result)
+		}
+	}
+
+	result := i.Status(context.Background(), "Test Host", "Missing")
+	if result.Error == nil {
+		t.Errorf("Error expected for service missing in prefetched data but got nil")
+	}
+
+	if fetched != 0 {
+		t.Errorf("Expected no single requests for prefetched services, got %d", fetched)
+	}
+
+	i.Status(context.Background(), "Test Host", "Not Prefetched")
+	if fetched != 1 {
+		t.Errorf("Expected single request for service not prefetched, got %d", fetched)
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	names := []string{"Test Host!All Fine", "Test Host!Other"}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("X-HTTP-Method-Override") != "GET" {
+			t.Errorf("Expected POST request with method override, got %s '%s'", r.Method, r.Header.Get("X-HTTP-Method-Override"))
+		}
+		if r.URL.Path != "/v1/objects/services" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var q Query
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			t.Errorf("Could not decode query: %s", err.Error())
+		}
+		if q.Filter != FilterServiceNames {
+			t.Errorf("Unexpected filter '%s'", q.Filter)
+		}
+		if !reflect.DeepEqual(q.FilterVars["names"], []interface{}{names[0], names[1]}) {
+			t.Errorf("Unexpected filter vars %v", q.FilterVars)
+		}
+		w.Write([]byte(`{"results": [{"name": "Test Host!All Fine", "attrs": {"last_check_result": {"output": "ok", "state": 0}}}]}`))
+	}))
+	defer ts.Close()
+
+	chk, err := Setup(checker.Config{Connection: ts.URL, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("No error expected but got error: %s", err.Error())
+	}
+	response, err := chk.(Icinga).f.FetchAll(context.Background(), names)
+	if err != nil {
+		t.Fatalf("No error expected but got error: %s", err.Error())
+	}
+	if len(response.Results) != 1 || response.Results[0].Name != names[0] {
+		t.Errorf("Unexpected response %v", response)
+	}
+}
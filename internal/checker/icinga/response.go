@@ -35,6 +35,17 @@ type LastCheckResult struct {
 	Output string  `json:"output"`
 }
 
+// Query describes the body of a request to the Icinga2 API which allows to
+// filter objects using the Icinga2 DSL.
+type Query struct {
+	Filter     string                 `json:"filter"`
+	FilterVars map[string]interface{} `json:"filter_vars,omitempty"`
+}
+
+// FilterServiceNames is the filter used to request services by their full
+// name. The names are passed as 'names' in the filter variables.
+const FilterServiceNames = "service.__name in names"
+
 const (
 	statusOK = iota
 	statusWarn
@@ -0,0 +1,64 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/rules"
+)
+
+// Limit wraps a Checker implementation and limits the number of concurrent
+// requests passed to the checker. Requests exceeding the limit wait for a
+// free slot until their context is done.
+type Limit struct {
+	chk   Checker
+	slots chan struct{}
+}
+
+// NewLimit returns a Limit wrapping the Checker provided which allows 'max'
+// concurrent requests.
+func NewLimit(chk Checker, max int) *Limit {
+	return &Limit{
+		chk:   chk,
+		slots: make(chan struct{}, max),
+	}
+}
+
+// Status implements the 'Checker' interface.
+func (l *Limit) Status(ctx context.Context, host string, service string) Result {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return Result{
+			Timestamp: time.Now(),
+			Error:     fmt.Errorf("check of %s!%s was not started: %s", host, service, ctx.Err().Error()),
+		}
+	}
+	defer func() { <-l.slots }()
+	return l.chk.Status(ctx, host, service)
+}
+
+// Prefetch implements the 'Prefetcher' interface if the checker wrapped does.
+func (l *Limit) Prefetch(ctx context.Context, services []Service) error {
+	p, ok := l.chk.(Prefetcher)
+	if !ok {
+		return nil
+	}
+	return p.Prefetch(ctx, services)
+}
+
+// Health implements the 'Checker' interface.
+func (l *Limit) Health() (string, error) {
+	return l.chk.Health()
+}
+
+// Values implements the 'Checker' interface.
+func (l *Limit) Values() []string {
+	return l.chk.Values()
+}
+
+// DefaultRules implements the 'Checker' interface.
+func (l *Limit) DefaultRules() rules.Rules {
+	return l.chk.DefaultRules()
+}
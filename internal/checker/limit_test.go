@@ -0,0 +1,68 @@
+package checker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/rules"
+)
+
+type concurrencyChecker struct {
+	current int64
+	max     int64
+	delay   time.Duration
+}
+
+func (c *concurrencyChecker) Status(ctx context.Context, host, service string) Result {
+	current := atomic.AddInt64(&c.current, 1)
+	for {
+		max := atomic.LoadInt64(&c.max)
+		if current <= max || atomic.CompareAndSwapInt64(&c.max, max, current) {
+			break
+		}
+	}
+	time.Sleep(c.delay)
+	atomic.AddInt64(&c.current, -1)
+	return Result{Timestamp: time.Now(), Values: map[string]bool{"ok": true}}
+}
+
+func (c *concurrencyChecker) Values() []string          { return []string{"ok"} }
+func (c *concurrencyChecker) Health() (string, error)   { return "all fine", nil }
+func (c *concurrencyChecker) DefaultRules() rules.Rules { return rules.Rules{} }
+
+func TestLimit(t *testing.T) {
+	chk := &concurrencyChecker{delay: 10 * time.Millisecond}
+	l := NewLimit(chk, 3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Status(context.Background(), "host", "service")
+		}()
+	}
+	wg.Wait()
+
+	if chk.max > 3 {
+		t.Errorf("Expected at most 3 concurrent requests, got %d", chk.max)
+	}
+}
+
+func TestLimitDeadline(t *testing.T) {
+	chk := &concurrencyChecker{delay: 100 * time.Millisecond}
+	l := NewLimit(chk, 1)
+
+	go l.Status(context.Background(), "host", "slow")
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	r := l.Status(ctx, "host", "waiting")
+	if r.Error == nil {
+		t.Errorf("Error expected for request waiting beyond its deadline but got nil")
+	}
+}
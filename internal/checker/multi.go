@@ -0,0 +1,259 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/rules"
+)
+
+// KindMulti is the kind of the checker which routes the services to several
+// named backends.
+const KindMulti = "multi"
+
+// Multi holds several named checkers (backends) and routes each request to
+// one of them. Requests which do not name a backend are passed to the first
+// backend. The values of all results are completed with the values of all
+// other backends in order to allow the same rules to be applied to results
+// of all backends.
+type Multi struct {
+	names    []string
+	backends map[string]Checker
+}
+
+// NewMulti returns a Multi with a backend for each entry of 'Backends' in the
+// configuration provided. Fields which are not set in the configuration of a
+// backend are inherited.
+func NewMulti(conf Config) (*Multi, error) {
+	m := &Multi{backends: make(map[string]Checker)}
+	for _, b := range conf.Backends {
+		if b.Timeout == 0 {
+			b.Timeout = conf.Timeout
+		}
+		if b.CacheTTL == nil {
+			b.CacheTTL = conf.CacheTTL
+		}
+		if b.MaxParallel == 0 {
+			b.MaxParallel = conf.MaxParallel
+		}
+		chk, err := New(b)
+		if err != nil {
+			return nil, fmt.Errorf("checker: backend '%s' could not be set up: %s", b.Name, err.Error())
+		}
+		m.names = append(m.names, b.Name)
+		m.backends[b.Name] = chk
+	}
+	if len(m.names) < 1 {
+		return nil, errors.New("checker: no backends configured")
+	}
+	return m, nil
+}
+
+// Status implements the 'Checker' interface. The request is passed to the
+// first backend.
+func (m *Multi) Status(ctx context.Context, host string, service string) Result {
+	return m.StatusOf(ctx, "", host, service)
+}
+
+// StatusOf passes the request to the backend named. If 'backend' is empty,
+// the first backend is used.
+func (m *Multi) StatusOf(ctx context.Context, backend string, host string, service string) Result {
+	chk, err := m.backend(backend)
+	if err != nil {
+		return Result{
+			Timestamp: time.Now(),
+			Values:    m.complete(nil),
+			Error:     err,
+		}
+	}
+	r := chk.Status(ctx, host, service)
+	r.Values = m.complete(r.Values)
+	return r
+}
+
+// Stats implements the 'StatsReporter' interface. The numbers of all
+// backends are summed up.
+func (m *Multi) Stats() (hits uint64, misses uint64) {
+	for _, name := range m.names {
+		if r, ok := m.backends[name].(StatsReporter); ok {
+			h, mi := r.Stats()
+			hits += h
+			misses += mi
+		}
+	}
+	return hits, misses
+}
+
+func (m *Multi) backend(name string) (Checker, error) {
+	if name == "" {
+		name = m.names[0]
+	}
+	chk, ok := m.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("checker backend '%s' does not exist", name)
+	}
+	return chk, nil
+}
+
+// complete adds all values of all backends missing in 'values' as 'false'.
+func (m *Multi) complete(values map[string]bool) map[string]bool {
+	out := make(map[string]bool)
+	for _, v := range m.Values() {
+		out[v] = false
+	}
+	for k, v := range values {
+		out[k] = v
+	}
+	return out
+}
+
+// Prefetch implements the 'Prefetcher' interface. The services are passed to
+// the backends they belong to if the backend implements the 'Prefetcher'
+// interface itself.
+func (m *Multi) Prefetch(ctx context.Context, services []Service) error {
+	perBackend := make(map[string][]Service)
+	for _, s := range services {
+		name := s.Checker
+		if name == "" {
+			name = m.names[0]
+		}
+		perBackend[name] = append(perBackend[name], s)
+	}
+
+	var errs []string
+	for _, name := range m.names {
+		p, ok := m.backends[name].(Prefetcher)
+		if !ok || len(perBackend[name]) == 0 {
+			continue
+		}
+		if err := p.Prefetch(ctx, perBackend[name]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Health implements the 'Checker' interface. The health of all backends is
+// reported, an error is returned if any of the backends is not healthy.
+func (m *Multi) Health() (string, error) {
+	var out []string
+	var errs []string
+	for _, name := range m.names {
+		msg, err := m.backends[name].Health()
+		out = append(out, fmt.Sprintf("%s: %s", name, msg))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		return strings.Join(out, "\n"), errors.New(strings.Join(errs, ", "))
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// Values implements the 'Checker' interface. It returns the values of all
+// backends.
+func (m *Multi) Values() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, name := range m.names {
+		for _, v := range m.backends[name].Values() {
+			if !seen[v] {
+				seen[v] = true
+				out = append(out, v)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// DefaultRules implements the 'Checker' interface. If all backends provide
+// the same default rules, those are returned. Otherwise the rules of all
+// backends are merged, see 'mergeRules'.
+func (m *Multi) DefaultRules() rules.Rules {
+	var sets []rules.Rules
+	for _, name := range m.names {
+		sets = append(sets, m.backends[name].DefaultRules())
+	}
+	return mergeRules(sets)
+}
+
+// mergeRules merges the rule sets provided, keeping the original order of
+// each rule in order to allow rules to be overridden by the order documented
+// by the checker. Rules which occur at the same order in several sets are
+// only kept once. If sets define different rules at the same order, the rule
+// of the set listed first keeps the order, the others are moved to the next
+// free order.
+func mergeRules(sets []rules.Rules) rules.Rules {
+	same := true
+	for _, s := range sets[1:] {
+		if !reflect.DeepEqual(s, sets[0]) {
+			same = false
+			break
+		}
+	}
+	if same {
+		return sets[0]
+	}
+
+	out := rules.Rules{}
+	for _, s := range sets {
+		var orders []int
+		for order := range s {
+			orders = append(orders, order)
+		}
+		sort.Ints(orders)
+		for _, order := range orders {
+			rule := s[order]
+			if existing, ok := out[order]; ok && reflect.DeepEqual(existing, rule) {
+				continue
+			}
+			for {
+				if _, ok := out[order]; !ok {
+					break
+				}
+				order++
+			}
+			out[order] = rule
+		}
+	}
+	return out
+}
+
+// StatusOf passes the request to the backend named if 'chk' is a 'Multi'. If
+// 'backend' is empty the request is passed to 'chk' itself.
+func StatusOf(ctx context.Context, chk Checker, backend string, host string, service string) Result {
+	if m, ok := chk.(*Multi); ok {
+		return m.StatusOf(ctx, backend, host, service)
+	}
+	if backend != "" {
+		return Result{
+			Timestamp: time.Now(),
+			Values:    make(map[string]bool),
+			Error:     fmt.Errorf("checker backend '%s' requested but checker is not of kind '%s'", backend, KindMulti),
+		}
+	}
+	return chk.Status(ctx, host, service)
+}
+
+// HasBackend returns true if requests for the backend named can be handled
+// by 'chk'.
+func HasBackend(chk Checker, backend string) bool {
+	if backend == "" {
+		return true
+	}
+	if m, ok := chk.(*Multi); ok {
+		_, err := m.backend(backend)
+		return err == nil
+	}
+	return false
+}
@@ -0,0 +1,316 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/status"
+)
+
+// backendChecker returns results tagged with the connection it was set up
+// with in order to verify the routing.
+type backendChecker struct {
+	connection string
+	values     []string
+	rules      rules.Rules
+	prefetched *[]Service
+}
+
+func (c backendChecker) Status(ctx context.Context, host, service string) Result {
+	r := Result{Timestamp: time.Now(), Message: c.connection, Values: make(map[string]bool)}
+	for _, v := range c.values {
+		r.Values[v] = true
+	}
+	return r
+}
+
+func (c backendChecker) Prefetch(ctx context.Context, services []Service) error {
+	*c.prefetched = append(*c.prefetched, services...)
+	return nil
+}
+
+func (c backendChecker) Values() []string          { return c.values }
+func (c backendChecker) DefaultRules() rules.Rules { return c.rules }
+func (c backendChecker) Health() (string, error) {
+	if c.connection == "broken" {
+		return "", errors.New("unreachable")
+	}
+	return "fine", nil
+}
+
+var prefetched []Service
+
+var backendRules = map[string]rules.Rules{
+	"a": {
+		10:   rules.Rule{Must: []string{"failed"}, Then: status.StatusUnknown},
+		20:   rules.Rule{Must: []string{"critical"}, Then: status.StatusNOK},
+		9999: rules.Rule{Then: status.StatusOK},
+	},
+	"b": {
+		10:   rules.Rule{Must: []string{"failed"}, Then: status.StatusUnknown},
+		20:   rules.Rule{Must: []string{"firing"}, MustNot: []string{"silenced"}, Then: status.StatusNOK},
+		9999: rules.Rule{Then: status.StatusOK},
+	},
+}
+
+var backendValues = map[string][]string{
+	"a": {"failed", "critical"},
+	"b": {"failed", "firing", "silenced"},
+}
+
+func init() {
+	for _, kind := range []string{"a", "b"} {
+		kind := kind
+		Register("test_"+kind, func(conf Config) (Checker, error) {
+			return backendChecker{
+				connection: conf.Connection,
+				values:     backendValues[kind],
+				rules:      backendRules[kind],
+				prefetched: &prefetched,
+			}, nil
+		})
+	}
+}
+
+func newTestMulti(t *testing.T, backends ...Config) Checker {
+	chk, err := New(Config{Kind: KindMulti, Backends: backends})
+	if err != nil {
+		t.Fatalf("Could not set up multi checker: %s", err.Error())
+	}
+	return chk
+}
+
+func TestMultiRouting(t *testing.T) {
+	chk := newTestMulti(t,
+		Config{Name: "dc1", Kind: "test_a", Connection: "dc1"},
+		Config{Name: "dc2", Kind: "test_b", Connection: "dc2"},
+	)
+
+	tests := map[string]string{"": "dc1", "dc1": "dc1", "dc2": "dc2"}
+	for backend, expected := range tests {
+		r := StatusOf(context.Background(), chk, backend, "host", "service")
+		if r.Error != nil {
+			t.Errorf("Unexpected error for backend '%s': %s", backend, r.Error.Error())
+		}
+		if r.Message != expected {
+			t.Errorf("Expected request for backend '%s' to be routed to '%s', got '%s'", backend, expected, r.Message)
+		}
+	}
+
+	r := StatusOf(context.Background(), chk, "dc3", "host", "service")
+	if r.Error == nil {
+		t.Errorf("Error expected for nonexistent backend but got nil")
+	}
+	if HasBackend(chk, "dc3") || !HasBackend(chk, "dc2") || !HasBackend(chk, "") {
+		t.Errorf("HasBackend returned unexpected results")
+	}
+}
+
+func TestMultiValues(t *testing.T) {
+	chk := newTestMulti(t,
+		Config{Name: "dc1", Kind: "test_a", Connection: "dc1"},
+		Config{Name: "dc2", Kind: "test_b", Connection: "dc2"},
+	)
+
+	expected := []string{"critical", "failed", "firing", "silenced"}
+	if values := chk.Values(); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected values '%v', got '%v'", expected, values)
+	}
+
+	r := StatusOf(context.Background(), chk, "dc1", "host", "service")
+	expectedValues := map[string]bool{"critical": true, "failed": true, "firing": false, "silenced": false}
+	if !reflect.DeepEqual(r.Values, expectedValues) {
+		t.Errorf("Expected values of result to be completed '%v', got '%v'", expectedValues, r.Values)
+	}
+	if _, _, err := chk.DefaultRules().Analyze(r.Values); err != nil {
+		t.Errorf("Merged rules cannot be applied to completed values: %s", err.Error())
+	}
+}
+
+func TestMultiDefaultRules(t *testing.T) {
+	same := newTestMulti(t,
+		Config{Name: "dc1", Kind: "test_a", Connection: "dc1"},
+		Config{Name: "dc2", Kind: "test_a", Connection: "dc2"},
+	)
+	if !reflect.DeepEqual(same.DefaultRules(), backendRules["a"]) {
+		t.Errorf("Expected rules of backends of the same kind to be unchanged, got '%v'", same.DefaultRules())
+	}
+
+	mixed := newTestMulti(t,
+		Config{Name: "dc1", Kind: "test_a", Connection: "dc1"},
+		Config{Name: "dc2", Kind: "test_b", Connection: "dc2"},
+	)
+	expected := rules.Rules{
+		10:   backendRules["a"][10],
+		20:   backendRules["a"][20],
+		21:   backendRules["b"][20],
+		9999: backendRules["a"][9999],
+	}
+	if !reflect.DeepEqual(mixed.DefaultRules(), expected) {
+		t.Errorf("Expected merged rules '%v', got '%v'", expected, mixed.DefaultRules())
+	}
+}
+
+func TestMergeRules(t *testing.T) {
+	expression := rules.Rule{Expression: "firing and not silenced", Then: status.StatusNOK}
+	other := rules.Rule{Then: status.StatusUnknown}
+	sets := []rules.Rules{
+		{20: backendRules["a"][20], 21: backendRules["a"][10], 9999: backendRules["a"][9999]},
+		{20: expression, 9999: other},
+	}
+	expected := rules.Rules{
+		20:    backendRules["a"][20],
+		21:    backendRules["a"][10],
+		22:    expression,
+		9999:  backendRules["a"][9999],
+		10000: other,
+	}
+
+	for i := 0; i < 10; i++ {
+		if merged := mergeRules(sets); !reflect.DeepEqual(merged, expected) {
+			t.Fatalf("Expected merged rules '%v', got '%v'", expected, merged)
+		}
+	}
+}
+
+func TestMultiPrefetch(t *testing.T) {
+	chk := newTestMulti(t,
+		Config{Name: "dc1", Kind: "test_a", Connection: "dc1"},
+		Config{Name: "dc2", Kind: "test_b", Connection: "dc2"},
+	)
+	prefetched = nil
+
+	services := []Service{
+		{Host: "h", Service: "default"},
+		{Host: "h", Service: "first", Checker: "dc1"},
+		{Host: "h", Service: "second", Checker: "dc2"},
+	}
+	err := chk.(Prefetcher).Prefetch(context.Background(), services)
+	if err != nil {
+		t.Fatalf("Unexpected error while prefetching: %s", err.Error())
+	}
+	expected := []Service{services[0], services[1], services[2]}
+	if !reflect.DeepEqual(prefetched, expected) {
+		t.Errorf("Expected services '%v' to be prefetched, got '%v'", expected, prefetched)
+	}
+}
+
+func TestMultiHealth(t *testing.T) {
+	chk := newTestMulti(t,
+		Config{Name: "dc1", Kind: "test_a", Connection: "dc1"},
+		Config{Name: "dc2", Kind: "test_b", Connection: "broken"},
+	)
+	msg, err := chk.Health()
+	if err == nil {
+		t.Errorf("Error expected if a backend is not healthy but got nil")
+	}
+	if msg != "dc1: fine\ndc2: " {
+		t.Errorf("Unexpected health message '%s'", msg)
+	}
+}
+
+func TestMultiConfigValidate(t *testing.T) {
+	tests := []struct {
+		conf  Config
+		valid bool
+	}{
+		{
+			conf: Config{Kind: KindMulti, Backends: []Config{
+				{Name: "dc1", Kind: "icinga", Connection: "http://dc1"},
+				{Name: "dc2", Kind: "icinga", Connection: "http://dc2"},
+			}},
+			valid: true,
+		},
+		{
+			conf:  Config{Kind: KindMulti},
+			valid: false,
+		},
+		{
+			conf: Config{Kind: KindMulti, Backends: []Config{
+				{Name: "dc1", Kind: "icinga", Connection: "http://dc1"},
+				{Name: "dc1", Kind: "icinga", Connection: "http://dc2"},
+			}},
+			valid: false,
+		},
+		{
+			conf: Config{Kind: KindMulti, Backends: []Config{
+				{Kind: "icinga", Connection: "http://dc1"},
+			}},
+			valid: false,
+		},
+		{
+			conf: Config{Kind: KindMulti, Backends: []Config{
+				{Name: "dc1", Kind: "icinga"},
+			}},
+			valid: false,
+		},
+		{
+			conf: Config{Kind: "icinga", Connection: "http://dc1", Backends: []Config{
+				{Name: "dc1", Kind: "icinga", Connection: "http://dc1"},
+			}},
+			valid: false,
+		},
+	}
+	for i, test := range tests {
+		errs, err := test.conf.Validate()
+		if test.valid && err != nil {
+			t.Errorf("Config %d expected to be valid, got errors: %v", i, errs)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Config %d expected to be invalid", i)
+		}
+	}
+}
+
+func TestMultiCacheTTL(t *testing.T) {
+	m, err := NewMulti(Config{
+		Kind:     KindMulti,
+		CacheTTL: duration(time.Minute),
+		Backends: []Config{
+			{Name: "dc1", Kind: "test_a", Connection: "dc1"},
+			{Name: "dc2", Kind: "test_b", Connection: "dc2", CacheTTL: duration(0)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Could not set up multi checker: %s", err.Error())
+	}
+
+	expected := map[string]time.Duration{"dc1": time.Minute, "dc2": 0}
+	for name, ttl := range expected {
+		c, ok := m.backends[name].(*Cache)
+		if !ok {
+			t.Errorf("Expected backend '%s' to be wrapped in a cache", name)
+			continue
+		}
+		if c.ttl != ttl {
+			t.Errorf("Expected time to live of backend '%s' to be %s, got %s", name, ttl, c.ttl)
+		}
+	}
+}
+
+func TestMultiStats(t *testing.T) {
+	chk, err := New(Config{Kind: KindMulti, CacheTTL: duration(time.Minute), Backends: []Config{
+		{Name: "dc1", Kind: "test_a", Connection: "dc1"},
+		{Name: "dc2", Kind: "test_b", Connection: "dc2"},
+	}})
+	if err != nil {
+		t.Fatalf("Could not set up multi checker: %s", err.Error())
+	}
+	sr, ok := chk.(StatsReporter)
+	if !ok {
+		t.Fatalf("Expected multi checker to report the stats of its backends")
+	}
+
+	StatusOf(context.Background(), chk, "dc1", "host", "service")
+	StatusOf(context.Background(), chk, "dc1", "host", "service")
+	StatusOf(context.Background(), chk, "dc2", "host", "service")
+
+	hits, misses := sr.Stats()
+	if hits != 1 || misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses summed up over all backends, got %d hits and %d misses", hits, misses)
+	}
+}
@@ -0,0 +1,33 @@
+package prometheus
+
+type flag string
+
+const (
+	FlagFiring    flag = "firing"
+	FlagPending   flag = "pending"
+	FlagSilenced  flag = "silenced"
+	FlagInhibited flag = "inhibited"
+	FlagFailed    flag = "failed"
+)
+
+func (f flag) String() string {
+	return string(f)
+}
+
+type flags map[flag]bool
+
+var flagDefaults = flags{
+	FlagFiring:    false,
+	FlagPending:   false,
+	FlagSilenced:  false,
+	FlagInhibited: false,
+	FlagFailed:    true,
+}
+
+func (f flags) ToValues() map[string]bool {
+	out := make(map[string]bool)
+	for k, v := range flagDefaults {
+		out[k.String()] = v
+	}
+	return out
+}
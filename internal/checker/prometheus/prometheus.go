@@ -0,0 +1,322 @@
+// Package prometheus provides a 'Checker' implementation based on Prometheus
+// alerts. The host of a service is interpreted as the name of an alert, the
+// service as label selector of the alert. Silences and inhibitions are read
+// from the Alertmanager if configured.
+package prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/status"
+)
+
+// init registers the 'Checker' implementation.
+func init() {
+	checker.Register("prometheus", Setup)
+}
+
+// Setup configures the 'Checker' implementation and returns it. The URL of
+// the Alertmanager is read from the optional 'alertmanager' parameter of the
+// connection string.
+func Setup(conf checker.Config) (checker.Checker, error) {
+	u, err := url.Parse(conf.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: conf.TLSSkipVerify},
+		},
+		Timeout: conf.Timeout,
+	}
+
+	var am *endpoint
+	if raw := u.Query().Get("alertmanager"); raw != "" {
+		amURL, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("alertmanager url is invalid: %s", err.Error())
+		}
+		am = newEndpoint(amURL, client)
+	}
+	u.RawQuery = ""
+
+	p := Prometheus{
+		f: api{
+			prometheus:   newEndpoint(u, client),
+			alertmanager: am,
+		},
+		snapshot: &snapshot{},
+	}
+	return p, nil
+}
+
+// Prometheus holds the 'Checker' implementation. It allows BPMON to derive
+// the status of a service from alerts of Prometheus and the Alertmanager.
+type Prometheus struct {
+	f        fetcher
+	snapshot *snapshot
+}
+
+// snapshot keeps the alerts fetched when services are prefetched.
+type snapshot struct {
+	sync.RWMutex
+	valid  bool
+	alerts alerts
+}
+
+func (s *snapshot) get() (a alerts, ok bool) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.alerts, s.valid
+}
+
+func (s *snapshot) set(a alerts, valid bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.alerts = a
+	s.valid = valid
+}
+
+// DefaultRules implements the 'Checker' interface.
+func (p Prometheus) DefaultRules() rules.Rules {
+	rules := rules.Rules{
+		10: rules.Rule{
+			Must:    []string{FlagFailed.String()},
+			MustNot: []string{},
+			Then:    status.StatusUnknown,
+		},
+		20: rules.Rule{
+			Must:    []string{FlagFiring.String()},
+			MustNot: []string{FlagSilenced.String(), FlagInhibited.String()},
+			Then:    status.StatusNOK,
+		},
+		9999: rules.Rule{
+			Must:    []string{},
+			MustNot: []string{},
+			Then:    status.StatusOK,
+		},
+	}
+	return rules
+}
+
+// Values implements the 'Checker' interface.
+func (p Prometheus) Values() []string {
+	var out []string
+	for key := range flagDefaults {
+		out = append(out, key.String())
+	}
+	return out
+}
+
+// Health implements the 'Checker' interface.
+func (p Prometheus) Health() (string, error) {
+	return p.f.Health()
+}
+
+// Status implements the 'Checker' interface. 'host' is the name of the alert,
+// 'service' a label selector such as 'instance="db1",job=~"mysql|postgres"'.
+func (p Prometheus) Status(ctx context.Context, host string, service string) checker.Result {
+	r := checker.Result{
+		Timestamp: time.Now(),
+		Values:    flagDefaults.ToValues(),
+	}
+
+	sel, err := newSelector(host, service)
+	if err != nil {
+		r.Error = err
+		return r
+	}
+
+	a, ok := p.snapshot.get()
+	if !ok {
+		a, err = p.f.FetchAll(ctx)
+		if err != nil {
+			r.Error = err
+			return r
+		}
+	}
+
+	r.Message, r.Values = a.status(sel)
+	return r
+}
+
+// Prefetch implements the 'Prefetcher' interface. Since all alerts are
+// fetched at once, the services provided are not relevant.
+func (p Prometheus) Prefetch(ctx context.Context, services []checker.Service) error {
+	a, err := p.f.FetchAll(ctx)
+	if err != nil {
+		p.snapshot.set(alerts{}, false)
+		return err
+	}
+	p.snapshot.set(a, true)
+	return nil
+}
+
+// status evaluates the alerts matching the selector. A service is 'silenced'
+// or 'inhibited' if all of its alerts known to the Alertmanager are.
+func (a alerts) status(sel selector) (msg string, vals map[string]bool) {
+	vals = flagDefaults.ToValues()
+	vals[FlagFailed.String()] = false
+
+	var firing []string
+	pending := 0
+	for _, alert := range a.rules {
+		if !sel.matches(alert.Labels) {
+			continue
+		}
+		switch alert.State {
+		case stateFiring:
+			vals[FlagFiring.String()] = true
+			firing = append(firing, alert.describe())
+		case statePending:
+			vals[FlagPending.String()] = true
+			pending++
+		}
+	}
+
+	managed := 0
+	silenced := true
+	inhibited := true
+	for _, alert := range a.managed {
+		if !sel.matches(alert.Labels) {
+			continue
+		}
+		managed++
+		silenced = silenced && len(alert.Status.SilencedBy) > 0
+		inhibited = inhibited && len(alert.Status.InhibitedBy) > 0
+	}
+	if managed > 0 {
+		vals[FlagSilenced.String()] = silenced
+		vals[FlagInhibited.String()] = inhibited
+	}
+
+	sort.Strings(firing)
+	msg = fmt.Sprintf("%d firing, %d pending", len(firing), pending)
+	if len(firing) > 0 {
+		msg = msg + ": " + strings.Join(firing, ", ")
+	}
+	return
+}
+
+// describe returns the summary of an alert if annotated, its labels
+// otherwise.
+func (a Alert) describe() string {
+	if summary, ok := a.Annotations["summary"]; ok {
+		return summary
+	}
+	var labels []string
+	for k, v := range a.Labels {
+		if k != "alertname" {
+			labels = append(labels, fmt.Sprintf("%s=%q", k, v))
+		}
+	}
+	sort.Strings(labels)
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+type fetcher interface {
+	FetchAll(context.Context) (alerts, error)
+	Health() (string, error)
+}
+
+type api struct {
+	prometheus   *endpoint
+	alertmanager *endpoint
+}
+
+// FetchAll requests all alerts from Prometheus and, if configured, from the
+// Alertmanager.
+func (a api) FetchAll(ctx context.Context) (alerts, error) {
+	var out alerts
+
+	var response RulesResponse
+	body, err := a.prometheus.do(ctx, "/api/v1/alerts")
+	if err != nil {
+		return out, err
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return out, err
+	}
+	if response.Status != "success" {
+		return out, fmt.Errorf("Prometheus API returned status '%s': %s", response.Status, response.Error)
+	}
+	out.rules = response.Data.Alerts
+
+	if a.alertmanager == nil {
+		return out, nil
+	}
+	body, err = a.alertmanager.do(ctx, "/api/v2/alerts")
+	if err != nil {
+		return out, err
+	}
+	err = json.Unmarshal(body, &out.managed)
+	return out, err
+}
+
+func (a api) Health() (string, error) {
+	body, err := a.prometheus.do(context.Background(), "/-/healthy")
+	if err != nil || a.alertmanager == nil {
+		return string(body), err
+	}
+	amBody, err := a.alertmanager.do(context.Background(), "/-/healthy")
+	return string(body) + string(amBody), err
+}
+
+// endpoint is the base url of either Prometheus or the Alertmanager.
+type endpoint struct {
+	baseURL string
+	user    string
+	pass    string
+	client  *http.Client
+}
+
+func newEndpoint(u *url.URL, client *http.Client) *endpoint {
+	password, _ := u.User.Password()
+	return &endpoint{
+		baseURL: fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, strings.TrimSuffix(u.Path, "/")),
+		user:    u.User.Username(),
+		pass:    password,
+		client:  client,
+	}
+}
+
+func (e *endpoint) do(ctx context.Context, path string) ([]byte, error) {
+	var body []byte
+
+	req, err := http.NewRequest("GET", e.baseURL+path, nil)
+	if err != nil {
+		return body, err
+	}
+	req = req.WithContext(ctx)
+	if e.user != "" {
+		req.SetBasicAuth(e.user, e.pass)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return body, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = errors.New("HTTP error " + resp.Status)
+		return body, err
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,239 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/status"
+)
+
+const prometheusAlerts = `
+{
+  "status": "success",
+  "data": {
+    "alerts": [
+      {
+        "labels": {"alertname": "InstanceDown", "instance": "db1", "job": "mysql"},
+        "annotations": {"summary": "db1 is down"},
+        "state": "firing"
+      },
+      {
+        "labels": {"alertname": "InstanceDown", "instance": "db2", "job": "mysql"},
+        "annotations": {},
+        "state": "firing"
+      },
+      {
+        "labels": {"alertname": "InstanceDown", "instance": "web1", "job": "nginx"},
+        "annotations": {},
+        "state": "pending"
+      }
+    ]
+  }
+}`
+
+const alertmanagerAlerts = `
+[
+  {
+    "labels": {"alertname": "InstanceDown", "instance": "db1", "job": "mysql"},
+    "status": {"state": "active", "silencedBy": [], "inhibitedBy": []}
+  },
+  {
+    "labels": {"alertname": "InstanceDown", "instance": "db2", "job": "mysql"},
+    "status": {"state": "suppressed", "silencedBy": ["8a1f"], "inhibitedBy": []}
+  }
+]`
+
+func newTestServer(t *testing.T, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		switch r.URL.Path {
+		case "/api/v1/alerts":
+			w.Write([]byte(prometheusAlerts))
+		case "/api/v2/alerts":
+			w.Write([]byte(alertmanagerAlerts))
+		case "/-/healthy":
+			w.Write([]byte("Healthy.\n"))
+		default:
+			t.Errorf("Unexpected request to '%s'", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func newTestChecker(t *testing.T, connection string) checker.Checker {
+	chk, err := Setup(checker.Config{Connection: connection, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Could not set up checker: %s", err.Error())
+	}
+	return chk
+}
+
+type testset struct {
+	alertname string
+	selector  string
+	values    map[string]bool
+	status    status.Status
+}
+
+var TestSets = []testset{
+	{
+		alertname: "InstanceDown",
+		selector:  `instance="db1"`,
+		values: map[string]bool{
+			"firing": true, "pending": false, "silenced": false, "inhibited": false, "failed": false,
+		},
+		status: status.StatusNOK,
+	},
+	{
+		alertname: "InstanceDown",
+		selector:  `instance="db2", job="mysql"`,
+		values: map[string]bool{
+			"firing": true, "pending": false, "silenced": true, "inhibited": false, "failed": false,
+		},
+		status: status.StatusOK,
+	},
+	{
+		alertname: "InstanceDown",
+		selector:  `job=~"my.*"`,
+		values: map[string]bool{
+			"firing": true, "pending": false, "silenced": false, "inhibited": false, "failed": false,
+		},
+		status: status.StatusNOK,
+	},
+	{
+		alertname: "InstanceDown",
+		selector:  `job!="mysql"`,
+		values: map[string]bool{
+			"firing": false, "pending": true, "silenced": false, "inhibited": false, "failed": false,
+		},
+		status: status.StatusOK,
+	},
+	{
+		alertname: "DiskFull",
+		selector:  "",
+		values: map[string]bool{
+			"firing": false, "pending": false, "silenced": false, "inhibited": false, "failed": false,
+		},
+		status: status.StatusOK,
+	},
+	{
+		alertname: "InstanceDown",
+		selector:  `instance="db1",`,
+		values: map[string]bool{
+			"firing": false, "pending": false, "silenced": false, "inhibited": false, "failed": true,
+		},
+		status: status.StatusUnknown,
+	},
+}
+
+func TestStatus(t *testing.T) {
+	var requests int
+	srv := newTestServer(t, &requests)
+	defer srv.Close()
+
+	chk := newTestChecker(t, srv.URL+"?alertmanager="+srv.URL)
+	r := chk.DefaultRules()
+
+	for _, test := range TestSets {
+		result := chk.Status(context.Background(), test.alertname, test.selector)
+		if !reflect.DeepEqual(result.Values, test.values) {
+			t.Errorf("Values for '%s{%s}' are wrong, expected '%v', got '%v'", test.alertname, test.selector, test.values, result.Values)
+		}
+		st, _, err := r.Analyze(result.Values)
+		if err != nil {
+			t.Errorf("Error returned while analyzing values of '%s{%s}': %s", test.alertname, test.selector, err.Error())
+		}
+		if st != test.status {
+			t.Errorf("Status for '%s{%s}' is wrong, expected '%s', got '%s'", test.alertname, test.selector, test.status, st)
+		}
+	}
+}
+
+func TestStatusMessage(t *testing.T) {
+	var requests int
+	srv := newTestServer(t, &requests)
+	defer srv.Close()
+
+	chk := newTestChecker(t, srv.URL)
+	result := chk.Status(context.Background(), "InstanceDown", `job="mysql"`)
+	expected := `2 firing, 0 pending: db1 is down, {instance="db2",job="mysql"}`
+	if result.Message != expected {
+		t.Errorf("Message is wrong, expected '%s', got '%s'", expected, result.Message)
+	}
+	if result.Values["silenced"] {
+		t.Errorf("Alerts must not be silenced if no alertmanager is configured")
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	var requests int
+	srv := newTestServer(t, &requests)
+	defer srv.Close()
+
+	chk := newTestChecker(t, srv.URL+"?alertmanager="+srv.URL)
+	p, ok := chk.(checker.Prefetcher)
+	if !ok {
+		t.Fatalf("Prometheus checker does not implement the Prefetcher interface")
+	}
+
+	err := p.Prefetch(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Error returned while prefetching: %s", err.Error())
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests while prefetching, got %d", requests)
+	}
+
+	for _, test := range TestSets {
+		chk.Status(context.Background(), test.alertname, test.selector)
+	}
+	if requests != 2 {
+		t.Errorf("Expected all services to be answered from the prefetched alerts, got %d requests", requests)
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	chk := newTestChecker(t, srv.URL)
+	result := chk.Status(context.Background(), "InstanceDown", "")
+	if result.Error == nil {
+		t.Errorf("Error expected but got nil")
+	}
+	if !result.Values["failed"] {
+		t.Errorf("Value 'failed' expected to be true")
+	}
+}
+
+func TestSelector(t *testing.T) {
+	tests := []struct {
+		in    string
+		valid bool
+	}{
+		{in: "", valid: true},
+		{in: `a="b"`, valid: true},
+		{in: ` a = "b" , c !~ "d|e" `, valid: true},
+		{in: `a="b\"c"`, valid: true},
+		{in: `a=b`, valid: false},
+		{in: `a="b",`, valid: false},
+		{in: `a=~"("`, valid: false},
+		{in: `a="b" c="d"`, valid: false},
+	}
+	for _, test := range tests {
+		_, err := newSelector("alert", test.in)
+		if test.valid && err != nil {
+			t.Errorf("Selector '%s' expected to be valid, got error: %s", test.in, err.Error())
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Selector '%s' expected to be invalid", test.in)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package prometheus
+
+// RulesResponse describes the response of the Prometheus API when the
+// active alerts are requested via /api/v1/alerts.
+type RulesResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Alerts []Alert `json:"alerts"`
+	} `json:"data"`
+}
+
+// Alert is a part of the Prometheus API response. State is either 'pending'
+// or 'firing'.
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	State       string            `json:"state"`
+}
+
+// ManagedAlert is an alert as returned by the Alertmanager API when the alerts
+// are requested via /api/v2/alerts.
+type ManagedAlert struct {
+	Labels      map[string]string  `json:"labels"`
+	Annotations map[string]string  `json:"annotations"`
+	Status      ManagedAlertStatus `json:"status"`
+}
+
+// ManagedAlertStatus is a part of the Alertmanager API response.
+type ManagedAlertStatus struct {
+	State       string   `json:"state"`
+	SilencedBy  []string `json:"silencedBy"`
+	InhibitedBy []string `json:"inhibitedBy"`
+}
+
+const (
+	statePending = "pending"
+	stateFiring  = "firing"
+)
+
+// alerts holds all alerts known to Prometheus and Alertmanager at a given
+// time.
+type alerts struct {
+	rules   []Alert
+	managed []ManagedAlert
+}
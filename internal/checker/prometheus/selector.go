@@ -0,0 +1,78 @@
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matcher matches a single label as in a PromQL label selector.
+type matcher struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (m matcher) matches(labels map[string]string) bool {
+	v := labels[m.name]
+	switch m.op {
+	case "=":
+		return v == m.value
+	case "!=":
+		return v != m.value
+	case "=~":
+		return m.re.MatchString(v)
+	case "!~":
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+// selector is a list of matchers which must all match.
+type selector []matcher
+
+func (s selector) matches(labels map[string]string) bool {
+	for _, m := range s {
+		if !m.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+var matcherRegexp = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*")\s*(,|$)`)
+
+// newSelector returns a selector matching all alerts with the name provided
+// as well as the labels selected by 'labels'. 'labels' uses the syntax of a
+// PromQL label selector without the curly braces, eg.
+// 'instance="db1",job=~"mysql|postgres"'.
+func newSelector(alertname, labels string) (selector, error) {
+	s := selector{{name: "alertname", op: "=", value: alertname}}
+
+	rest := strings.TrimSpace(labels)
+	for rest != "" {
+		m := matcherRegexp.FindStringSubmatch(rest)
+		if m == nil {
+			return s, fmt.Errorf("label selector '%s' is invalid near '%s'", labels, rest)
+		}
+		value, err := strconv.Unquote(m[3])
+		if err != nil {
+			return s, fmt.Errorf("label selector '%s' is invalid: %s", labels, err.Error())
+		}
+		lm := matcher{name: m[1], op: m[2], value: value}
+		if lm.op == "=~" || lm.op == "!~" {
+			lm.re, err = regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return s, fmt.Errorf("label selector '%s' is invalid: %s", labels, err.Error())
+			}
+		}
+		s = append(s, lm)
+		rest = rest[len(m[0]):]
+		if m[4] == "," && strings.TrimSpace(rest) == "" {
+			return s, fmt.Errorf("label selector '%s' is invalid: trailing comma", labels)
+		}
+	}
+	return s, nil
+}
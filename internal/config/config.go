@@ -8,7 +8,9 @@ import (
 
 	"github.com/unprofession-al/bpmon/internal/availabilities"
 	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/daemon"
 	"github.com/unprofession-al/bpmon/internal/dashboard"
+	"github.com/unprofession-al/bpmon/internal/exporter"
 	"github.com/unprofession-al/bpmon/internal/rules"
 	"github.com/unprofession-al/bpmon/internal/store"
 	yaml "gopkg.in/yaml.v2"
@@ -83,15 +85,27 @@ type ConfigSection struct {
 
 	// Define your office hours et al. according to your service level
 	// agreements (SLA). You will reference themlater in your BP definitions.
+	// Add timezone (eg. Europe/Zurich) to an availability in order to evaluate
+	// its time ranges in that timezone rather than the local timezone. Use
+	// exclude, holidays and extra to define exceptions for specific dates.
 	Availabilities availabilities.AvailabilitiesConfig `yaml:"availabilities"`
 
 	// Extend the default rules. The default rules are provided by the checker implementation
-	// and can be reviewed via bpmon config print.
+	// and can be reviewed via bpmon config print. The status a rule results in ('then') is
+	// one of 'ok', 'degraded' (or 'warning'), 'unknown' and 'not ok'.
 	Rules rules.Rules `yaml:"rules"`
 
 	// dashboard configures the dashboard subcommand.
 	Dashboard dashboard.Config `yaml:"dashboard"`
 
+	// daemon configures the serve subcommand which evaluates and persists all
+	// business processes periodically.
+	Daemon daemon.Config `yaml:"daemon"`
+
+	// exporter configures the exporter subcommand which exposes the status of
+	// all business processes as prometheus metrics.
+	Exporter exporter.Config `yaml:"exporter"`
+
 	// env allows you to setup your configuration file structure according to your
 	// requirements.
 	Env EnvConfig `yaml:"env"`
@@ -104,6 +118,8 @@ func defaultConfigSection() ConfigSection {
 		Checker:   checker.Defaults(),
 		Store:     store.Defaults(),
 		Dashboard: dashboard.Defaults(),
+		Daemon:    daemon.Defaults(),
+		Exporter:  exporter.Defaults(),
 		Env:       EnvDefaults(),
 	}
 }
@@ -124,13 +140,17 @@ func (s ConfigSection) Validate(name string) (out []string, err error) {
 	errs = fmtErrors(s.Dashboard.Validate())
 	out = append(out, errs...)
 
-	errs = fmtErrors(s.Env.Validate())
+	errs = fmtErrors(s.Daemon.Validate())
 	out = append(out, errs...)
 
-	_, aErr := s.Availabilities.Parse()
-	if aErr != nil {
-		out = append(out, fmt.Sprintf("error while parsing availabilities: %s", aErr.Error()))
-	}
+	errs = fmtErrors(s.Exporter.Validate())
+	out = append(out, errs...)
+
+	errs = fmtErrors(s.Availabilities.Validate())
+	out = append(out, errs...)
+
+	errs = fmtErrors(s.Env.Validate())
+	out = append(out, errs...)
 
 	if len(errs) > 0 {
 		err = fmt.Errorf("configuration Section '%s' has errors", name)
@@ -17,16 +17,47 @@ boilerplate consider anchors (http://yaml.org/spec/1.2/spec.html#id2765878)
 Sections other than 'default' can be used via the -s/--section flag.`
 	doc[section+".availabilities"] = `Define your office hours et al. according to your service level
 agreements (SLA). You will reference themlater in your BP definitions.
+Add timezone (eg. Europe/Zurich) to an availability in order to evaluate
+its time ranges in that timezone rather than the local timezone. Use
+exclude, holidays and extra to define exceptions for specific dates.
 `
 	doc[section+".checker"] = `First BPMON needs to have access to your Icinga2 API. Learn more on by reading
 https://docs.icinga.com/icinga2/latest/doc/module/icinga2/chapter/icinga2-api.
+`
+	doc[section+".checker.backends"] = `backends is a list of checkers used if kind is multi. Each backend is
+configured as a checker itself and must have a unique name. Services
+which do not specify a backend are checked by the first backend. The
+fields timeout, cache_ttl and max_parallel are inherited if not set in
+a backend. The connection of the multi checker itself is not used.
+`
+	doc[section+".checker.cache_ttl"] = `cache_ttl defines how long the result of a check is reused when the
+same host and service is referenced multiple times, eg. by several
+business processes. Concurrent requests for the same check are always
+merged into one request. Set to 0 to disable the reuse of results. This
+should be shorter than the interval of the serve subcommand.
 `
 	doc[section+".checker.connection"] = `The connection string describes how to connect to your Icinga API. The
 string needs to follow the pattern:
   [protocol]://[user]:[passwd]@[hostname]:[port]
+If kind is prometheus, the connection string points to the Prometheus
+API. The Alertmanager can be added as parameter to read silences and
+inhibitions:
+  [protocol]://[hostname]:[port]?alertmanager=[protocol]://[hostname]:[port]
 `
 	doc[section+".checker.kind"] = `kind defines the checker implementation to be used by BPMON. Currently
-only icinga is implemented.
+icinga and prometheus are implemented. Use multi in order to check the
+services using several checkers, see backends.
+`
+	doc[section+".checker.max_parallel"] = `max_parallel limits the number of requests sent to the checker at the
+same time. Set to 0 to send all requests at once.
+`
+	doc[section+".checker.name"] = `name identifies a backend if kind is multi. Services of a business
+process refer to the backend via its name.
+`
+	doc[section+".checker.run_timeout"] = `run_timeout defines how long BPMON waits for all checks of a single run
+to complete. Checks not completed in time are considered 'unknown'.
+Set to 0 to wait until all checks are completed. The string is parsed as
+a golang duration.
 `
 	doc[section+".checker.timeout"] = `timeout defines how long BPMON waits for each request to the checker to
 receive a response. The string is parsed as a goland duration, refer to
@@ -35,11 +66,21 @@ its documentation for more details:
 `
 	doc[section+".checker.tls_skip_verify"] = `BPMON verifies if a https connection is trusted. If you wont to trust a
 connection with an invalid certificate you have to set this to true.
+`
+	doc[section+".daemon"] = `daemon configures the serve subcommand which evaluates and persists all
+business processes periodically.
+`
+	doc[section+".daemon.interval"] = `interval defines how often the serve subcommand evaluates and persists
+all business processes. The same interval is used to calculate the spans
+of entities whose 'ok' states are not persisted, eg. in the dashboard.
+The string is parsed as a golang duration, refer to its documentation
+for more details:
+  https://golang.org/pkg/time/#ParseDuration
 `
 	doc[section+".dashboard"] = `dashboard configures the dashboard subcommand.
 `
 	doc[section+".dashboard.grant_write"] = `grant_write is a list of recipients which are allowed to access the annotate
-endpoint via POST request.
+endpoint via POST request and to create and delete maintenance windows.
 `
 	doc[section+".dashboard.listener"] = `listener tells the dashboard where to bind. This string
 should match the pattern [ip]:[port].
@@ -53,14 +94,26 @@ requirements.
 `
 	doc[section+".env.bp"] = `bp is the directory where your business process definitions are stored. The path must be
 relative to your base directory (-b/--base). The path must exist.
+`
+	doc[section+".env.maintenance"] = `maintenance is the directory where your maintenance windows are stored. The path must be
+relative to your base directory (-b/--base). Maintenance windows created via the dashboard
+are stored in this directory as well.
 `
 	doc[section+".env.runner"] = `runners is the directory where your custom runners are stored. The path must be
 relative to your base directory (-b/--base). The path must exist.
+`
+	doc[section+".exporter"] = `exporter configures the exporter subcommand which exposes the status of
+all business processes as prometheus metrics.
+`
+	doc[section+".exporter.listener"] = `listener tells the exporter where to bind. This string
+should match the pattern [ip]:[port]. The metrics are served
+at the path /metrics.
 `
 	doc[section+".global_recipients"] = `global_recipients will be added to the repicients list of all BP
 `
 	doc[section+".rules"] = `Extend the default rules. The default rules are provided by the checker implementation
-and can be reviewed via bpmon config print.
+and can be reviewed via bpmon config print. The status a rule results in ('then') is
+one of 'ok', 'degraded' (or 'warning'), 'unknown' and 'not ok'.
 `
 	doc[section+".store"] = `The connection to the InfluxDB is required in order to persist the the state, eg.
 the write subcommand.
@@ -68,9 +121,14 @@ the write subcommand.
 	doc[section+".store.connection"] = `The connection string describes how to connect to your Influx Database.
 The string needs to follow the pattern:
   [protocol]://[user]:[passwd]@[hostname]:[port]
+If kind is sql, the scheme selects the database:
+  sqlite3://[path to database file]
+  postgres://[user]:[passwd]@[hostname]:[port]/[database]?sslmode=disable
+If kind is memory, use memory:// to keep the data in memory only or
+file://[path] to persist the data to a file.
 `
 	doc[section+".store.debug"] = `if debug is set to true all queries generated and executed by bpmon will
-be logged to stdout.
+be logged to stdout. Results are then printed rather than written.
 `
 	doc[section+".store.get_last_status"] = `This will tell BPMON to compare the current status against the last
 status saved in InfluxDB and adds some values to the measurement
@@ -79,7 +137,7 @@ only when a status is changed from good to bad'. This only runs against
 types listed in 'save_ok' since only these are persisted 'correctly'.
 `
 	doc[section+".store.kind"] = `kind defines the store implementation to be used by BPMON. Currently
-only influx is implemented.
+influx, sql and memory are implemented.
 `
 	doc[section+".store.save_ok"] = `save_ok tells BPMON which data points should be persisted if the state is 'ok'.
 By default 'OK' states aro only saved to InfluxDB if its an BP measurement.
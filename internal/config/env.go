@@ -10,12 +10,18 @@ type EnvConfig struct {
 	// bp is the directory where your business process definitions are stored. The path must be
 	// relative to your base directory (-b/--base). The path must exist.
 	BP string `yaml:"bp"`
+
+	// maintenance is the directory where your maintenance windows are stored. The path must be
+	// relative to your base directory (-b/--base). Maintenance windows created via the dashboard
+	// are stored in this directory as well.
+	Maintenance string `yaml:"maintenance"`
 }
 
 func EnvDefaults() EnvConfig {
 	return EnvConfig{
-		Runners: "runners/",
-		BP:      "bp.d/",
+		Runners:     "runners/",
+		BP:          "bp.d/",
+		Maintenance: "maintenance.d/",
 	}
 }
 
@@ -27,6 +33,9 @@ func (env EnvConfig) Validate() ([]string, error) {
 	if env.BP == "" {
 		errs = append(errs, "Field 'bp' cannot be empty.")
 	}
+	if env.Maintenance == "" {
+		errs = append(errs, "Field 'maintenance' cannot be empty.")
+	}
 	if len(errs) > 0 {
 		err := errors.New("Config of 'env' has errors")
 		return errs, err
@@ -0,0 +1,34 @@
+package daemon
+
+import (
+	"errors"
+	"time"
+)
+
+type Config struct {
+	// interval defines how often the serve subcommand evaluates and persists
+	// all business processes. The same interval is used to calculate the spans
+	// of entities whose 'ok' states are not persisted, eg. in the dashboard.
+	// The string is parsed as a golang duration, refer to its documentation
+	// for more details:
+	//   https://golang.org/pkg/time/#ParseDuration
+	Interval time.Duration `yaml:"interval"`
+}
+
+func Defaults() Config {
+	return Config{
+		Interval: time.Duration(5 * time.Minute),
+	}
+}
+
+func (dc Config) Validate() ([]string, error) {
+	errs := []string{}
+	if dc.Interval <= 0 {
+		errs = append(errs, "Field 'interval' must be a positive duration.")
+	}
+	if len(errs) > 0 {
+		err := errors.New("Config of 'daemon' has errors")
+		return errs, err
+	}
+	return errs, nil
+}
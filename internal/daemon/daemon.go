@@ -0,0 +1,168 @@
+// Package daemon provides a long running process which evaluates all business
+// processes in a fixed interval and persists the results to the store. It
+// replaces the need to run 'bpmon write' via an external scheduler.
+package daemon
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// Runtime bundles everything the daemon requires to evaluate and persist the
+// business processes.
+type Runtime struct {
+	BP            bpmon.BusinessProcesses
+	Checker       checker.Checker
+	Rules         rules.Rules
+	Store         store.Accessor
+	CheckerConfig checker.Config
+	GetLastStatus bool
+	SaveOK        []string
+}
+
+// Daemon evaluates all business processes of its 'Runtime' once per interval.
+type Daemon struct {
+	verbose bool
+	updated chan struct{}
+
+	// running is held for reading while a run is in progress.
+	running sync.RWMutex
+
+	mu       sync.RWMutex
+	interval time.Duration
+	rt       Runtime
+}
+
+// New returns a configured Daemon. Call 'Run' to start it.
+func New(c Config, rt Runtime, verbose bool) *Daemon {
+	return &Daemon{
+		verbose:  verbose,
+		updated:  make(chan struct{}, 1),
+		interval: c.Interval,
+		rt:       rt,
+	}
+}
+
+// Update replaces the configuration and the 'Runtime' of the daemon. The new
+// runtime is used starting with the next run, a run in progress is not
+// affected. If the interval changes, the next run is rescheduled to one
+// interval after the start of the last run.
+func (d *Daemon) Update(c Config, rt Runtime) {
+	d.mu.Lock()
+	if d.interval != c.Interval {
+		log.Printf("Interval changed from %s to %s", d.interval, c.Interval)
+	}
+	d.interval = c.Interval
+	d.rt = rt
+	d.mu.Unlock()
+
+	select {
+	case d.updated <- struct{}{}:
+	default:
+	}
+}
+
+// Release closes the store 's' once the run in progress, if any, has
+// completed. Call Release with the store replaced via 'Update'.
+func (d *Daemon) Release(s store.Accessor) {
+	go func() {
+		d.running.Lock()
+		defer d.running.Unlock()
+		if err := store.Close(s); err != nil {
+			log.Printf("Could not close the store replaced: %s", err.Error())
+		}
+	}()
+}
+
+func (d *Daemon) runtime() Runtime {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.rt
+}
+
+// next returns the first point in time after 'now' which is a multiple of
+// the interval after 'last' as well as the number of runs skipped.
+func (d *Daemon) next(last time.Time, now time.Time) (time.Time, int) {
+	d.mu.RLock()
+	interval := d.interval
+	d.mu.RUnlock()
+
+	next := last.Add(interval)
+	skipped := 0
+	for !next.After(now) {
+		next = next.Add(interval)
+		skipped++
+	}
+	return next, skipped
+}
+
+// Run evaluates all business processes immediately and then once per
+// interval until the context is cancelled. A run in progress is always
+// completed before Run returns.
+func (d *Daemon) Run(ctx context.Context) {
+	scheduled := time.Now()
+	for {
+		d.process(scheduled)
+
+		last := scheduled
+		next, skipped := d.next(last, time.Now())
+		if skipped > 0 && d.verbose {
+			log.Printf("Run took longer than the interval, skipping %d run(s)", skipped)
+		}
+		for waiting := true; waiting; {
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-d.updated:
+				timer.Stop()
+				next, _ = d.next(last, time.Now())
+			case <-timer.C:
+				waiting = false
+			}
+		}
+		scheduled = next
+	}
+}
+
+func (d *Daemon) process(scheduled time.Time) {
+	d.running.RLock()
+	defer d.running.RUnlock()
+
+	rt := d.runtime()
+	ctx, cancel := rt.CheckerConfig.RunContext(context.Background())
+	defer cancel()
+
+	err := rt.BP.Prefetch(ctx, rt.Checker)
+	if err != nil {
+		log.Printf("Could not prefetch services, fetching one by one: %s", err.Error())
+	}
+
+	if d.verbose {
+		log.Printf("Processing %d business processes", len(rt.BP))
+	}
+	for _, rs := range rt.BP.Status(ctx, rt.Checker, rt.Store, rt.Rules) {
+		if rt.GetLastStatus {
+			rs.AddPreviousStatus(rt.Store, rt.SaveOK)
+		}
+		err = rt.Store.Write(&rs)
+		if err != nil {
+			log.Printf("Error while writing business process %s: %s", rs.ID, err.Error())
+		}
+	}
+	if d.verbose {
+		log.Printf("Run scheduled at %s done in %s", scheduled.Format(time.RFC3339), time.Since(scheduled))
+		if sr, ok := rt.Checker.(checker.StatsReporter); ok {
+			hits, misses := sr.Stats()
+			log.Printf("Checker cache: %d hits, %d misses in total", hits, misses)
+		}
+	}
+}
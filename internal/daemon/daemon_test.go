@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// recorder is a store which sends the ID of each business process written
+// to 'written'. All other methods are not implemented.
+type recorder struct {
+	store.Accessor
+	written chan string
+}
+
+func (r recorder) Write(rs *store.ResultSet) error {
+	r.written <- rs.ID
+	return nil
+}
+
+func runtimeOf(id string, written chan string) Runtime {
+	return Runtime{
+		BP:    bpmon.BusinessProcesses{{ID: id, Name: id}},
+		Store: recorder{written: written},
+	}
+}
+
+func expectWrite(t *testing.T, written chan string, id string, within time.Duration) {
+	t.Helper()
+	select {
+	case got := <-written:
+		if got != id {
+			t.Errorf("Expected business process '%s' to be written, got '%s'", id, got)
+		}
+	case <-time.After(within):
+		t.Errorf("Expected business process '%s' to be written within %s", id, within)
+	}
+}
+
+func TestRun(t *testing.T) {
+	written := make(chan string, 10)
+	d := New(Config{Interval: 20 * time.Millisecond}, runtimeOf("a", written), false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 3; i++ {
+		expectWrite(t, written, "a", time.Second)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Run did not return after the context was cancelled")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	written := make(chan string, 10)
+	d := New(Config{Interval: time.Hour}, runtimeOf("a", written), false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	expectWrite(t, written, "a", time.Second)
+
+	d.Update(Config{Interval: 20 * time.Millisecond}, runtimeOf("b", written))
+	expectWrite(t, written, "b", time.Second)
+	expectWrite(t, written, "b", time.Second)
+}
+
+func TestNext(t *testing.T) {
+	d := New(Config{Interval: time.Minute}, Runtime{}, false)
+	last := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		now     time.Time
+		next    time.Time
+		skipped int
+	}{
+		"in time":      {now: last.Add(10 * time.Second), next: last.Add(time.Minute), skipped: 0},
+		"one late":     {now: last.Add(time.Minute), next: last.Add(2 * time.Minute), skipped: 1},
+		"several late": {now: last.Add(150 * time.Second), next: last.Add(3 * time.Minute), skipped: 2},
+	}
+
+	for name, test := range tests {
+		next, skipped := d.next(last, test.now)
+		if !next.Equal(test.next) || skipped != test.skipped {
+			t.Errorf("Expected next run of '%s' at %s with %d skipped, got %s with %d skipped", name, test.next, test.skipped, next, skipped)
+		}
+	}
+}
+
+// blocking is a store whose writes block until 'proceed' is closed.
+type blocking struct {
+	store.Accessor
+	started chan struct{}
+	proceed chan struct{}
+	closed  chan struct{}
+}
+
+func (b blocking) Write(rs *store.ResultSet) error {
+	b.started <- struct{}{}
+	<-b.proceed
+	return nil
+}
+
+func (b blocking) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestRelease(t *testing.T) {
+	s := blocking{
+		started: make(chan struct{}, 10),
+		proceed: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	rt := Runtime{
+		BP:    bpmon.BusinessProcesses{{ID: "a", Name: "a"}},
+		Store: s,
+	}
+	d := New(Config{Interval: time.Hour}, rt, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	select {
+	case <-s.started:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected run to be started")
+	}
+
+	d.Release(s)
+	select {
+	case <-s.closed:
+		t.Errorf("Store was closed while a run is in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(s.proceed)
+	select {
+	case <-s.closed:
+	case <-time.After(time.Second):
+		t.Errorf("Store was not closed once the run was completed")
+	}
+}
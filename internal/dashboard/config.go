@@ -13,7 +13,7 @@ type Config struct {
 	Static string `yaml:"static"`
 
 	// grant_write is a list of recipients which are allowed to access the annotate
-	// endpoint via POST request.
+	// endpoint via POST request and to create and delete maintenance windows.
 	GrantWrite []string `yaml:"grant_write"`
 }
 
@@ -5,6 +5,8 @@ import (
 	"log"
 	"net/http"
 	"regexp"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
@@ -16,25 +18,81 @@ type Dashboard struct {
 	bp         bpmon.BusinessProcesses
 	store      store.Accessor
 	listener   string
-	handler    http.Handler
+	static     string
 	grantWrite []string
 	auth       bool
+	authPepper string
+	authHeader string
+	interval   time.Duration
+	current    *handler
+
+	// maintenance is the directory maintenance windows are stored in, base
+	// the directory holiday calendars of recurring maintenance windows are
+	// read relative to.
+	maintenance string
+	base        string
+}
+
+// handler holds the http.Handler currently served. It is shared between all
+// copies of a Dashboard and allows to replace the handler while serving.
+type handler struct {
+	sync.RWMutex
+	h http.Handler
+
+	// updating serializes the updates of the dashboard, bp and store are
+	// the business processes and the store of the last update.
+	updating sync.Mutex
+	bp       bpmon.BusinessProcesses
+	store    store.Accessor
+}
+
+func (h *handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	h.RLock()
+	current := h.h
+	h.RUnlock()
+	current.ServeHTTP(res, req)
 }
 
 const (
 	KeyRecipients key = iota
 )
 
-func New(c Config, bp bpmon.BusinessProcesses, store store.Accessor, authPepper string, authHeader string) (Dashboard, string, error) {
-	msg := ""
-
+func New(c Config, bp bpmon.BusinessProcesses, store store.Accessor, interval time.Duration, maintenance string, base string, authPepper string, authHeader string) (Dashboard, string, error) {
 	d := Dashboard{
-		bp:         bp,
-		listener:   c.Listener,
-		store:      store,
-		grantWrite: c.GrantWrite,
+		listener:    c.Listener,
+		static:      c.Static,
+		grantWrite:  c.GrantWrite,
+		authPepper:  authPepper,
+		authHeader:  authHeader,
+		interval:    interval,
+		current:     &handler{},
+		maintenance: maintenance,
+		base:        base,
 	}
 
+	if authPepper != "" && authHeader != "" {
+		return d, "", fmt.Errorf("pepper and recipients-header are set, only one is allowed")
+	}
+
+	return d.Update(bp, store)
+}
+
+// Update replaces the business processes and the store served by the
+// dashboard. Requests in progress are completed using the previous business
+// processes and store. If a pepper is provided the auth hashes are regenerated
+// and returned as part of the message.
+func (d Dashboard) Update(bp bpmon.BusinessProcesses, store store.Accessor) (Dashboard, string, error) {
+	d.current.updating.Lock()
+	defer d.current.updating.Unlock()
+	return d.update(bp, store)
+}
+
+// update implements 'Update', the caller must hold the 'updating' lock.
+func (d Dashboard) update(bp bpmon.BusinessProcesses, store store.Accessor) (Dashboard, string, error) {
+	msg := ""
+	d.bp = bp
+	d.store = store
+
 	r := mux.NewRouter().StrictSlash(true)
 
 	apiRouter := mux.NewRouter()
@@ -48,25 +106,23 @@ func New(c Config, bp bpmon.BusinessProcesses, store store.Accessor, authPepper
 		ProtectPattern:      regexp.MustCompile("^/api/v1/bps/.+"),
 	}
 
-	if authPepper != "" && authHeader != "" {
-		return d, msg, fmt.Errorf("pepper and recipients-header are set, only one is allowed")
-	} else if authPepper == "" && authHeader == "" {
+	if d.authPepper == "" && d.authHeader == "" {
 		d.auth = false
 		msg = "WARNING: No pepper or recipients-header is provided, all information are accessible without auth..."
 		r.Handle("/api/{_:.*}", apiRouter)
-	} else if authHeader != "" {
+	} else if d.authHeader != "" {
 		d.auth = true
-		msg = fmt.Sprintf("Recipients-header is provided, using HTTP Header '%s' to read recipients...\n", authHeader)
+		msg = fmt.Sprintf("Recipients-header is provided, using HTTP Header '%s' to read recipients...\n", d.authHeader)
 		m := HeaderAuth{
-			HeaderName: authHeader,
+			HeaderName: d.authHeader,
 			ContextKey: KeyRecipients,
 		}
 		r.Handle("/api/{_:.*}", alice.New(m.Wrap, authorization.Wrap).Then(apiRouter))
-	} else if authPepper != "" {
+	} else if d.authPepper != "" {
 		d.auth = true
 		var recipientHashes map[string]string
 		msg = fmt.Sprintf("Pepper is provided, generating auth hashes...\n")
-		recipientHashes = bp.GenerateRecipientHashes(authPepper)
+		recipientHashes = bp.GenerateRecipientHashes(d.authPepper)
 		msg = msg + fmt.Sprintf("%15s: %s\n", "Recipient", "Hash")
 		for k, v := range recipientHashes {
 			msg = msg + fmt.Sprintf("%15s: %s\n", v, k)
@@ -79,18 +135,22 @@ func New(c Config, bp bpmon.BusinessProcesses, store store.Accessor, authPepper
 		r.Handle("/api/{_:.*}", alice.New(m.Wrap, authorization.Wrap).Then(apiRouter))
 	}
 
-	if c.Static != "" {
-		r.PathPrefix("/").Handler(http.FileServer(http.Dir(c.Static)))
+	if d.static != "" {
+		r.PathPrefix("/").Handler(http.FileServer(http.Dir(d.static)))
 	}
 
-	d.handler = alice.New().Then(r)
+	d.current.Lock()
+	d.current.h = alice.New().Then(r)
+	d.current.bp = bp
+	d.current.store = store
+	d.current.Unlock()
 
 	return d, msg, nil
 }
 
 func (d Dashboard) Run() {
 	fmt.Printf("Serving Dashboard at http://%s\nPress CTRL-c to stop...\n", d.listener)
-	log.Fatal(http.ListenAndServe(d.listener, d.handler))
+	log.Fatal(http.ListenAndServe(d.listener, d.current))
 }
 
 func (d Dashboard) getRoutes() map[string]Leafs {
@@ -110,6 +170,19 @@ func (d Dashboard) getRoutes() map[string]Leafs {
 					},
 				},
 			},
+			"maintenance": Leaf{
+				E: Endpoints{
+					"GET":  Endpoint{N: "ListMaintenance", H: d.ListMaintenanceHandler},
+					"POST": Endpoint{N: "AddMaintenance", H: d.AddMaintenanceHandler},
+				},
+				L: Leafs{
+					"{id}": Leaf{
+						E: Endpoints{
+							"DELETE": Endpoint{N: "RemoveMaintenance", H: d.RemoveMaintenanceHandler},
+						},
+					},
+				},
+			},
 			"bps": Leaf{
 				E: Endpoints{
 					"GET": Endpoint{N: "ListBPs", H: d.ListBPsHandler},
@@ -120,6 +193,16 @@ func (d Dashboard) getRoutes() map[string]Leafs {
 							"GET": Endpoint{N: "GetBPSpans", H: d.GetBPTimelineHandler},
 						},
 						L: Leafs{
+							"sla": Leaf{
+								E: Endpoints{
+									"GET": Endpoint{N: "GetBPSLA", H: d.GetBPSLAHandler},
+								},
+							},
+							"slo": Leaf{
+								E: Endpoints{
+									"GET": Endpoint{N: "GetBPSLO", H: d.GetBPSLOHandler},
+								},
+							},
 							"kpis": Leaf{
 								E: Endpoints{
 									"GET": Endpoint{N: "ListKPIs", H: d.ListKPIsHandler},
@@ -3,13 +3,18 @@ package dashboard
 import (
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/maintenance"
+	"github.com/unprofession-al/bpmon/internal/report"
 	"github.com/unprofession-al/bpmon/internal/status"
 	"github.com/unprofession-al/bpmon/internal/store"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func (d Dashboard) ListBPsHandler(res http.ResponseWriter, req *http.Request) {
@@ -28,32 +33,33 @@ func (d Dashboard) ListBPsHandler(res http.ResponseWriter, req *http.Request) {
 	Respond(res, req, http.StatusOK, list)
 }
 
-func (d Dashboard) GetBPTimelineHandler(res http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	bpid := vars["bp"]
-
-	// name := ""
-	found := false
+// getBP returns the business process referenced by the path of the request.
+// If the business process does not exist, a 404 is responded and 'false' is
+// returned. Access of the recipients is checked by 'Authorization'.
+func (d Dashboard) getBP(res http.ResponseWriter, req *http.Request) (bpmon.BP, bool) {
+	bpid := mux.Vars(req)["bp"]
 	for _, bp := range d.bp {
 		if bp.ID == bpid {
-			found = true
-			//name = bp.Name
+			return bp, true
 		}
 	}
+	msg := fmt.Sprintf("Business process %s not found", bpid)
+	Respond(res, req, http.StatusNotFound, msg)
+	return bpmon.BP{}, false
+}
 
-	if !found {
-		msg := fmt.Sprintf("Business process %s not found", bpid)
-		Respond(res, req, http.StatusNotFound, msg)
+func (d Dashboard) GetBPTimelineHandler(res http.ResponseWriter, req *http.Request) {
+	bp, ok := d.getBP(res, req)
+	if !ok {
 		return
 	}
 
 	start, end := GetStartEnd(req)
 
 	re := store.ResultSet{
-		Tags: map[store.Kind]string{store.KindBusinessProcess: bpid},
+		Tags: map[store.Kind]string{store.KindBusinessProcess: bp.ID},
 	}
-	interval, _ := time.ParseDuration("300s")
-	points, err := d.store.GetSpans(re, start, end, interval, []status.Status{})
+	points, err := d.store.GetSpans(re, start, end, d.interval, []status.Status{})
 	if err != nil {
 		msg := fmt.Sprintf("An error occurred: %s", err.Error())
 		Respond(res, req, http.StatusInternalServerError, msg)
@@ -63,50 +69,81 @@ func (d Dashboard) GetBPTimelineHandler(res http.ResponseWriter, req *http.Reque
 	Respond(res, req, http.StatusOK, points)
 }
 
-func (d Dashboard) ListKPIsHandler(res http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	bpid := vars["bp"]
+func (d Dashboard) GetBPSLAHandler(res http.ResponseWriter, req *http.Request) {
+	bp, ok := d.getBP(res, req)
+	if !ok {
+		return
+	}
 
-	list := make(map[string]string)
-	found := false
-	for _, bp := range d.bp {
-		if bp.ID == bpid {
-			found = true
-			for _, kpi := range bp.Kpis {
-				list[kpi.ID] = kpi.Name
-			}
-		}
+	start, end := GetStartEnd(req)
+
+	sla, err := report.Get(bp, d.store, start, end, d.interval)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred: %s", err.Error())
+		Respond(res, req, http.StatusInternalServerError, msg)
+		return
 	}
-	if !found {
-		msg := fmt.Sprintf("Business process %s not found", bpid)
+
+	Respond(res, req, http.StatusOK, sla)
+}
+
+func (d Dashboard) GetBPSLOHandler(res http.ResponseWriter, req *http.Request) {
+	bp, ok := d.getBP(res, req)
+	if !ok {
+		return
+	}
+
+	if bp.SLO == nil {
+		msg := fmt.Sprintf("No SLO defined for business process %s", bp.ID)
 		Respond(res, req, http.StatusNotFound, msg)
 		return
 	}
 
-	Respond(res, req, http.StatusOK, list)
+	_, end := GetStartEnd(req)
+
+	var burn bpmon.Window
+	if burnStr := req.URL.Query()["burn"]; len(burnStr) > 0 {
+		var err error
+		burn, err = bpmon.ParseWindow(burnStr[0])
+		if err != nil {
+			msg := fmt.Sprintf("Parameter 'burn' is invalid: %s", err.Error())
+			Respond(res, req, http.StatusBadRequest, msg)
+			return
+		}
+	}
+
+	budget, err := report.GetBudget(bp, d.store, end, time.Duration(burn), d.interval)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred: %s", err.Error())
+		Respond(res, req, http.StatusInternalServerError, msg)
+		return
+	}
+
+	Respond(res, req, http.StatusOK, budget)
 }
 
-func (d Dashboard) GetKPITimelineHandler(res http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	bpid := vars["bp"]
-	kpiid := vars["kpi"]
+func (d Dashboard) ListKPIsHandler(res http.ResponseWriter, req *http.Request) {
+	bp, ok := d.getBP(res, req)
+	if !ok {
+		return
+	}
 
-	bp := bpmon.BP{}
-	found := false
-	for _, currentBP := range d.bp {
-		if currentBP.ID == bpid {
-			found = true
-			bp = currentBP
-		}
+	list := make(map[string]string)
+	for _, kpi := range bp.Kpis {
+		list[kpi.ID] = kpi.Name
 	}
 
-	if !found {
-		msg := fmt.Sprintf("Business process %s not found", bpid)
-		Respond(res, req, http.StatusNotFound, msg)
+	Respond(res, req, http.StatusOK, list)
+}
+
+func (d Dashboard) GetKPITimelineHandler(res http.ResponseWriter, req *http.Request) {
+	bp, ok := d.getBP(res, req)
+	if !ok {
 		return
 	}
+	kpiid := mux.Vars(req)["kpi"]
 
-	found = false
+	found := false
 	for _, currentKPI := range bp.Kpis {
 		if currentKPI.ID == kpiid {
 			found = true
@@ -114,7 +151,7 @@ func (d Dashboard) GetKPITimelineHandler(res http.ResponseWriter, req *http.Requ
 	}
 
 	if !found {
-		msg := fmt.Sprintf("KPI %s of Business process %s not found", kpiid, bpid)
+		msg := fmt.Sprintf("KPI %s of Business process %s not found", kpiid, bp.ID)
 		Respond(res, req, http.StatusNotFound, msg)
 		return
 	}
@@ -122,11 +159,10 @@ func (d Dashboard) GetKPITimelineHandler(res http.ResponseWriter, req *http.Requ
 	start, end := GetStartEnd(req)
 
 	re := store.ResultSet{
-		Tags: map[store.Kind]string{store.KindBusinessProcess: bpid, store.KindKeyPerformanceIndicator: kpiid},
+		Tags: map[store.Kind]string{store.KindBusinessProcess: bp.ID, store.KindKeyPerformanceIndicator: kpiid},
 	}
 
-	interval, _ := time.ParseDuration("300s")
-	points, err := d.store.GetSpans(re, start, end, interval, []status.Status{})
+	points, err := d.store.GetSpans(re, start, end, d.interval, []status.Status{})
 	if err != nil {
 		msg := fmt.Sprintf("An error occurred: %s", err.Error())
 		Respond(res, req, http.StatusInternalServerError, msg)
@@ -139,27 +175,7 @@ func (d Dashboard) GetKPITimelineHandler(res http.ResponseWriter, req *http.Requ
 // TODO: The Handler should allow to validate/sanitize the post body against certain formats
 // such as HTML.
 func (d Dashboard) AnnotateHandler(res http.ResponseWriter, req *http.Request) {
-	if recipients := req.Context().Value(KeyRecipients); recipients != nil {
-		allow := false
-		for _, r := range recipients.([]string) {
-			for _, w := range d.grantWrite {
-				if r == w {
-					allow = true
-					break
-				}
-			}
-			if allow {
-				break
-			}
-		}
-		if !allow {
-			msg := "you are not allowed to annotate"
-			Respond(res, req, http.StatusUnauthorized, msg)
-			return
-		}
-	} else {
-		msg := "No credentials provided"
-		Respond(res, req, http.StatusUnauthorized, msg)
+	if !d.granted(res, req, "annotate") {
 		return
 	}
 	vars := mux.Vars(req)
@@ -182,6 +198,162 @@ func (d Dashboard) AnnotateHandler(res http.ResponseWriter, req *http.Request) {
 	Respond(res, req, http.StatusCreated, out)
 }
 
+// ListMaintenanceHandler lists the maintenance windows. If recipients are
+// known, only maintenance windows of their business processes and those not
+// scoped to a business process are listed.
+func (d Dashboard) ListMaintenanceHandler(res http.ResponseWriter, req *http.Request) {
+	windows, err := maintenance.Load(d.maintenance, d.base)
+	if err != nil {
+		msg := fmt.Sprintf("An error occurred: %s", err.Error())
+		Respond(res, req, http.StatusInternalServerError, msg)
+		return
+	}
+
+	if recipients := req.Context().Value(KeyRecipients); recipients != nil {
+		allowed := make(map[string]bool)
+		for _, bp := range d.bp.GetByRecipients(recipients.([]string)) {
+			allowed[bp.ID] = true
+		}
+		visible := maintenance.Windows{}
+		for _, w := range windows {
+			if w.BP == "" || allowed[w.BP] {
+				visible = append(visible, w)
+			}
+		}
+		windows = visible
+	}
+
+	Respond(res, req, http.StatusOK, windows)
+}
+
+// AddMaintenanceHandler creates a maintenance window. The body of the
+// request holds the maintenance window as YAML or JSON, a random ID is
+// assigned if none is provided.
+func (d Dashboard) AddMaintenanceHandler(res http.ResponseWriter, req *http.Request) {
+	if !d.granted(res, req, "manage maintenance windows") {
+		return
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		Respond(res, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w := maintenance.Window{}
+	err = yaml.Unmarshal(b, &w)
+	if err != nil {
+		msg := fmt.Sprintf("Maintenance window could not be parsed: %s", err.Error())
+		Respond(res, req, http.StatusBadRequest, msg)
+		return
+	}
+
+	if w.ID == "" {
+		w.ID, err = maintenance.NewID()
+		if err != nil {
+			Respond(res, req, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if errs := w.Validate(); len(errs) > 0 {
+		msg := fmt.Sprintf("Maintenance window is invalid: %s", strings.Join(errs, " "))
+		Respond(res, req, http.StatusBadRequest, msg)
+		return
+	}
+	if err := d.bp.CheckMaintenance(w); err != nil {
+		Respond(res, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	out, err := maintenance.Add(d.maintenance, d.base, w)
+	switch err {
+	case nil:
+		d.maintain()
+		Respond(res, req, http.StatusCreated, out)
+	case maintenance.ErrExists:
+		msg := fmt.Sprintf("Maintenance window %s already exists", w.ID)
+		Respond(res, req, http.StatusConflict, msg)
+	default:
+		msg := fmt.Sprintf("An error occurred: %s", err.Error())
+		Respond(res, req, http.StatusInternalServerError, msg)
+	}
+}
+
+// RemoveMaintenanceHandler deletes a maintenance window. Only maintenance
+// windows created via API can be deleted.
+func (d Dashboard) RemoveMaintenanceHandler(res http.ResponseWriter, req *http.Request) {
+	if !d.granted(res, req, "manage maintenance windows") {
+		return
+	}
+
+	vars := mux.Vars(req)
+	id := vars["id"]
+
+	err := maintenance.Remove(d.maintenance, d.base, id)
+	switch err {
+	case nil:
+		d.maintain()
+		Respond(res, req, http.StatusOK, fmt.Sprintf("Maintenance window %s removed", id))
+	case maintenance.ErrNotFound:
+		msg := fmt.Sprintf("Maintenance window %s not found", id)
+		Respond(res, req, http.StatusNotFound, msg)
+	case maintenance.ErrReadOnly:
+		msg := fmt.Sprintf("Maintenance window %s is not managed via API and cannot be removed", id)
+		Respond(res, req, http.StatusForbidden, msg)
+	default:
+		msg := fmt.Sprintf("An error occurred: %s", err.Error())
+		Respond(res, req, http.StatusInternalServerError, msg)
+	}
+}
+
+// maintain reads the maintenance windows and serves the business processes
+// with the windows assigned. Otherwise maintenance windows modified via API
+// would only apply to the business processes once the configuration is
+// reloaded. The business processes and the store currently served are used
+// rather than those of 'd', which may have been replaced by a reload in the
+// meantime. Errors are logged only as the modification itself succeeded.
+func (d Dashboard) maintain() {
+	d.current.updating.Lock()
+	defer d.current.updating.Unlock()
+
+	windows, err := maintenance.Load(d.maintenance, d.base)
+	if err != nil {
+		log.Printf("Could not apply maintenance windows: %s", err.Error())
+		return
+	}
+	bp := make(bpmon.BusinessProcesses, len(d.current.bp))
+	copy(bp, d.current.bp)
+	if err := bp.Maintain(windows); err != nil {
+		log.Printf("Could not apply maintenance windows: %s", err.Error())
+		return
+	}
+	if _, _, err := d.update(bp, d.current.store); err != nil {
+		log.Printf("Could not apply maintenance windows: %s", err.Error())
+	}
+}
+
+// granted checks whether any recipient of the request is listed in
+// 'grant_write'. If not, the request is answered with an error message
+// stating that the recipient is not allowed to perform 'action'.
+func (d Dashboard) granted(res http.ResponseWriter, req *http.Request, action string) bool {
+	recipients := req.Context().Value(KeyRecipients)
+	if recipients == nil {
+		msg := "No credentials provided"
+		Respond(res, req, http.StatusUnauthorized, msg)
+		return false
+	}
+	for _, r := range recipients.([]string) {
+		for _, w := range d.grantWrite {
+			if r == w {
+				return true
+			}
+		}
+	}
+	msg := fmt.Sprintf("you are not allowed to %s", action)
+	Respond(res, req, http.StatusUnauthorized, msg)
+	return false
+}
+
 func (d Dashboard) WhoamiHandler(res http.ResponseWriter, req *http.Request) {
 	out := struct {
 		Roles      []string `json:"roles" yaml:"roles"`
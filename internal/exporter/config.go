@@ -0,0 +1,28 @@
+package exporter
+
+import "errors"
+
+type Config struct {
+	// listener tells the exporter where to bind. This string
+	// should match the pattern [ip]:[port]. The metrics are served
+	// at the path /metrics.
+	Listener string `yaml:"listener"`
+}
+
+func Defaults() Config {
+	return Config{
+		Listener: "127.0.0.1:8911",
+	}
+}
+
+func (ec Config) Validate() ([]string, error) {
+	errs := []string{}
+	if ec.Listener == "" {
+		errs = append(errs, "Field 'listener' cannot be empty.")
+	}
+	if len(errs) > 0 {
+		err := errors.New("Config of 'exporter' has errors")
+		return errs, err
+	}
+	return errs, nil
+}
@@ -0,0 +1,148 @@
+// Package exporter exposes the status of all business processes in the
+// Prometheus text exposition format. The business processes are evaluated
+// each time the metrics are scraped, the values therefore reflect exactly
+// what 'bpmon run' shows at the time of the scrape.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// Runtime bundles everything the exporter requires to evaluate the business
+// processes.
+type Runtime struct {
+	BP            bpmon.BusinessProcesses
+	Checker       checker.Checker
+	Rules         rules.Rules
+	CheckerConfig checker.Config
+}
+
+// Exporter evaluates the business processes of its 'Runtime' on each scrape.
+// Scrapes are processed one at a time.
+type Exporter struct {
+	listener string
+	verbose  bool
+
+	mu sync.RWMutex
+	rt Runtime
+
+	// eval serializes evaluations and guards the counters
+	eval        sync.Mutex
+	evaluations uint64
+	errors      map[string]uint64
+}
+
+// New returns a configured Exporter. Call 'Run' to start serving.
+func New(c Config, rt Runtime, verbose bool) *Exporter {
+	return &Exporter{
+		listener: c.Listener,
+		verbose:  verbose,
+		rt:       rt,
+		errors:   make(map[string]uint64),
+	}
+}
+
+// Update replaces the 'Runtime' of the exporter. The new runtime is used
+// starting with the next scrape, a scrape in progress is completed first.
+// The error counters of business processes which no longer exist are
+// dropped.
+func (e *Exporter) Update(rt Runtime) {
+	e.eval.Lock()
+	defer e.eval.Unlock()
+
+	errors := make(map[string]uint64)
+	for _, bp := range rt.BP {
+		if n, ok := e.errors[bp.ID]; ok {
+			errors[bp.ID] = n
+		}
+	}
+	e.errors = errors
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rt = rt
+}
+
+func (e *Exporter) runtime() Runtime {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rt
+}
+
+// ServeHTTP implements the http.Handler interface. It evaluates all business
+// processes and writes the metrics.
+func (e *Exporter) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	e.eval.Lock()
+	defer e.eval.Unlock()
+
+	rt := e.runtime()
+	ctx, cancel := rt.CheckerConfig.RunContext(req.Context())
+	defer cancel()
+
+	start := time.Now()
+	err := rt.BP.Prefetch(ctx, rt.Checker)
+	if err != nil && e.verbose {
+		log.Printf("Could not prefetch services, fetching one by one: %s", err.Error())
+	}
+
+	sets := rt.BP.Status(ctx, rt.Checker, nil, rt.Rules)
+	for _, rs := range sets {
+		e.errors[rs.ID] += countErrors(rs)
+	}
+	e.evaluations++
+
+	m := metrics{
+		sets:        sets,
+		duration:    time.Since(start),
+		evaluations: e.evaluations,
+		errors:      e.errors,
+	}
+
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	err = m.write(res)
+	if err != nil && e.verbose {
+		log.Printf("Error while writing metrics: %s", err.Error())
+	}
+}
+
+// Run serves the metrics at /metrics until the context is cancelled.
+func (e *Exporter) Run(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+
+	srv := &http.Server{Addr: e.listener, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	fmt.Printf("Serving metrics at http://%s/metrics\nPress CTRL-c to stop...\n", e.listener)
+	err := srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// countErrors returns the number of services in the result set whose check
+// returned an error.
+func countErrors(rs store.ResultSet) uint64 {
+	var n uint64
+	if rs.Kind() == store.KindService && rs.Err != nil {
+		n++
+	}
+	for _, child := range rs.Children {
+		n += countErrors(*child)
+	}
+	return n
+}
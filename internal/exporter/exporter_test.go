@@ -0,0 +1,140 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/checker"
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/status"
+)
+
+type checkerMock struct{}
+
+func (chk checkerMock) Status(ctx context.Context, host, service string) checker.Result {
+	r := checker.Result{
+		Timestamp: time.Now(),
+		Values:    map[string]bool{"bad": service == "bad"},
+	}
+	if service == "error" {
+		r.Error = errors.New("Error occurred")
+	}
+	return r
+}
+
+func (chk checkerMock) Values() []string          { return []string{"bad"} }
+func (chk checkerMock) Health() (string, error)   { return "all fine", nil }
+func (chk checkerMock) DefaultRules() rules.Rules { return rules.Rules{} }
+
+func TestExporter(t *testing.T) {
+	rt := Runtime{
+		BP: bpmon.BusinessProcesses{
+			{
+				ID: "app",
+				Kpis: []bpmon.KPI{
+					{
+						ID:        "web",
+						Operation: "AND",
+						Services: []bpmon.Service{
+							{Host: "host", Service: "good"},
+							{Host: "host", Service: "bad"},
+						},
+					},
+					{
+						ID:        "db",
+						Operation: "AND",
+						Services: []bpmon.Service{
+							{Host: "host", Service: "error"},
+						},
+					},
+				},
+			},
+		},
+		Checker: checkerMock{},
+		Rules: rules.Rules{
+			10:   rules.Rule{Must: []string{"bad"}, Then: status.StatusNOK},
+			9999: rules.Rule{Then: status.StatusOK},
+		},
+	}
+	e := New(Defaults(), rt, false)
+
+	var body string
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		data, _ := ioutil.ReadAll(rec.Body)
+		body = string(data)
+	}
+
+	expected := []string{
+		"# TYPE bpmon_status gauge",
+		`bpmon_status{bp="app",kpi="",svc=""} 1`,
+		`bpmon_status{bp="app",kpi="web",svc=""} 1`,
+		`bpmon_status{bp="app",kpi="web",svc="host!good"} 0`,
+		`bpmon_status{bp="app",kpi="web",svc="host!bad"} 1`,
+		`bpmon_status{bp="app",kpi="db",svc="host!error"} 0`,
+		`bpmon_in_availability{bp="app"} 0`,
+		"# TYPE bpmon_checker_errors_total counter",
+		`bpmon_checker_errors_total{bp="app"} 2`,
+		"bpmon_evaluations_total 2",
+		"bpmon_evaluation_duration_seconds ",
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected metrics to contain '%s', got:\n%s", line, body)
+		}
+	}
+}
+
+func TestExporterUpdate(t *testing.T) {
+	bp := func(id string) bpmon.BP {
+		return bpmon.BP{
+			ID: id,
+			Kpis: []bpmon.KPI{
+				{ID: "db", Operation: "AND", Services: []bpmon.Service{{Host: "host", Service: "error"}}},
+			},
+		}
+	}
+	rt := Runtime{
+		BP:      bpmon.BusinessProcesses{bp("app"), bp("old")},
+		Checker: checkerMock{},
+		Rules:   rules.Rules{9999: rules.Rule{Then: status.StatusOK}},
+	}
+	e := New(Defaults(), rt, false)
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+
+	rt.BP = bpmon.BusinessProcesses{bp("app"), bp("new")}
+	e.Update(rt)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	data, _ := ioutil.ReadAll(rec.Body)
+	body := string(data)
+
+	expected := []string{
+		`bpmon_checker_errors_total{bp="app"} 2`,
+		`bpmon_checker_errors_total{bp="new"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected metrics to contain '%s', got:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `bp="old"`) {
+		t.Errorf("Expected metrics of business process removed to be dropped, got:\n%s", body)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	in := "a\\b\"c\nd"
+	expected := `a\\b\"c\nd`
+	if out := escape(in); out != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, out)
+	}
+}
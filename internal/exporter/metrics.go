@@ -0,0 +1,136 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// metrics holds the data of a single scrape.
+type metrics struct {
+	sets        []store.ResultSet
+	duration    time.Duration
+	evaluations uint64
+	errors      map[string]uint64
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+type sample struct {
+	labels [][2]string
+	value  float64
+}
+
+// write renders the metrics in the Prometheus text exposition format.
+// References to other business processes are omitted since the referenced
+// business process is exposed on its own.
+func (m metrics) write(w io.Writer) error {
+	st := family{
+		name: "bpmon_status",
+		help: "Status of business processes, key performance indicators and services (0 = ok, 1 = not ok, 2 = unknown, 3 = degraded).",
+		typ:  "gauge",
+	}
+	avail := family{
+		name: "bpmon_in_availability",
+		help: "Whether the business process is currently within its availability (1) or not (0).",
+		typ:  "gauge",
+	}
+	for _, bp := range m.sets {
+		st.add(bp.Status.Int(), bp.ID, "", "")
+		avail.add(boolToInt(bp.Vals["in_availability"]), bp.ID)
+		for _, kpi := range bp.Children {
+			st.add(kpi.Status.Int(), bp.ID, kpi.ID, "")
+			for _, svc := range kpi.Children {
+				if svc.Kind() != store.KindService {
+					continue
+				}
+				st.add(svc.Status.Int(), bp.ID, kpi.ID, svc.ID)
+			}
+		}
+	}
+
+	errs := family{
+		name: "bpmon_checker_errors_total",
+		help: "Number of service checks which returned an error, by business process.",
+		typ:  "counter",
+	}
+	var ids []string
+	for id := range m.errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		errs.samples = append(errs.samples, sample{
+			labels: [][2]string{{"bp", id}},
+			value:  float64(m.errors[id]),
+		})
+	}
+
+	duration := family{
+		name:    "bpmon_evaluation_duration_seconds",
+		help:    "Time spent to evaluate all business processes during the last scrape.",
+		typ:     "gauge",
+		samples: []sample{{value: m.duration.Seconds()}},
+	}
+	evaluations := family{
+		name:    "bpmon_evaluations_total",
+		help:    "Number of evaluations of all business processes.",
+		typ:     "counter",
+		samples: []sample{{value: float64(m.evaluations)}},
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, f := range []family{st, avail, errs, duration, evaluations} {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+// add appends a sample to the family. The label values are assigned to the
+// label names 'bp', 'kpi' and 'svc' in this order.
+func (f *family) add(value int, values ...string) {
+	s := sample{value: float64(value)}
+	for i, name := range []string{"bp", "kpi", "svc"}[:len(values)] {
+		s.labels = append(s.labels, [2]string{name, values[i]})
+	}
+	f.samples = append(f.samples, s)
+}
+
+func (f family) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	for _, s := range f.samples {
+		var labels []string
+		for _, l := range s.labels {
+			labels = append(labels, fmt.Sprintf("%s=\"%s\"", l[0], escape(l[1])))
+		}
+		if len(labels) > 0 {
+			fmt.Fprintf(w, "%s{%s} %g\n", f.name, strings.Join(labels, ","), s.value)
+		} else {
+			fmt.Fprintf(w, "%s %g\n", f.name, s.value)
+		}
+	}
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(in string) string {
+	return escaper.Replace(in)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
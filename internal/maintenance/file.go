@@ -0,0 +1,182 @@
+package maintenance
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// APIFile is the name of the file maintenance windows managed by 'Add' and
+// 'Remove' are stored in. Maintenance windows defined in other files cannot
+// be removed.
+const APIFile = "api.yaml"
+
+var (
+	// ErrNotFound is returned by 'Remove' if the maintenance window does
+	// not exist.
+	ErrNotFound = errors.New("maintenance window not found")
+
+	// ErrReadOnly is returned by 'Remove' if the maintenance window is not
+	// defined in 'APIFile'.
+	ErrReadOnly = errors.New("maintenance window is not managed via API")
+
+	// ErrExists is returned by 'Add' if a maintenance window with the same
+	// ID exists already.
+	ErrExists = errors.New("maintenance window already exists")
+)
+
+// mu serializes the modifications of 'APIFile'.
+var mu sync.Mutex
+
+// Load reads the maintenance windows from all YAML files (*.yaml) in 'dir',
+// each holding a list of maintenance windows. If 'dir' does not exist, no
+// maintenance windows are returned. Holiday calendars of recurring
+// maintenance windows are read relative to 'base'.
+func Load(dir string, base string) (Windows, error) {
+	ws := Windows{}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return ws, nil
+	} else if err != nil {
+		return ws, fmt.Errorf("error while reading maintenance windows from '%s': %s", dir, err.Error())
+	}
+
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".yaml" {
+			continue
+		}
+		fileWindows, err := read(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return ws, err
+		}
+		for _, w := range fileWindows {
+			w.Source = f.Name()
+			if err := w.parse(base); err != nil {
+				return ws, fmt.Errorf("error while parsing maintenance windows in %s/%s: %s", dir, f.Name(), err.Error())
+			}
+			ws = append(ws, w)
+		}
+	}
+
+	err = ws.validate()
+	if err != nil {
+		return ws, fmt.Errorf("error while reading maintenance windows from '%s': %s", dir, err.Error())
+	}
+	return ws, nil
+}
+
+// Add stores the maintenance window in 'APIFile' in 'dir' and returns the
+// maintenance window as stored.
+func Add(dir string, base string, w Window) (Window, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	ws, err := Load(dir, base)
+	if err != nil {
+		return w, err
+	}
+
+	if _, ok := ws.Get(w.ID); ok {
+		return w, ErrExists
+	}
+	if err := w.parse(base); err != nil {
+		return w, err
+	}
+	w.Source = APIFile
+
+	path := filepath.Join(dir, APIFile)
+	stored, err := read(path)
+	if err != nil {
+		return w, err
+	}
+	err = write(path, append(stored, w))
+	return w, err
+}
+
+// Remove deletes the maintenance window with the ID 'id' from 'APIFile' in
+// 'dir'.
+func Remove(dir string, base string, id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	ws, err := Load(dir, base)
+	if err != nil {
+		return err
+	}
+	w, ok := ws.Get(id)
+	if !ok {
+		return ErrNotFound
+	}
+	if w.Source != APIFile {
+		return ErrReadOnly
+	}
+
+	path := filepath.Join(dir, APIFile)
+	stored, err := read(path)
+	if err != nil {
+		return err
+	}
+	remaining := Windows{}
+	for _, w := range stored {
+		if w.ID != id {
+			remaining = append(remaining, w)
+		}
+	}
+	return write(path, remaining)
+}
+
+// read returns the maintenance windows stored in the file 'path'. If the
+// file does not exist, no maintenance windows are returned.
+func read(path string) (Windows, error) {
+	ws := Windows{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ws, nil
+	} else if err != nil {
+		return ws, fmt.Errorf("error while reading maintenance windows from '%s': %s", path, err.Error())
+	}
+	err = yaml.Unmarshal(data, &ws)
+	if err != nil {
+		return ws, fmt.Errorf("error while parsing maintenance windows in '%s': %s", path, err.Error())
+	}
+	return ws, nil
+}
+
+// write replaces the file 'path' with the maintenance windows provided. The
+// file is replaced atomically in order to not expose partially written
+// files to readers.
+func write(path string, ws Windows) error {
+	data, err := yaml.Marshal(ws)
+	if err != nil {
+		return fmt.Errorf("error while rendering maintenance windows: %s", err.Error())
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("error while creating directory for maintenance windows: %s", err.Error())
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error while writing maintenance windows to '%s': %s", tmp, err.Error())
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return fmt.Errorf("error while writing maintenance windows to '%s': %s", path, err.Error())
+	}
+	return nil
+}
+
+// NewID returns a random ID for a maintenance window.
+func NewID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error while generating id of maintenance window: %s", err.Error())
+	}
+	return fmt.Sprintf("%x", b), nil
+}
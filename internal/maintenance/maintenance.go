@@ -0,0 +1,261 @@
+// Package maintenance provides maintenance windows. A maintenance window is
+// scoped to a business process, a KPI or a service and applies either once,
+// between its start and its end, or recurringly according to a schedule.
+// Results affected by a maintenance window are marked with the value
+// 'maintenance' which allows rules to map them to a status of choice.
+package maintenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/availabilities"
+	"github.com/unprofession-al/bpmon/internal/rules"
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// Value is the key of the value which marks results affected by a
+// maintenance window.
+const Value = "maintenance"
+
+// DefaultRuleOrder is the order of the rule returned by 'DefaultRules'. Add a
+// rule of the same order to your configuration in order to override it.
+const DefaultRuleOrder = 5
+
+// DefaultRules returns the rules which map results affected by a
+// maintenance window to 'ok'.
+func DefaultRules() rules.Rules {
+	return rules.Rules{
+		DefaultRuleOrder: rules.Rule{
+			Must:    []string{Value},
+			MustNot: []string{},
+			Then:    status.StatusOK,
+		},
+	}
+}
+
+// Window defines a period in which a business process, a KPI or a service is
+// under maintenance.
+type Window struct {
+	// ID identifies the maintenance window.
+	ID string `json:"id" yaml:"id"`
+
+	// Description tells why the maintenance window is required.
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// BP, KPI and Service define the scope of the maintenance window. If
+	// empty, the maintenance window applies to all business processes, KPIs
+	// and services respectively. A KPI requires a business process, a
+	// service is formated as in 'host!service'.
+	BP      string `json:"bp,omitempty" yaml:"bp,omitempty"`
+	KPI     string `json:"kpi,omitempty" yaml:"kpi,omitempty"`
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+
+	// Start and End limit the maintenance window. Both are required unless
+	// the maintenance window is recurring.
+	Start time.Time `json:"start,omitempty" yaml:"start,omitempty"`
+	End   time.Time `json:"end,omitempty" yaml:"end,omitempty"`
+
+	// Recurring holds the time ranges per weekday in which the maintenance
+	// window applies, defined just as availabilities.
+	Recurring *availabilities.AvailabilityConfig `json:"recurring,omitempty" yaml:"recurring,omitempty"`
+
+	// Source is the name of the file the maintenance window is defined in.
+	Source string `json:"source" yaml:"-"`
+
+	schedule *availabilities.Availability
+}
+
+// MarshalJSON implements the Marshaler interface of package json. In
+// contrast to the default encoding, 'Start' and 'End' are omitted if not set.
+func (w Window) MarshalJSON() ([]byte, error) {
+	type window Window
+	aux := struct {
+		window
+		Start *time.Time `json:"start,omitempty"`
+		End   *time.Time `json:"end,omitempty"`
+	}{window: window(w)}
+	if !w.Start.IsZero() {
+		aux.Start = &w.Start
+	}
+	if !w.End.IsZero() {
+		aux.End = &w.End
+	}
+	return json.Marshal(aux)
+}
+
+// Validate checks the maintenance window for errors and returns a list of
+// messages.
+func (w Window) Validate() []string {
+	errs := []string{}
+	if w.ID == "" {
+		errs = append(errs, "Field 'id' cannot be empty.")
+	}
+	if w.KPI != "" && w.BP == "" {
+		errs = append(errs, "Field 'bp' is required if field 'kpi' is set.")
+	}
+	if w.Service != "" && len(strings.Split(w.Service, "!")) != 2 {
+		errs = append(errs, fmt.Sprintf("Field 'service' must be formated as in 'host!service', is '%s'.", w.Service))
+	}
+	if w.Recurring == nil && (w.Start.IsZero() || w.End.IsZero()) {
+		errs = append(errs, "Fields 'start' and 'end' are required unless the maintenance window is recurring.")
+	}
+	if !w.Start.IsZero() && !w.End.IsZero() && !w.End.After(w.Start) {
+		errs = append(errs, "Field 'end' must be after field 'start'.")
+	}
+	if w.Recurring != nil {
+		if err := w.Recurring.Validate(); err != nil {
+			errs = append(errs, fmt.Sprintf("Field 'recurring' is invalid: %s.", err.Error()))
+		}
+	}
+	return errs
+}
+
+// parse validates the maintenance window and parses its schedule. Holiday
+// calendars of the schedule are read relative to 'base'.
+func (w *Window) parse(base string) error {
+	if errs := w.Validate(); len(errs) > 0 {
+		return fmt.Errorf("maintenance window '%s' is invalid: %s", w.ID, strings.Join(errs, " "))
+	}
+	if w.Recurring != nil {
+		schedule, err := w.Recurring.Parse(base)
+		if err != nil {
+			return fmt.Errorf("maintenance window '%s' is invalid: %s", w.ID, err.Error())
+		}
+		w.schedule = &schedule
+	}
+	return nil
+}
+
+// Matches checks whether the maintenance window applies to the result
+// tagged with 'tags'. A maintenance window of a business process applies to
+// its KPIs and services as well.
+func (w Window) Matches(tags map[store.Kind]string) bool {
+	scope := map[store.Kind]string{
+		store.KindBusinessProcess:         w.BP,
+		store.KindKeyPerformanceIndicator: w.KPI,
+		store.KindService:                 w.Service,
+	}
+	for kind, id := range scope {
+		if id == "" {
+			continue
+		}
+		if tag, ok := tags[kind]; !ok || tag != id {
+			return false
+		}
+	}
+	return true
+}
+
+// Active checks whether the maintenance window applies at 't'.
+func (w Window) Active(t time.Time) bool {
+	if !w.Start.IsZero() && t.Before(w.Start) {
+		return false
+	}
+	if !w.End.IsZero() && !t.Before(w.End) {
+		return false
+	}
+	if w.schedule != nil {
+		return w.schedule.Contains(t)
+	}
+	return true
+}
+
+// Ranges returns the time ranges between 'start' and 'end' in which the
+// maintenance window applies, ordered by time.
+func (w Window) Ranges(start time.Time, end time.Time) []availabilities.TimeRange {
+	if !w.Start.IsZero() && w.Start.After(start) {
+		start = w.Start
+	}
+	if !w.End.IsZero() && w.End.Before(end) {
+		end = w.End
+	}
+	if !start.Before(end) {
+		return []availabilities.TimeRange{}
+	}
+	if w.schedule != nil {
+		return w.schedule.Windows(start, end)
+	}
+	return []availabilities.TimeRange{{Start: start, End: end}}
+}
+
+// Windows is a list of maintenance windows.
+type Windows []Window
+
+// Active checks whether any of the maintenance windows applies to the result
+// tagged with 'tags' at 't'.
+func (ws Windows) Active(tags map[store.Kind]string, t time.Time) bool {
+	for _, w := range ws {
+		if w.Matches(tags) && w.Active(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Ranges returns the time ranges between 'start' and 'end' in which any of
+// the maintenance windows applies to the result tagged with 'tags'. The
+// time ranges may overlap.
+func (ws Windows) Ranges(tags map[store.Kind]string, start time.Time, end time.Time) []availabilities.TimeRange {
+	out := []availabilities.TimeRange{}
+	for _, w := range ws {
+		if w.Matches(tags) {
+			out = append(out, w.Ranges(start, end)...)
+		}
+	}
+	return out
+}
+
+// For returns the maintenance windows which may apply to the business
+// process with the ID 'bp'.
+func (ws Windows) For(bp string) Windows {
+	out := Windows{}
+	for _, w := range ws {
+		if w.BP == "" || w.BP == bp {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// Calendars returns the paths of the holiday calendars of all recurring
+// maintenance windows, read relative to 'base'.
+func (ws Windows) Calendars(base string) []string {
+	var paths []string
+	for _, w := range ws {
+		if w.Recurring != nil {
+			paths = append(paths, w.Recurring.Calendars(base)...)
+		}
+	}
+	return paths
+}
+
+// Get returns the maintenance window with the ID 'id'.
+func (ws Windows) Get(id string) (Window, bool) {
+	for _, w := range ws {
+		if w.ID == id {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+// validate checks the IDs of the maintenance windows for uniqueness.
+func (ws Windows) validate() error {
+	seen := make(map[string]string)
+	var errs []string
+	for _, w := range ws {
+		if source, ok := seen[w.ID]; ok {
+			errs = append(errs, fmt.Sprintf("ID '%s' of maintenance window in '%s' is already used in '%s'", w.ID, w.Source, source))
+		}
+		seen[w.ID] = w.Source
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/availabilities"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+func at(str string) time.Time {
+	t, err := time.Parse("Mon 2006/01/02 15:04", str)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func recurring(day string, tr string) *availabilities.AvailabilityConfig {
+	return &availabilities.AvailabilityConfig{Days: map[string][]string{day: {tr}}}
+}
+
+func TestMatches(t *testing.T) {
+	bp := map[store.Kind]string{store.KindBusinessProcess: "app"}
+	kpi := map[store.Kind]string{store.KindBusinessProcess: "app", store.KindKeyPerformanceIndicator: "db"}
+	svc := map[store.Kind]string{store.KindBusinessProcess: "app", store.KindKeyPerformanceIndicator: "db", store.KindService: "db1!mysql"}
+	other := map[store.Kind]string{store.KindBusinessProcess: "shop", store.KindKeyPerformanceIndicator: "db", store.KindService: "db1!mysql"}
+
+	tests := []struct {
+		name    string
+		w       Window
+		matches []map[store.Kind]string
+		misses  []map[store.Kind]string
+	}{
+		{
+			name:    "everything",
+			w:       Window{},
+			matches: []map[store.Kind]string{bp, kpi, svc, other},
+		},
+		{
+			name:    "business process",
+			w:       Window{BP: "app"},
+			matches: []map[store.Kind]string{bp, kpi, svc},
+			misses:  []map[store.Kind]string{other},
+		},
+		{
+			name:    "kpi",
+			w:       Window{BP: "app", KPI: "db"},
+			matches: []map[store.Kind]string{kpi, svc},
+			misses:  []map[store.Kind]string{bp, other},
+		},
+		{
+			name:    "service in all business processes",
+			w:       Window{Service: "db1!mysql"},
+			matches: []map[store.Kind]string{svc, other},
+			misses:  []map[store.Kind]string{bp, kpi},
+		},
+	}
+
+	for _, test := range tests {
+		for _, tags := range test.matches {
+			if !test.w.Matches(tags) {
+				t.Errorf("Maintenance window '%s' should match %v but does not", test.name, tags)
+			}
+		}
+		for _, tags := range test.misses {
+			if test.w.Matches(tags) {
+				t.Errorf("Maintenance window '%s' should not match %v but does", test.name, tags)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	start := at("Sat 2017/03/25 22:00")
+	end := at("Sun 2017/03/26 04:00")
+
+	tests := map[string]struct {
+		w    Window
+		errs int
+	}{
+		"one-off":          {w: Window{ID: "a", BP: "app", Start: start, End: end}, errs: 0},
+		"recurring":        {w: Window{ID: "a", Recurring: recurring("sunday", "02:00:00-03:00:00")}, errs: 0},
+		"without id":       {w: Window{Start: start, End: end}, errs: 1},
+		"kpi without bp":   {w: Window{ID: "a", KPI: "db", Start: start, End: end}, errs: 1},
+		"invalid service":  {w: Window{ID: "a", Service: "db1", Start: start, End: end}, errs: 1},
+		"without end":      {w: Window{ID: "a", Start: start}, errs: 1},
+		"end before start": {w: Window{ID: "a", Start: end, End: start}, errs: 1},
+		"invalid schedule": {w: Window{ID: "a", Recurring: recurring("someday", "02:00:00-03:00:00")}, errs: 1},
+	}
+
+	for name, test := range tests {
+		errs := test.w.Validate()
+		if len(errs) != test.errs {
+			t.Errorf("Expected %d errors for '%s', got %d: %v", test.errs, name, len(errs), errs)
+		}
+	}
+}
+
+func TestLoadAddRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bpmon-maintenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ws, err := Load(filepath.Join(dir, "does-not-exist"), "")
+	if err != nil || len(ws) != 0 {
+		t.Errorf("No maintenance windows and no error expected for directory which does not exist, got %v and %v", ws, err)
+	}
+
+	conf := `---
+- id: backup
+  service: db1!mysql
+  recurring:
+    timezone: UTC
+    sunday: [ "02:00:00-03:00:00" ]
+`
+	err = ioutil.WriteFile(filepath.Join(dir, "static.yaml"), []byte(conf), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := Window{ID: "migration", BP: "app", Start: at("Sat 2017/03/25 22:00"), End: at("Sun 2017/03/26 04:00")}
+	_, err = Add(dir, "", w)
+	if err != nil {
+		t.Fatalf("No error expected while adding maintenance window, got: %s", err.Error())
+	}
+	_, err = Add(dir, "", w)
+	if err != ErrExists {
+		t.Errorf("Expected '%v' while adding maintenance window twice, got '%v'", ErrExists, err)
+	}
+
+	ws, err = Load(dir, "")
+	if err != nil {
+		t.Fatalf("No error expected while loading maintenance windows, got: %s", err.Error())
+	}
+	if len(ws) != 2 {
+		t.Fatalf("Expected 2 maintenance windows, got %d: %v", len(ws), ws)
+	}
+
+	svc := map[store.Kind]string{store.KindBusinessProcess: "app", store.KindService: "db1!mysql"}
+	active := map[time.Time]bool{
+		at("Sun 2017/03/19 01:59"): false,
+		at("Sun 2017/03/19 02:00"): true,
+		at("Sun 2017/03/19 03:00"): false,
+		at("Sat 2017/03/25 23:00"): true,
+	}
+	for ts, expected := range active {
+		if ws.Active(svc, ts) != expected {
+			t.Errorf("Maintenance at %v should be %t, is %t", ts, expected, !expected)
+		}
+	}
+
+	ranges := ws.Ranges(map[store.Kind]string{store.KindBusinessProcess: "app"}, at("Sat 2017/03/25 00:00"), at("Sun 2017/03/26 00:00"))
+	if len(ranges) != 1 || !ranges[0].Start.Equal(at("Sat 2017/03/25 22:00")) || !ranges[0].End.Equal(at("Sun 2017/03/26 00:00")) {
+		t.Errorf("Ranges of the business process are not as expected: %v", ranges)
+	}
+
+	if err := Remove(dir, "", "backup"); err != ErrReadOnly {
+		t.Errorf("Expected error '%v' while removing maintenance window not managed via API, got '%v'", ErrReadOnly, err)
+	}
+	if err := Remove(dir, "", "unknown"); err != ErrNotFound {
+		t.Errorf("Expected error '%v' while removing maintenance window which does not exist, got '%v'", ErrNotFound, err)
+	}
+	if err := Remove(dir, "", "migration"); err != nil {
+		t.Errorf("No error expected while removing maintenance window, got: %s", err.Error())
+	}
+
+	ws, err = Load(dir, "")
+	if err != nil || len(ws) != 1 {
+		t.Errorf("Expected 1 maintenance window after removal, got %v and %v", ws, err)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	w := Window{ID: "backup", Service: "db1!mysql", Recurring: recurring("sunday", "02:00:00-03:00:00"), Source: "static.yaml"}
+	out, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("No error expected but got: %s", err.Error())
+	}
+	expected := `{"id":"backup","service":"db1!mysql","recurring":{"sunday":["02:00:00-03:00:00"]},"source":"static.yaml"}`
+	if string(out) != expected {
+		t.Errorf("JSON not as expected: Should be '%s', is '%s'", expected, string(out))
+	}
+}
@@ -1,96 +1,205 @@
+// Package math implements the operations which aggregate the status of the
+// children of a KPI. An operation is formated as its name, optionally
+// followed by an argument, eg. 'and', 'min 2' or 'weighted 2.5'. Names are
+// case insensitive.
 package math
 
 import (
-	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 )
 
-func and(values []bool) bool {
-	for _, val := range values {
-		if !val {
-			return false
-		}
-	}
-	return true
+// Result is the result of an operation.
+type Result int
+
+const (
+	// OK is returned if the requirement of the operation is met and all
+	// values are ok.
+	OK Result = iota
+
+	// Degraded is returned if the requirement of the operation is met even
+	// though some values are not ok or some of the values ok are degraded.
+	Degraded
+
+	// NOK is returned if the requirement of the operation is not met.
+	NOK
+)
+
+var resultText = map[Result]string{
+	OK:       "ok",
+	Degraded: "degraded",
+	NOK:      "not ok",
 }
 
-func or(values []bool) bool {
-	if len(values) < 1 {
-		return true
-	}
-	for _, val := range values {
-		if val {
-			return true
-		}
-	}
-	return false
+// String implements the stringer interface.
+func (r Result) String() string {
+	return resultText[r]
 }
 
-func min(values []bool, min float64) bool {
-	count := 0.0
-	for _, val := range values {
-		if val {
-			count++
-		}
-		if count >= min {
-			return true
-		}
-	}
-	return count >= min
+// Value is an input of an operation.
+type Value struct {
+	// OK tells whether the child is ok.
+	OK bool
+
+	// Degraded tells whether the child is impaired even though it is ok.
+	Degraded bool
+
+	// Weight is the weight of the child in weighted operations.
+	Weight float64
 }
 
-func minpercent(values []bool, minpercent float64) bool {
-	if len(values) < 1 {
-		return true
-	}
-	max := float64(len(values))
-	target := max * minpercent / 100.0
-	count := 0.0
-	for _, val := range values {
-		if val {
-			count++
-		}
-		if count >= target {
-			return true
-		}
-	}
-	return false
+// names lists all operations for error messages.
+const names = "'and', 'or', 'xor', 'min N', 'max N', 'exactly N', 'minpercent N' or 'weighted N'"
+
+// arg defines the argument an operation requires.
+type arg int
+
+const (
+	argNone arg = iota
+	argCount
+	argPercent
+	argNumber
+)
+
+type operation struct {
+	arg     arg
+	example string
+	// met checks whether the requirement of the operation is met given the
+	// number of values ok, the number of values and the sum of the weights
+	// of the values ok.
+	met func(ok int, total int, weight float64, arg float64) bool
+	// exact is true if failures are part of the requirement, the result of
+	// such operations is never 'Degraded'.
+	exact bool
 }
 
-func Calculate(operation string, values []bool) (bool, error) {
-	var out bool
+var operations = map[string]operation{
+	"and": {
+		example: "and",
+		met:     func(ok, total int, weight, arg float64) bool { return ok == total },
+	},
+	"or": {
+		example: "or",
+		met:     func(ok, total int, weight, arg float64) bool { return total == 0 || ok > 0 },
+	},
+	"xor": {
+		example: "xor",
+		met:     func(ok, total int, weight, arg float64) bool { return total == 0 || ok == 1 },
+		exact:   true,
+	},
+	"min": {
+		arg:     argCount,
+		example: "min 2",
+		met:     func(ok, total int, weight, arg float64) bool { return float64(ok) >= arg },
+	},
+	"max": {
+		arg:     argCount,
+		example: "max 1",
+		met:     func(ok, total int, weight, arg float64) bool { return float64(total-ok) <= arg },
+	},
+	"exactly": {
+		arg:     argCount,
+		example: "exactly 2",
+		met:     func(ok, total int, weight, arg float64) bool { return float64(ok) == arg },
+		exact:   true,
+	},
+	"minpercent": {
+		arg:     argPercent,
+		example: "minpercent 50",
+		met: func(ok, total int, weight, arg float64) bool {
+			return total == 0 || float64(ok) >= float64(total)*arg/100.0
+		},
+	},
+	"weighted": {
+		arg:     argNumber,
+		example: "weighted 2.5",
+		met:     func(ok, total int, weight, arg float64) bool { return weight >= arg },
+	},
+}
 
+// Evaluate applies the operation to the values provided.
+func Evaluate(operation string, values []Value) (Result, error) {
 	op, arg, err := parseOp(operation)
 	if err != nil {
-		return out, err
+		return NOK, err
+	}
+
+	ok := 0
+	weight := 0.0
+	degraded := false
+	for _, v := range values {
+		if v.OK {
+			ok++
+			weight += v.Weight
+			degraded = degraded || v.Degraded
+		}
 	}
 
-	switch op {
-	case "and":
-		out = and(values)
-	case "or":
-		out = or(values)
-	case "min":
-		out = min(values, arg)
-	case "minpercent":
-		out = minpercent(values, arg)
-	default:
-		return true, errors.New("Operation '" + operation + "' unknown")
+	switch {
+	case !op.met(ok, len(values), weight, arg):
+		return NOK, nil
+	case degraded:
+		return Degraded, nil
+	case ok < len(values) && !op.exact:
+		return Degraded, nil
+	}
+	return OK, nil
+}
+
+// Calculate applies the operation to the values provided, each having a
+// weight of 1. The result is true unless the requirement of the operation
+// is not met.
+func Calculate(operation string, values []bool) (bool, error) {
+	weighted := make([]Value, len(values))
+	for i, v := range values {
+		weighted[i] = Value{OK: v, Weight: 1}
+	}
+	res, err := Evaluate(operation, weighted)
+	if err != nil {
+		return true, err
 	}
+	return res != NOK, nil
+}
 
-	return out, nil
+// Validate returns an error if the operation cannot be interpreted by
+// Calculate.
+func Validate(operation string) error {
+	_, _, err := parseOp(operation)
+	return err
 }
 
-func parseOp(operation string) (op string, arg float64, err error) {
-	opParts := strings.Split(strings.ToLower(operation), " ")
-	op = opParts[0]
+func parseOp(operation string) (op operation, arg float64, err error) {
+	fields := strings.Fields(strings.ToLower(operation))
+	if len(fields) < 1 {
+		return op, 0, fmt.Errorf("operation cannot be empty, use one of %s", names)
+	}
+
+	op, ok := operations[fields[0]]
+	if !ok {
+		return op, 0, fmt.Errorf("operation '%s' is unknown, use one of %s", fields[0], names)
+	}
 
-	if len(opParts) > 1 {
-		arg, err = strconv.ParseFloat(opParts[1], 64)
-		if err != nil {
-			return "", 0.0, err
+	if op.arg == argNone {
+		if len(fields) > 1 {
+			return op, 0, fmt.Errorf("operation '%s' does not take an argument, use '%s'", fields[0], op.example)
 		}
+		return op, 0, nil
+	}
+	if len(fields) != 2 {
+		return op, 0, fmt.Errorf("operation '%s' requires exactly one argument as in '%s'", fields[0], op.example)
+	}
+
+	arg, err = strconv.ParseFloat(fields[1], 64)
+	switch {
+	case err != nil:
+		return op, 0, fmt.Errorf("argument '%s' of operation '%s' is not a number, use '%s'", fields[1], fields[0], op.example)
+	case arg < 0:
+		return op, 0, fmt.Errorf("argument '%s' of operation '%s' cannot be negative", fields[1], fields[0])
+	case op.arg == argCount && arg != float64(int(arg)):
+		return op, 0, fmt.Errorf("argument '%s' of operation '%s' must be a whole number, use '%s'", fields[1], fields[0], op.example)
+	case op.arg == argPercent && arg > 100:
+		return op, 0, fmt.Errorf("argument '%s' of operation '%s' is a percentage and cannot exceed 100", fields[1], fields[0])
 	}
-	return op, arg, err
+	return op, arg, nil
 }
@@ -31,6 +31,15 @@ var MathTestSets = []mathTestSet{
 	{"MINPERCENT 0", []bool{false, false}, true},
 	{"MINPERCENT 0", []bool{}, true},
 	{"MINPERCENT 100", []bool{}, true},
+	{"MAX 1", []bool{true, false, true}, true},
+	{"MAX 1", []bool{false, false, true}, false},
+	{"MAX 0", []bool{}, true},
+	{"XOR", []bool{false, true, false}, true},
+	{"XOR", []bool{true, true, false}, false},
+	{"XOR", []bool{false, false}, false},
+	{"EXACTLY 2", []bool{true, false, true}, true},
+	{"EXACTLY 2", []bool{true, true, true}, false},
+	{"EXACTLY 0", []bool{false}, true},
 }
 
 func TestOperations(t *testing.T) {
@@ -63,3 +72,66 @@ func TestMalformedOperation(t *testing.T) {
 		t.Errorf("Malformed operation did not return error")
 	}
 }
+
+type evaluateTestSet struct {
+	op  string
+	val []Value
+	res Result
+}
+
+var EvaluateTestSets = []evaluateTestSet{
+	{"and", []Value{{true, false, 1}, {true, false, 1}}, OK},
+	{"and", []Value{{true, false, 1}, {false, false, 1}}, NOK},
+	{"and", []Value{{true, true, 1}, {true, false, 1}}, Degraded},
+	{"or", []Value{{true, false, 1}, {false, false, 1}}, Degraded},
+	{"or", []Value{{false, true, 1}, {false, false, 1}}, NOK},
+	{"min 1", []Value{{true, false, 1}, {true, false, 1}}, OK},
+	{"min 1", []Value{{true, false, 1}, {false, false, 1}}, Degraded},
+	{"max 1", []Value{{true, false, 1}, {false, false, 1}}, Degraded},
+	{"xor", []Value{{true, false, 1}, {false, false, 1}}, OK},
+	{"xor", []Value{{true, true, 1}, {false, false, 1}}, Degraded},
+	{"exactly 1", []Value{{true, false, 1}, {false, false, 1}}, OK},
+	{"weighted 3", []Value{{true, false, 2}, {true, false, 1}, {false, false, 5}}, Degraded},
+	{"weighted 3", []Value{{true, false, 2}, {false, false, 1}, {true, false, 0.5}}, NOK},
+	{"weighted 2.5", []Value{{true, false, 2}, {false, false, 1}, {true, false, 0.5}}, Degraded},
+	{"weighted 0", []Value{}, OK},
+}
+
+func TestEvaluate(t *testing.T) {
+	for _, test := range EvaluateTestSets {
+		res, err := Evaluate(test.op, test.val)
+		if err != nil {
+			t.Errorf("No error expected for operation '%s', got: %s", test.op, err.Error())
+			continue
+		}
+		if res != test.res {
+			t.Errorf("Expected operation '%s' with data %v to be %s, is %s", test.op, test.val, test.res, res)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]string{
+		"":               "operation cannot be empty, use one of 'and', 'or', 'xor', 'min N', 'max N', 'exactly N', 'minpercent N' or 'weighted N'",
+		"nand":           "operation 'nand' is unknown, use one of 'and', 'or', 'xor', 'min N', 'max N', 'exactly N', 'minpercent N' or 'weighted N'",
+		"AND 2":          "operation 'and' does not take an argument, use 'and'",
+		"MIN":            "operation 'min' requires exactly one argument as in 'min 2'",
+		"MIN 1 2":        "operation 'min' requires exactly one argument as in 'min 2'",
+		"MIN 4,3":        "argument '4,3' of operation 'min' is not a number, use 'min 2'",
+		"MAX -1":         "argument '-1' of operation 'max' cannot be negative",
+		"EXACTLY 1.5":    "argument '1.5' of operation 'exactly' must be a whole number, use 'exactly 2'",
+		"MINPERCENT 101": "argument '101' of operation 'minpercent' is a percentage and cannot exceed 100",
+		"weighted 1.5":   "",
+	}
+
+	for op, expected := range tests {
+		err := Validate(op)
+		msg := ""
+		if err != nil {
+			msg = err.Error()
+		}
+		if msg != expected {
+			t.Errorf("Expected error '%s' for operation '%s', got '%s'", expected, op, msg)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package report
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// Budget holds the error budget of a business process with an SLO at 'End'.
+// All durations are in seconds.
+type Budget struct {
+	BP     string    `json:"bp" yaml:"bp"`
+	Target float64   `json:"target" yaml:"target"`
+	Start  time.Time `json:"start" yaml:"start"`
+	End    time.Time `json:"end" yaml:"end"`
+
+	// Achieved is the percentage of the service time the business process
+	// was not down between 'Start' and 'End'.
+	Achieved float64 `json:"achieved" yaml:"achieved"`
+
+	// Allowed is the downtime allowed by the SLO between 'Start' and 'End',
+	// Consumed the actual downtime.
+	Allowed  float64 `json:"allowed" yaml:"allowed"`
+	Consumed float64 `json:"consumed" yaml:"consumed"`
+
+	// Remaining is the percentage of the allowed downtime left. The value is
+	// negative if the SLO is violated.
+	Remaining float64 `json:"remaining" yaml:"remaining"`
+
+	// BurnRate is the rate the budget was consumed at since 'BurnStart'
+	// relative to the rate which exactly exhausts the budget at the end of
+	// the window. A burn rate of 1 is sustainable, a burn rate of 10 exhausts
+	// the budget within a tenth of the window.
+	BurnRate  float64   `json:"burn_rate" yaml:"burn_rate"`
+	BurnStart time.Time `json:"burn_start" yaml:"burn_start"`
+}
+
+// GetBudget calculates the error budget of the business process at 'at'. The
+// burn rate is calculated for the period 'burn' before 'at', or the whole
+// window of the SLO if 'burn' is zero. An error is returned if no SLO is
+// defined for the business process.
+func GetBudget(bp bpmon.BP, s store.Accessor, at time.Time, burn time.Duration, interval time.Duration) (Budget, error) {
+	if bp.SLO == nil {
+		return Budget{}, fmt.Errorf("no SLO is defined for business process %s", bp.ID)
+	}
+
+	start := at.Add(-bp.SLO.Duration())
+	sla, err := Get(bp, s, start, at, interval)
+	if err != nil {
+		return Budget{}, err
+	}
+
+	burnSLA := sla
+	if burn > 0 && burn < bp.SLO.Duration() {
+		burnSLA, err = Get(bp, s, at.Add(-burn), at, interval)
+		if err != nil {
+			return Budget{}, err
+		}
+	}
+
+	return NewBudget(*bp.SLO, sla, burnSLA), nil
+}
+
+// NewBudget calculates the error budget based on the service level achieved
+// within the window of the SLO ('sla') and the service level achieved within
+// the period the burn rate is calculated for ('burn').
+func NewBudget(slo bpmon.SLO, sla SLA, burn SLA) Budget {
+	errorRate := (100.0 - slo.Target) / 100.0
+
+	b := Budget{
+		BP:        sla.BP,
+		Target:    slo.Target,
+		Start:     sla.Start,
+		End:       sla.End,
+		Achieved:  sla.Achieved,
+		Allowed:   sla.ServiceTime * errorRate,
+		Consumed:  sla.Downtime,
+		Remaining: 100.0,
+		BurnStart: burn.Start,
+	}
+
+	if b.Allowed > 0 {
+		b.Remaining = 100.0 / b.Allowed * (b.Allowed - b.Consumed)
+	}
+	if burn.ServiceTime > 0 {
+		b.BurnRate = burn.Downtime / burn.ServiceTime / errorRate
+	}
+	return b
+}
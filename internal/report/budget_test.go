@@ -0,0 +1,73 @@
+package report
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+)
+
+func TestNewBudget(t *testing.T) {
+	slo := bpmon.SLO{Target: 99}
+	start := time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(100 * time.Hour)
+
+	tests := []struct {
+		name      string
+		sla       SLA
+		burn      SLA
+		allowed   float64
+		remaining float64
+		burnRate  float64
+	}{
+		{
+			name:      "no downtime",
+			sla:       SLA{ServiceTime: 100 * 3600, Start: start, End: end},
+			burn:      SLA{ServiceTime: 3600, Start: end.Add(-time.Hour), End: end},
+			allowed:   3600,
+			remaining: 100,
+			burnRate:  0,
+		},
+		{
+			name:      "budget half consumed, burning fast",
+			sla:       SLA{ServiceTime: 100 * 3600, Downtime: 1800, Start: start, End: end},
+			burn:      SLA{ServiceTime: 3600, Downtime: 360, Start: end.Add(-time.Hour), End: end},
+			allowed:   3600,
+			remaining: 50,
+			burnRate:  10,
+		},
+		{
+			name:      "budget exhausted",
+			sla:       SLA{ServiceTime: 100 * 3600, Downtime: 7200, Start: start, End: end},
+			burn:      SLA{ServiceTime: 100 * 3600, Downtime: 7200, Start: start, End: end},
+			allowed:   3600,
+			remaining: -100,
+			burnRate:  2,
+		},
+		{
+			name:      "no service time",
+			sla:       SLA{Start: start, End: end},
+			burn:      SLA{Start: start, End: end},
+			allowed:   0,
+			remaining: 100,
+			burnRate:  0,
+		},
+	}
+
+	for _, test := range tests {
+		b := NewBudget(slo, test.sla, test.burn)
+		if math.Abs(b.Allowed-test.allowed) > 0.000001 {
+			t.Errorf("%s: expected %f seconds allowed, got %f", test.name, test.allowed, b.Allowed)
+		}
+		if math.Abs(b.Remaining-test.remaining) > 0.000001 {
+			t.Errorf("%s: expected %f%% remaining, got %f%%", test.name, test.remaining, b.Remaining)
+		}
+		if math.Abs(b.BurnRate-test.burnRate) > 0.000001 {
+			t.Errorf("%s: expected burn rate of %f, got %f", test.name, test.burnRate, b.BurnRate)
+		}
+		if !b.Start.Equal(start) || !b.End.Equal(end) || !b.BurnStart.Equal(test.burn.Start) || b.Target != 99 {
+			t.Errorf("%s: budget does not describe the window: %+v", test.name, b)
+		}
+	}
+}
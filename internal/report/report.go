@@ -0,0 +1,231 @@
+// Package report calculates the service level achieved by business processes.
+// The spans persisted in the store are intersected with the availability
+// windows of the business process: only downtime during the availability
+// counts against the service level. Maintenance windows of the business
+// process are excluded from its availability.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/availabilities"
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// SLA holds the service level achieved by a business process between 'Start'
+// and 'End'. All durations are in seconds.
+type SLA struct {
+	BP           string    `json:"bp" yaml:"bp"`
+	Name         string    `json:"name" yaml:"name"`
+	Availability string    `json:"availability" yaml:"availability"`
+	Start        time.Time `json:"start" yaml:"start"`
+	End          time.Time `json:"end" yaml:"end"`
+
+	// ServiceTime is the time the business process must be available.
+	ServiceTime float64 `json:"service_time" yaml:"service_time"`
+
+	// Maintenance is the time of the availability excluded from the service
+	// time due to maintenance windows.
+	Maintenance float64 `json:"maintenance" yaml:"maintenance"`
+
+	// Downtime is the time the business process was not ok during its
+	// availability, DowntimeMaintenance during its maintenance windows and
+	// DowntimeOutside outside of both.
+	Downtime            float64 `json:"downtime" yaml:"downtime"`
+	DowntimeMaintenance float64 `json:"downtime_maintenance" yaml:"downtime_maintenance"`
+	DowntimeOutside     float64 `json:"downtime_outside" yaml:"downtime_outside"`
+
+	// Unknown is the time the status of the business process was unknown
+	// during its availability. It is not counted as downtime.
+	Unknown float64 `json:"unknown" yaml:"unknown"`
+
+	// Achieved is the percentage of the service time the business process
+	// was not down. If no service time is required, the value is 100.
+	Achieved float64 `json:"achieved" yaml:"achieved"`
+
+	// Incidents is the number of outages affecting the availability.
+	// Consecutive spans which are not ok are counted as one incident.
+	Incidents int `json:"incidents" yaml:"incidents"`
+}
+
+// Get reads the spans of the business process from the store and returns
+// the service level achieved between 'start' and 'end'.
+func Get(bp bpmon.BP, s store.Accessor, start time.Time, end time.Time, interval time.Duration) (SLA, error) {
+	rs := store.ResultSet{
+		Tags: map[store.Kind]string{store.KindBusinessProcess: bp.ID},
+	}
+	spans, err := s.GetSpans(rs, start, end, interval, []status.Status{})
+	if err != nil {
+		return SLA{}, err
+	}
+	return New(bp, spans, start, end), nil
+}
+
+// New calculates the service level achieved by the business process based on
+// the spans provided, which must be ordered by time.
+func New(bp bpmon.BP, spans []store.Span, start time.Time, end time.Time) SLA {
+	sla := SLA{
+		BP:           bp.ID,
+		Name:         bp.Name,
+		Availability: bp.AvailabilityName,
+		Start:        start,
+		End:          end,
+	}
+
+	windows := bp.Availability.Windows(start, end)
+	tags := map[store.Kind]string{store.KindBusinessProcess: bp.ID}
+	excluded := merge(bp.Maintenance.Ranges(tags, start, end))
+	for _, w := range windows {
+		sla.Maintenance += overlap(w.Start, w.End, excluded)
+	}
+	windows = subtract(windows, excluded)
+	for _, w := range windows {
+		sla.ServiceTime += w.End.Sub(w.Start).Seconds()
+	}
+
+	inIncident := false
+	for _, span := range spans {
+		from, to := clip(span.Start, span.End, start, end)
+		if !from.Before(to) {
+			continue
+		}
+
+		inside := overlap(from, to, windows)
+		switch span.Status {
+		case status.StatusNOK:
+			maintained := overlap(from, to, excluded)
+			sla.Downtime += inside
+			sla.DowntimeMaintenance += maintained
+			sla.DowntimeOutside += to.Sub(from).Seconds() - inside - maintained
+			if inside > 0 && !inIncident {
+				sla.Incidents++
+				inIncident = true
+			}
+		case status.StatusUnknown:
+			sla.Unknown += inside
+			inIncident = false
+		default:
+			inIncident = false
+		}
+	}
+
+	sla.Achieved = 100.0
+	if sla.ServiceTime > 0 {
+		sla.Achieved = 100.0 / sla.ServiceTime * (sla.ServiceTime - sla.Downtime)
+	}
+	return sla
+}
+
+// overlap returns the number of seconds between 'from' and 'to' covered by
+// the windows provided.
+func overlap(from time.Time, to time.Time, windows []availabilities.TimeRange) float64 {
+	var out float64
+	for _, w := range windows {
+		start, end := clip(from, to, w.Start, w.End)
+		if start.Before(end) {
+			out += end.Sub(start).Seconds()
+		}
+	}
+	return out
+}
+
+// subtract removes the time ranges 'excluded' from the windows provided.
+func subtract(windows []availabilities.TimeRange, excluded []availabilities.TimeRange) []availabilities.TimeRange {
+	for _, e := range excluded {
+		var out []availabilities.TimeRange
+		for _, w := range windows {
+			if !e.Start.Before(w.End) || !e.End.After(w.Start) {
+				out = append(out, w)
+				continue
+			}
+			if w.Start.Before(e.Start) {
+				out = append(out, availabilities.TimeRange{Start: w.Start, End: e.Start})
+			}
+			if w.End.After(e.End) {
+				out = append(out, availabilities.TimeRange{Start: e.End, End: w.End})
+			}
+		}
+		windows = out
+	}
+	return windows
+}
+
+// merge returns the union of the time ranges provided, ordered by time.
+func merge(ranges []availabilities.TimeRange) []availabilities.TimeRange {
+	sorted := append([]availabilities.TimeRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var out []availabilities.TimeRange
+	for _, r := range sorted {
+		if last := len(out) - 1; last >= 0 && !r.Start.After(out[last].End) {
+			if r.End.After(out[last].End) {
+				out[last].End = r.End
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// clip limits the range from 'from' to 'to' to the range from 'start' to
+// 'end'.
+func clip(from time.Time, to time.Time, start time.Time, end time.Time) (time.Time, time.Time) {
+	if from.Before(start) {
+		from = start
+	}
+	if to.After(end) {
+		to = end
+	}
+	return from, to
+}
+
+// dateFormat is the format of dates accepted by 'ParsePeriod' in addition
+// to RFC3339.
+const dateFormat = "2006-01-02"
+
+// ParsePeriod parses the beginning and the end of the period to report. Both
+// must be formatted as date ('2006-01-02') or as RFC3339. Dates as well as
+// the default period are determined in the location of 'now', pass the time
+// in the location of the availability of a business process in order to
+// report the days as seen by the business process. If 'start' is empty, the
+// period starts at the beginning of the month before 'now'; if 'end' is
+// empty, the period lasts one month.
+func ParsePeriod(start string, end string, now time.Time) (from time.Time, to time.Time, err error) {
+	year, month, _ := now.Date()
+	from = time.Date(year, month-1, 1, 0, 0, 0, 0, now.Location())
+	if start != "" {
+		from, err = parseTime(start, now.Location())
+		if err != nil {
+			return
+		}
+	}
+
+	to = from.AddDate(0, 1, 0)
+	if end != "" {
+		to, err = parseTime(end, now.Location())
+		if err != nil {
+			return
+		}
+	}
+
+	if !from.Before(to) {
+		err = fmt.Errorf("the end of the period (%s) must be after its start (%s)", to.Format(time.RFC3339), from.Format(time.RFC3339))
+	}
+	return
+}
+
+func parseTime(str string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateFormat, str, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return t, fmt.Errorf("'%s' does not look like a point in time, it must be formated as in '%s' or '%s'", str, dateFormat, time.RFC3339)
+	}
+	return t, nil
+}
@@ -0,0 +1,340 @@
+package report
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/availabilities"
+	"github.com/unprofession-al/bpmon/internal/bpmon"
+	"github.com/unprofession-al/bpmon/internal/maintenance"
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+func at(str string) time.Time {
+	t, err := time.Parse("Mon 2006/01/02 15:04", str)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func clock(str string) time.Time {
+	t, err := time.Parse("15:04", str)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func span(s status.Status, start string, end string) store.Span {
+	return store.Span{Status: s, Start: at(start), End: at(end)}
+}
+
+func TestNew(t *testing.T) {
+	bp := bpmon.BP{
+		ID:               "app",
+		Name:             "Application",
+		AvailabilityName: "office",
+		Availability: availabilities.Availability{
+			Location: time.UTC,
+			Days: map[time.Weekday]availabilities.AvailabilityTime{
+				time.Monday: availabilities.AvailabilityTime{
+					TimeRanges: []availabilities.TimeRange{
+						{Start: clock("08:00"), End: clock("18:00")},
+					},
+				},
+				time.Tuesday: availabilities.AvailabilityTime{
+					TimeRanges: []availabilities.TimeRange{
+						{Start: clock("08:00"), End: clock("18:00")},
+					},
+				},
+			},
+		},
+	}
+
+	start := at("Mon 2017/03/20 00:00")
+	end := at("Wed 2017/03/22 00:00")
+
+	tests := []struct {
+		name            string
+		spans           []store.Span
+		downtime        float64
+		downtimeOutside float64
+		unknown         float64
+		incidents       int
+	}{
+		{
+			name: "all ok",
+			spans: []store.Span{
+				span(status.StatusOK, "Sun 2017/03/19 00:00", "Wed 2017/03/22 00:00"),
+			},
+		},
+		{
+			name: "outage outside of availability",
+			spans: []store.Span{
+				span(status.StatusOK, "Mon 2017/03/20 00:00", "Mon 2017/03/20 02:00"),
+				span(status.StatusNOK, "Mon 2017/03/20 02:00", "Mon 2017/03/20 04:00"),
+				span(status.StatusOK, "Mon 2017/03/20 04:00", "Wed 2017/03/22 00:00"),
+			},
+			downtimeOutside: 2 * 3600,
+		},
+		{
+			name: "overnight outage and unknown",
+			spans: []store.Span{
+				span(status.StatusOK, "Mon 2017/03/20 00:00", "Mon 2017/03/20 17:00"),
+				span(status.StatusNOK, "Mon 2017/03/20 17:00", "Tue 2017/03/21 02:00"),
+				span(status.StatusNOK, "Tue 2017/03/21 02:00", "Tue 2017/03/21 09:00"),
+				span(status.StatusOK, "Tue 2017/03/21 09:00", "Tue 2017/03/21 12:00"),
+				span(status.StatusUnknown, "Tue 2017/03/21 12:00", "Tue 2017/03/21 12:30"),
+				span(status.StatusNOK, "Tue 2017/03/21 12:30", "Tue 2017/03/21 13:00"),
+				span(status.StatusOK, "Tue 2017/03/21 13:00", "Wed 2017/03/22 00:00"),
+			},
+			downtime:        2*3600 + 1800,
+			downtimeOutside: 14 * 3600,
+			unknown:         1800,
+			incidents:       2,
+		},
+	}
+
+	for _, test := range tests {
+		sla := New(bp, test.spans, start, end)
+		if sla.BP != "app" || sla.Availability != "office" || !sla.Start.Equal(start) || !sla.End.Equal(end) {
+			t.Errorf("%s: report does not describe the business process and period requested: %+v", test.name, sla)
+		}
+		if sla.ServiceTime != 20*3600 {
+			t.Errorf("%s: expected service time of %d, got %f", test.name, 20*3600, sla.ServiceTime)
+		}
+		if sla.Downtime != test.downtime {
+			t.Errorf("%s: expected downtime of %f, got %f", test.name, test.downtime, sla.Downtime)
+		}
+		if sla.DowntimeOutside != test.downtimeOutside {
+			t.Errorf("%s: expected downtime outside of %f, got %f", test.name, test.downtimeOutside, sla.DowntimeOutside)
+		}
+		if sla.Unknown != test.unknown {
+			t.Errorf("%s: expected unknown of %f, got %f", test.name, test.unknown, sla.Unknown)
+		}
+		if sla.Incidents != test.incidents {
+			t.Errorf("%s: expected %d incidents, got %d", test.name, test.incidents, sla.Incidents)
+		}
+		achieved := 100.0 / sla.ServiceTime * (sla.ServiceTime - test.downtime)
+		if math.Abs(sla.Achieved-achieved) > 0.000001 {
+			t.Errorf("%s: expected %f%% achieved, got %f%%", test.name, achieved, sla.Achieved)
+		}
+	}
+}
+
+func TestNewWithoutServiceTime(t *testing.T) {
+	bp := bpmon.BP{ID: "app", Availability: availabilities.Availability{}}
+	spans := []store.Span{
+		span(status.StatusNOK, "Mon 2017/03/20 00:00", "Tue 2017/03/21 00:00"),
+	}
+	sla := New(bp, spans, at("Mon 2017/03/20 00:00"), at("Tue 2017/03/21 00:00"))
+	if sla.Achieved != 100.0 || sla.Incidents != 0 || sla.DowntimeOutside != 24*3600 {
+		t.Errorf("Expected 100%% achieved without incidents if no service time is required, got %+v", sla)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2017, 3, 20, 14, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		start       string
+		end         string
+		from        time.Time
+		to          time.Time
+		errExpected bool
+	}{
+		{
+			from: time.Date(2017, 2, 1, 0, 0, 0, 0, time.Local),
+			to:   time.Date(2017, 3, 1, 0, 0, 0, 0, time.Local),
+		},
+		{
+			start: "2017-01-15",
+			from:  time.Date(2017, 1, 15, 0, 0, 0, 0, time.Local),
+			to:    time.Date(2017, 2, 15, 0, 0, 0, 0, time.Local),
+		},
+		{
+			start: "2017-01-15T12:00:00Z",
+			end:   "2017-01-16",
+			from:  time.Date(2017, 1, 15, 12, 0, 0, 0, time.UTC),
+			to:    time.Date(2017, 1, 16, 0, 0, 0, 0, time.Local),
+		},
+		{
+			start:       "yesterday",
+			errExpected: true,
+		},
+		{
+			start:       "2017-01-15",
+			end:         "2017-01-15",
+			errExpected: true,
+		},
+	}
+
+	for _, test := range tests {
+		from, to, err := ParsePeriod(test.start, test.end, now)
+		if err == nil && test.errExpected {
+			t.Errorf("Error expected for '%s' to '%s' but test succeeded", test.start, test.end)
+		} else if err != nil && !test.errExpected {
+			t.Errorf("No error expected for '%s' to '%s' but test failed: %s", test.start, test.end, err.Error())
+		} else if err == nil && (!from.Equal(test.from) || !to.Equal(test.to)) {
+			t.Errorf("Period for '%s' to '%s' should be %v to %v, is %v to %v", test.start, test.end, test.from, test.to, from, to)
+		}
+	}
+}
+
+func TestParsePeriodTimezone(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Fatalf("Timezone required by test cannot be loaded: %s", err.Error())
+	}
+	// shortly after midnight in Zurich, still the previous month in UTC
+	now := time.Date(2017, 3, 1, 0, 30, 0, 0, zurich)
+
+	tests := []struct {
+		start string
+		end   string
+		from  time.Time
+		to    time.Time
+	}{
+		{
+			from: time.Date(2017, 2, 1, 0, 0, 0, 0, zurich),
+			to:   time.Date(2017, 3, 1, 0, 0, 0, 0, zurich),
+		},
+		{
+			start: "2017-01-15",
+			end:   "2017-01-16",
+			from:  time.Date(2017, 1, 14, 23, 0, 0, 0, time.UTC),
+			to:    time.Date(2017, 1, 15, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			start: "2017-01-15T12:00:00Z",
+			from:  time.Date(2017, 1, 15, 12, 0, 0, 0, time.UTC),
+			to:    time.Date(2017, 2, 15, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		from, to, err := ParsePeriod(test.start, test.end, now)
+		if err != nil {
+			t.Errorf("No error expected for '%s' to '%s' but test failed: %s", test.start, test.end, err.Error())
+		} else if !from.Equal(test.from) || !to.Equal(test.to) {
+			t.Errorf("Period for '%s' to '%s' should be %v to %v, is %v to %v", test.start, test.end, test.from, test.to, from, to)
+		}
+	}
+}
+
+func TestNewTimezone(t *testing.T) {
+	singapore, err := time.LoadLocation("Asia/Singapore")
+	if err != nil {
+		t.Fatalf("Timezone required by test cannot be loaded: %s", err.Error())
+	}
+	bp := bpmon.BP{
+		ID: "app",
+		Availability: availabilities.Availability{
+			Location: singapore,
+			Days: map[time.Weekday]availabilities.AvailabilityTime{
+				time.Monday: availabilities.AvailabilityTime{
+					TimeRanges: []availabilities.TimeRange{
+						{Start: clock("08:00"), End: clock("17:00")},
+					},
+				},
+			},
+		},
+	}
+
+	// Sun 23:00 to Mon 02:00 UTC is Mon 07:00 to 10:00 in Singapore
+	spans := []store.Span{
+		span(status.StatusOK, "Sun 2017/03/19 00:00", "Sun 2017/03/19 23:00"),
+		span(status.StatusNOK, "Sun 2017/03/19 23:00", "Mon 2017/03/20 02:00"),
+		span(status.StatusOK, "Mon 2017/03/20 02:00", "Tue 2017/03/21 00:00"),
+	}
+	sla := New(bp, spans, at("Sun 2017/03/19 00:00"), at("Tue 2017/03/21 00:00"))
+	if sla.ServiceTime != 9*3600 || sla.Downtime != 2*3600 || sla.DowntimeOutside != 3600 || sla.Incidents != 1 {
+		t.Errorf("Report not calculated in the timezone of the availability: %+v", sla)
+	}
+}
+
+func TestNewMaintenance(t *testing.T) {
+	bp := bpmon.BP{
+		ID: "app",
+		Availability: availabilities.Availability{
+			Location: time.UTC,
+			Days: map[time.Weekday]availabilities.AvailabilityTime{
+				time.Monday: availabilities.AvailabilityTime{
+					TimeRanges: []availabilities.TimeRange{
+						{Start: clock("08:00"), End: clock("18:00")},
+					},
+				},
+			},
+		},
+		Maintenance: maintenance.Windows{
+			{ID: "a", BP: "app", Start: at("Mon 2017/03/20 10:00"), End: at("Mon 2017/03/20 11:30")},
+			{ID: "b", Start: at("Mon 2017/03/20 11:00"), End: at("Mon 2017/03/20 12:00")},
+			// maintenance windows of single KPIs do not affect the service time
+			{ID: "c", BP: "app", KPI: "db", Start: at("Mon 2017/03/20 14:00"), End: at("Mon 2017/03/20 16:00")},
+		},
+	}
+	spans := []store.Span{
+		span(status.StatusOK, "Mon 2017/03/20 00:00", "Mon 2017/03/20 09:00"),
+		span(status.StatusNOK, "Mon 2017/03/20 09:00", "Mon 2017/03/20 11:00"),
+		span(status.StatusOK, "Mon 2017/03/20 11:00", "Tue 2017/03/21 00:00"),
+	}
+
+	sla := New(bp, spans, at("Mon 2017/03/20 00:00"), at("Tue 2017/03/21 00:00"))
+	if sla.ServiceTime != 8*3600 {
+		t.Errorf("Expected service time of %d, got %f", 8*3600, sla.ServiceTime)
+	}
+	if sla.Maintenance != 2*3600 {
+		t.Errorf("Expected maintenance of %d, got %f", 2*3600, sla.Maintenance)
+	}
+	if sla.Downtime != 3600 || sla.DowntimeMaintenance != 3600 || sla.DowntimeOutside != 0 {
+		t.Errorf("Expected downtime of 3600 inside and during maintenance and none outside, got %f, %f and %f", sla.Downtime, sla.DowntimeMaintenance, sla.DowntimeOutside)
+	}
+	if math.Abs(sla.Achieved-87.5) > 0.000001 {
+		t.Errorf("Expected 87.5%% achieved, got %f%%", sla.Achieved)
+	}
+}
+
+func TestNewDowntimeDuringMaintenance(t *testing.T) {
+	bp := bpmon.BP{
+		ID: "app",
+		Availability: availabilities.Availability{
+			Location: time.UTC,
+			Days: map[time.Weekday]availabilities.AvailabilityTime{
+				time.Monday: availabilities.AvailabilityTime{
+					TimeRanges: []availabilities.TimeRange{
+						{Start: clock("08:00"), End: clock("18:00")},
+					},
+				},
+			},
+		},
+		Maintenance: maintenance.Windows{
+			// the window lasts beyond the availability
+			{ID: "a", BP: "app", Start: at("Mon 2017/03/20 17:30"), End: at("Mon 2017/03/20 19:00")},
+		},
+	}
+	spans := []store.Span{
+		span(status.StatusOK, "Mon 2017/03/20 00:00", "Mon 2017/03/20 17:00"),
+		span(status.StatusNOK, "Mon 2017/03/20 17:00", "Mon 2017/03/20 20:00"),
+		span(status.StatusOK, "Mon 2017/03/20 20:00", "Tue 2017/03/21 00:00"),
+	}
+
+	sla := New(bp, spans, at("Mon 2017/03/20 00:00"), at("Tue 2017/03/21 00:00"))
+	if sla.ServiceTime != 9.5*3600 || sla.Maintenance != 1800 {
+		t.Errorf("Expected service time of 34200 and maintenance of 1800, got %f and %f", sla.ServiceTime, sla.Maintenance)
+	}
+	if sla.Downtime != 1800 {
+		t.Errorf("Expected downtime of 1800, got %f", sla.Downtime)
+	}
+	if sla.DowntimeMaintenance != 1.5*3600 {
+		t.Errorf("Expected downtime during maintenance of 5400, got %f", sla.DowntimeMaintenance)
+	}
+	if sla.DowntimeOutside != 3600 {
+		t.Errorf("Expected downtime outside of 3600, got %f", sla.DowntimeOutside)
+	}
+	if sla.Incidents != 1 {
+		t.Errorf("Expected 1 incident, got %d", sla.Incidents)
+	}
+}
@@ -0,0 +1,171 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/unprofession-al/bpmon/internal/status"
+)
+
+// Condition is a single condition of a Rule as evaluated by 'Explain'.
+type Condition struct {
+	// Key is the value key of the condition.
+	Key string
+
+	// Expected is the value required, 'true' for keys listed in 'Must',
+	// 'false' for keys listed in 'MustNot'.
+	Expected bool
+
+	// Exists tells whether the key exists in the values.
+	Exists bool
+
+	// Value is the value of the key.
+	Value bool
+}
+
+// Passed checks whether the condition is fulfilled.
+func (c Condition) Passed() bool {
+	return c.Exists && c.Value == c.Expected
+}
+
+// String returns the condition and its outcome in a human readable format.
+func (c Condition) String() string {
+	prefix := ""
+	if !c.Expected {
+		prefix = "not "
+	}
+	switch {
+	case !c.Exists:
+		return fmt.Sprintf("%s%s: does not exist", prefix, c.Key)
+	case c.Passed():
+		return fmt.Sprintf("%s%s: passed", prefix, c.Key)
+	}
+	return fmt.Sprintf("%s%s: failed", prefix, c.Key)
+}
+
+// Step is a Rule as evaluated by 'Explain'.
+type Step struct {
+	Match
+
+	// Conditions holds the outcome of the keys listed in 'Must' and
+	// 'MustNot' of the Rule which are evaluated. Just as 'Analyze', the
+	// evaluation of the keys in 'Must' and in 'MustNot' stops at the first
+	// key which fails.
+	Conditions []Condition
+
+	// Evaluated tells whether the expression of the Rule is evaluated,
+	// which is only the case if all keys in 'Must' and 'MustNot' passed.
+	Evaluated bool
+
+	// Expression holds the outcome of the expression of the Rule, if
+	// evaluated.
+	Expression bool
+
+	// Matched tells whether all conditions of the Rule are fulfilled.
+	Matched bool
+
+	// Err is set if the Rule cannot be evaluated.
+	Err error
+}
+
+// evaluate evaluates the Rule of the step against the values as described
+// by 'Analyze'. The conditions are only recorded if 'record' is set.
+func (s *Step) evaluate(values map[string]bool, record bool) {
+	passed := true
+	check := func(keys []string, expected bool) {
+		for _, keyname := range keys {
+			val, ok := values[keyname]
+			if record {
+				s.Conditions = append(s.Conditions, Condition{Key: keyname, Expected: expected, Exists: ok, Value: val})
+			}
+			if !ok {
+				s.Err = fmt.Errorf("key '%s' from rule with order %d does not exist", keyname, s.Order)
+				return
+			}
+			if val != expected {
+				passed = false
+				return
+			}
+		}
+	}
+
+	check(s.Rule.Must, true)
+	if s.Err == nil {
+		check(s.Rule.MustNot, false)
+	}
+	if s.Err != nil {
+		return
+	}
+
+	if s.Rule.Expression != "" && passed {
+		expr, err := s.Rule.expression()
+		if err != nil {
+			s.Err = fmt.Errorf("expression from rule with order %d is invalid: %s", s.Order, err.Error())
+			return
+		}
+		for _, keyname := range expr.keys() {
+			if _, ok := values[keyname]; !ok {
+				s.Err = fmt.Errorf("key '%s' from rule with order %d does not exist", keyname, s.Order)
+				return
+			}
+		}
+		s.Evaluated = true
+		s.Expression = expr.eval(values)
+		passed = s.Expression
+	}
+	s.Matched = passed
+}
+
+// Explanation describes how 'Analyze' arrives at a status.
+type Explanation struct {
+	// Steps holds the Rules in the order evaluated, up to and including the
+	// Rule matched or the Rule which cannot be evaluated.
+	Steps []Step
+
+	// Status, Match and Err are the results of 'Analyze'.
+	Status status.Status
+	Match  Match
+	Err    error
+}
+
+// Explain evaluates the values against the Rules just as 'Analyze', but
+// records the outcome of each condition of each Rule evaluated.
+func (r Rules) Explain(values map[string]bool) Explanation {
+	out := Explanation{}
+	out.Status, out.Match, out.Err = r.evaluate(values, &out.Steps)
+	return out
+}
+
+// String returns the explanation in a human readable format.
+func (e Explanation) String() string {
+	var sb strings.Builder
+	for _, step := range e.Steps {
+		outcome := "not matched"
+		if step.Matched {
+			outcome = "matched"
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", step.Match.String(), outcome)
+		for _, cond := range step.Conditions {
+			fmt.Fprintf(&sb, "    %s\n", cond.String())
+		}
+		if step.Rule.Expression != "" {
+			switch {
+			case !step.Evaluated:
+				fmt.Fprintf(&sb, "    %s: not evaluated\n", step.Rule.Expression)
+			case step.Expression:
+				fmt.Fprintf(&sb, "    %s: passed\n", step.Rule.Expression)
+			default:
+				fmt.Fprintf(&sb, "    %s: failed\n", step.Rule.Expression)
+			}
+		}
+		if step.Err != nil {
+			fmt.Fprintf(&sb, "    error: %s\n", step.Err.Error())
+		}
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&sb, "status: %s (%s)\n", e.Status, e.Err.Error())
+	} else {
+		fmt.Fprintf(&sb, "status: %s\n", e.Status)
+	}
+	return sb.String()
+}
@@ -0,0 +1,192 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expression is a boolean expression over values. Expressions are formated
+// as in 'critical or (warn and not acknowledged)': Names of values are
+// combined by the operators 'and', 'or' and 'not' as well as parentheses.
+// 'not' binds stronger than 'and', which binds stronger than 'or'. Operators
+// are case insensitive.
+type expression interface {
+	// eval evaluates the expression against the values provided. Values
+	// which do not exist are considered false.
+	eval(values map[string]bool) bool
+
+	// keys returns the names of all values used in the expression.
+	keys() []string
+
+	String() string
+}
+
+type valueExpr string
+
+func (e valueExpr) eval(values map[string]bool) bool { return values[string(e)] }
+
+func (e valueExpr) keys() []string { return []string{string(e)} }
+
+func (e valueExpr) String() string { return string(e) }
+
+type notExpr struct {
+	expr expression
+}
+
+func (e notExpr) eval(values map[string]bool) bool { return !e.expr.eval(values) }
+
+func (e notExpr) keys() []string { return e.expr.keys() }
+
+func (e notExpr) String() string { return "not " + e.expr.String() }
+
+type binaryExpr struct {
+	op    string
+	left  expression
+	right expression
+}
+
+func (e binaryExpr) eval(values map[string]bool) bool {
+	if e.op == "and" {
+		return e.left.eval(values) && e.right.eval(values)
+	}
+	return e.left.eval(values) || e.right.eval(values)
+}
+
+func (e binaryExpr) keys() []string { return append(e.left.keys(), e.right.keys()...) }
+
+func (e binaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.left.String(), e.op, e.right.String())
+}
+
+// parseExpression parses the expression 'in'.
+func parseExpression(in string) (expression, error) {
+	p := &parser{tokens: tokenize(in)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("expression cannot be empty")
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected '%s'", tok)
+	}
+	return expr, nil
+}
+
+// tokenize splits the expression into parentheses and words.
+func tokenize(in string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range in {
+		switch {
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// parser is a recursive descent parser of expressions.
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) isOperator(tok string, op string) bool {
+	return strings.ToLower(tok) == op
+}
+
+func (p *parser) parseOr() (expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || !p.isOperator(tok, "or") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "or", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (expression, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || !p.isOperator(tok, "and") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: "and", left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (expression, error) {
+	tok, ok := p.peek()
+	if ok && p.isOperator(tok, "not") {
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr: expr}, nil
+	}
+	return p.parseValue()
+}
+
+func (p *parser) parseValue() (expression, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	switch {
+	case tok == "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, ok := p.peek(); !ok || tok != ")" {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return expr, nil
+	case tok == ")" || p.isOperator(tok, "and") || p.isOperator(tok, "or"):
+		return nil, fmt.Errorf("unexpected '%s'", tok)
+	}
+	p.pos++
+	return valueExpr(tok), nil
+}
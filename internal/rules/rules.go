@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/unprofession-al/bpmon/internal/status"
 )
@@ -12,8 +13,9 @@ import (
 // of how those rules are applied.
 type Rules map[int]Rule
 
-// Rule describes the conditions that must be fulfilled ('Must' and 'MustNot')
-// as well as the result of the rule if all conditions are fulfilled ('Then')
+// Rule describes the conditions that must be fulfilled ('Must', 'MustNot' and
+// 'Expression') as well as the result of the rule if all conditions are
+// fulfilled ('Then')
 type Rule struct {
 	// Must is a list of value keys that must be 'true' in order to fulfill
 	// the Rule.
@@ -23,9 +25,52 @@ type Rule struct {
 	// the Rule.
 	MustNot []string `yaml:"must_not"`
 
+	// Expression is a boolean expression over value keys that must be 'true'
+	// in order to fulfill the Rule, eg. 'critical or (warn and not
+	// acknowledged)'. Value keys are combined by 'and', 'or', 'not' and
+	// parentheses. If empty, the Rule is fulfilled by 'Must' and 'MustNot'
+	// alone.
+	Expression string `yaml:"expression,omitempty"`
+
 	// Then is the resulting 'Status' if all conditions are fulfilled
 	// as defined.
 	Then status.Status `yaml:"then"`
+
+	// parsed holds the 'Expression' parsed when the Rule is read.
+	parsed *parsedExpression
+}
+
+// parsedExpression is the outcome of parsing the expression 'source'.
+type parsedExpression struct {
+	source string
+	expr   expression
+	err    error
+}
+
+// UnmarshalYAML implements the Unmarshaler interface of package yaml.
+// https://godoc.org/gopkg.in/yaml.v2#Unmarshaler
+// The 'Expression' is parsed once rather than each time the Rule is
+// analyzed, errors are reported by 'Validate' and 'Analyze'.
+func (r *Rule) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Rule
+	if err := unmarshal((*plain)(r)); err != nil {
+		return err
+	}
+	r.parsed = nil
+	if r.Expression != "" {
+		expr, err := parseExpression(r.Expression)
+		r.parsed = &parsedExpression{source: r.Expression, expr: expr, err: err}
+	}
+	return nil
+}
+
+// expression returns the parsed 'Expression'. Rules which are not read from
+// YAML, eg. the default rules of a checker, are parsed on each call.
+func (r Rule) expression() (expression, error) {
+	if r.parsed != nil && r.parsed.source == r.Expression {
+		return r.parsed.expr, r.parsed.err
+	}
+	return parseExpression(r.Expression)
 }
 
 // Merge adds a new set of Rules to the Rules that call the method. If a Rule
@@ -34,67 +79,148 @@ type Rule struct {
 func (r Rules) Merge(additional Rules) error {
 	for order, a := range additional {
 		rule := Rule{
-			Must:    a.Must,
-			MustNot: a.MustNot,
-			Then:    a.Then,
+			Must:       a.Must,
+			MustNot:    a.MustNot,
+			Expression: a.Expression,
+			Then:       a.Then,
+			parsed:     a.parsed,
 		}
 		r[order] = rule
 	}
 	return nil
 }
 
+// Override returns the Rules merged with the 'additional' Rules as done by
+// 'Merge'. In contrast to 'Merge', the Rules which call the method are not
+// modified.
+func (r Rules) Override(additional Rules) Rules {
+	if len(additional) == 0 {
+		return r
+	}
+	out := Rules{}
+	for order, rule := range r {
+		out[order] = rule
+	}
+	out.Merge(additional)
+	return out
+}
+
+// String returns the conditions and the result of the Rule in a human
+// readable format, eg. 'critical and not scheduled_downtime then not ok'.
+func (r Rule) String() string {
+	var conds []string
+	conds = append(conds, r.Must...)
+	for _, keyname := range r.MustNot {
+		conds = append(conds, "not "+keyname)
+	}
+	if r.Expression != "" {
+		conds = append(conds, "("+r.Expression+")")
+	}
+	if len(conds) == 0 {
+		conds = append(conds, "always")
+	}
+	return fmt.Sprintf("%s then %s", strings.Join(conds, " and "), r.Then)
+}
+
+// Match identifies the Rule applied by 'Analyze'.
+type Match struct {
+	// Order is the position of the Rule.
+	Order int
+
+	// Rule is the Rule itself.
+	Rule Rule
+}
+
+// String returns the Rule matched in a human readable format.
+func (m Match) String() string {
+	return fmt.Sprintf("rule %d: %s", m.Order, m.Rule.String())
+}
+
+// Validate checks the Rules for errors and returns a list of messages. The
+// expressions of the Rules are parsed and all value keys used must be listed
+// in 'values', usually the values provided by the checker.
+func (r Rules) Validate(values []string) ([]string, error) {
+	known := make(map[string]bool)
+	var names []string
+	for _, v := range values {
+		if !known[v] {
+			names = append(names, v)
+		}
+		known[v] = true
+	}
+	sort.Strings(names)
+
+	errs := []string{}
+	for _, index := range r.order() {
+		rule := r[index]
+		keys := append(append([]string{}, rule.Must...), rule.MustNot...)
+		if rule.Expression != "" {
+			expr, err := rule.expression()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("Field 'expression' of rule with order %d is invalid: %s.", index, err.Error()))
+			} else {
+				keys = append(keys, expr.keys()...)
+			}
+		}
+		seen := make(map[string]bool)
+		for _, keyname := range keys {
+			if !known[keyname] && !seen[keyname] {
+				errs = append(errs, fmt.Sprintf("Key '%s' of rule with order %d is not provided by the checker, use one of '%s'.", keyname, index, strings.Join(names, "', '")))
+			}
+			seen[keyname] = true
+		}
+	}
+	if len(errs) > 0 {
+		return errs, errors.New("rules have errors")
+	}
+	return errs, nil
+}
+
+// order returns the positions of the Rules in ascending order.
+func (r Rules) order() []int {
+	var order []int
+	for index := range r {
+		order = append(order, index)
+	}
+	sort.Ints(order)
+	return order
+}
+
 // Analyze takes values (as in store.ResultSet) and validates those values
 // against the Rules. It does so by:
 //
 //		* Starting at the first rule (rule with the smallest index).
 //		* Checking if all fields listed in 'Must' are true.
 //		* Checking if all fields listed in 'MustNot' are false.
-//		* Returning the status defined in 'Then' if the conditions
-//		  above apply.
+//		* Checking if the 'Expression' is true, if any.
+//		* Returning the status defined in 'Then' as well as the rule matched
+//		  if the conditions above apply.
 //		* Proceeding to the next rule if the current rules contiditions are
 //		  not fulfilled.
 //
-// If a 'Must' of 'MustNot' key does not exist in the values, an error
-// is returned.
+// If a 'Must', 'MustNot' or 'Expression' key does not exist in the values,
+// or if an 'Expression' cannot be parsed, an error is returned.
 //
 // If no Rules apply, status 'Unknown' is returned.
-func (r Rules) Analyze(values map[string]bool) (status.Status, error) {
-	var order []int
-	for index := range r {
-		order = append(order, index)
-	}
-	sort.Ints(order)
-
-	for _, index := range order {
-		matchMustCond := true
-		matchMustNotCond := true
-		rule := r[index]
+func (r Rules) Analyze(values map[string]bool) (status.Status, Match, error) {
+	return r.evaluate(values, nil)
+}
 
-		for _, keyname := range rule.Must {
-			if val, ok := values[keyname]; ok {
-				if !val {
-					matchMustCond = false
-					break
-				}
-			} else {
-				return status.StatusUnknown, fmt.Errorf("key '%s' from rule with order %d does not exist", keyname, index)
-			}
+// evaluate implements 'Analyze'. If 'steps' is not nil, the outcome of each
+// Rule evaluated is appended to 'steps', see 'Explain'.
+func (r Rules) evaluate(values map[string]bool, steps *[]Step) (status.Status, Match, error) {
+	for _, index := range r.order() {
+		step := Step{Match: Match{Order: index, Rule: r[index]}}
+		step.evaluate(values, steps != nil)
+		if steps != nil {
+			*steps = append(*steps, step)
 		}
-
-		for _, keyname := range rule.MustNot {
-			if val, ok := values[keyname]; ok {
-				if val {
-					matchMustNotCond = false
-					break
-				}
-			} else {
-				return status.StatusUnknown, fmt.Errorf("key '%s' from rule with order %d does not exist", keyname, index)
-			}
+		if step.Err != nil {
+			return status.StatusUnknown, Match{}, step.Err
 		}
-
-		if matchMustCond && matchMustNotCond {
-			return rule.Then, nil
+		if step.Matched {
+			return step.Rule.Then, step.Match, nil
 		}
 	}
-	return status.StatusUnknown, errors.New("no rule matched")
+	return status.StatusUnknown, Match{}, errors.New("no rule matched")
 }
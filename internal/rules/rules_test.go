@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/unprofession-al/bpmon/internal/status"
+	yaml "gopkg.in/yaml.v2"
 )
 
 var testRules = map[string]Rules{
@@ -140,7 +141,7 @@ func TestRuleAnalyze(t *testing.T) {
 	rules := testRules["base"]
 
 	for name, ts := range testsets {
-		s, err := rules.Analyze(ts.test)
+		s, _, err := rules.Analyze(ts.test)
 		if ts.errExpected && err == nil {
 			t.Errorf("Error expected but got nil")
 		} else if !ts.errExpected && err != nil {
@@ -151,3 +152,245 @@ func TestRuleAnalyze(t *testing.T) {
 		}
 	}
 }
+
+func TestExpression(t *testing.T) {
+	values := map[string]bool{"critical": false, "warn": true, "acknowledged": false}
+
+	tests := map[string]bool{
+		"critical or (warn and not acknowledged)": true,
+		"critical or warn and acknowledged":       false,
+		"(critical or warn) and acknowledged":     false,
+		"not critical and not acknowledged":       true,
+		"NOT not warn":                            true,
+		"critical OR acknowledged":                false,
+	}
+
+	for in, expected := range tests {
+		expr, err := parseExpression(in)
+		if err != nil {
+			t.Errorf("No error expected for expression '%s' but got error: %s", in, err.Error())
+			continue
+		}
+		if expr.eval(values) != expected {
+			t.Errorf("Expected expression '%s' to be %t, parsed as '%s'", in, expected, expr)
+		}
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                      "expression cannot be empty",
+		"critical or":           "unexpected end of expression",
+		"critical warn":         "unexpected 'warn'",
+		"(critical or warn":     "missing ')'",
+		"critical)":             "unexpected ')'",
+		"and critical":          "unexpected 'and'",
+		"not (critical or and)": "unexpected 'and'",
+	}
+
+	for in, expected := range tests {
+		_, err := parseExpression(in)
+		if err == nil || err.Error() != expected {
+			t.Errorf("Expected error '%s' for expression '%s', got '%v'", expected, in, err)
+		}
+	}
+}
+
+func TestRuleAnalyzeExpression(t *testing.T) {
+	rules := Rules{
+		10: Rule{Must: []string{}, MustNot: []string{"known"}, Then: status.StatusUnknown},
+		20: Rule{Must: []string{}, MustNot: []string{}, Expression: "critical or (warn and not acknowledged)", Then: status.StatusNOK},
+		30: Rule{Must: []string{"warn"}, MustNot: []string{}, Then: status.StatusDegraded},
+		99: Rule{Must: []string{}, MustNot: []string{}, Then: status.StatusOK},
+	}
+
+	testsets := map[string]struct {
+		values map[string]bool
+		status status.Status
+		order  int
+	}{
+		"critical":         {values: map[string]bool{"known": true, "critical": true, "warn": false, "acknowledged": false}, status: status.StatusNOK, order: 20},
+		"warn":             {values: map[string]bool{"known": true, "critical": false, "warn": true, "acknowledged": false}, status: status.StatusNOK, order: 20},
+		"acknowledged":     {values: map[string]bool{"known": true, "critical": false, "warn": true, "acknowledged": true}, status: status.StatusDegraded, order: 30},
+		"nothing to see":   {values: map[string]bool{"known": true, "critical": false, "warn": false, "acknowledged": false}, status: status.StatusOK, order: 99},
+		"unknown to rules": {values: map[string]bool{"known": false}, status: status.StatusUnknown, order: 10},
+	}
+
+	for name, ts := range testsets {
+		s, match, err := rules.Analyze(ts.values)
+		if err != nil {
+			t.Errorf("No error expected for test '%s' but got error: %s", name, err.Error())
+		}
+		if s != ts.status || match.Order != ts.order {
+			t.Errorf("Expected status '%s' by rule %d for '%s', got '%s' by rule %d", ts.status, ts.order, name, s, match.Order)
+		}
+	}
+
+	_, _, err := rules.Analyze(map[string]bool{"known": true, "critical": false})
+	if err == nil {
+		t.Errorf("Error expected for expression with key which does not exist but got nil")
+	}
+
+	expected := "rule 20: (critical or (warn and not acknowledged)) then not ok"
+	if _, match, _ := rules.Analyze(testsets["critical"].values); match.String() != expected {
+		t.Errorf("Expected match to be '%s', got '%s'", expected, match.String())
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	values := []string{"critical", "warn", "acknowledged"}
+
+	tests := map[string]struct {
+		rules Rules
+		errs  int
+	}{
+		"valid": {
+			rules: Rules{10: Rule{Must: []string{"warn"}, MustNot: []string{"acknowledged"}, Expression: "not critical", Then: status.StatusDegraded}},
+			errs:  0,
+		},
+		"unknown key in must": {
+			rules: Rules{10: Rule{Must: []string{"warning"}, Then: status.StatusDegraded}},
+			errs:  1,
+		},
+		"unknown key in expression": {
+			rules: Rules{10: Rule{Expression: "critical or down or down", Then: status.StatusNOK}},
+			errs:  1,
+		},
+		"malformed expression": {
+			rules: Rules{10: Rule{Expression: "critical or", Then: status.StatusNOK}},
+			errs:  1,
+		},
+	}
+
+	for name, test := range tests {
+		errs, err := test.rules.Validate(values)
+		if len(errs) != test.errs || (err == nil) != (test.errs == 0) {
+			t.Errorf("Expected %d errors for '%s', got %d: %v", test.errs, name, len(errs), errs)
+		}
+	}
+}
+
+func TestRuleOverride(t *testing.T) {
+	base := Rules{}
+	for k, v := range testRules["base"] {
+		base[k] = v
+	}
+	out := base.Override(testRules["overwrite"])
+	if !reflect.DeepEqual(out, testRules["base+overwrite"]) {
+		t.Errorf("Results do not match: '%v' vs. '%v'", out, testRules["base+overwrite"])
+	}
+	if !reflect.DeepEqual(base, testRules["base"]) {
+		t.Errorf("Rules overridden must not be modified, got '%v'", base)
+	}
+}
+
+func TestRuleExplain(t *testing.T) {
+	rules := Rules{
+		10: Rule{Must: []string{}, MustNot: []string{"known"}, Then: status.StatusUnknown},
+		20: Rule{Must: []string{}, MustNot: []string{"acknowledged"}, Expression: "critical or warn", Then: status.StatusNOK},
+		30: Rule{Must: []string{"warn"}, MustNot: []string{}, Then: status.StatusDegraded},
+		99: Rule{Must: []string{}, MustNot: []string{}, Then: status.StatusOK},
+	}
+
+	testsets := map[string]struct {
+		values map[string]bool
+		steps  []bool
+	}{
+		"critical":       {values: map[string]bool{"known": true, "critical": true, "warn": false, "acknowledged": false}, steps: []bool{false, true}},
+		"acknowledged":   {values: map[string]bool{"known": true, "critical": false, "warn": true, "acknowledged": true}, steps: []bool{false, false, true}},
+		"nothing to see": {values: map[string]bool{"known": true, "critical": false, "warn": false, "acknowledged": false}, steps: []bool{false, false, false, true}},
+		"unknown":        {values: map[string]bool{"known": false}, steps: []bool{true}},
+	}
+
+	for name, ts := range testsets {
+		e := rules.Explain(ts.values)
+		s, match, _ := rules.Analyze(ts.values)
+		if e.Err != nil {
+			t.Errorf("No error expected for test '%s' but got error: %s", name, e.Err.Error())
+		}
+		if e.Status != s || e.Match.Order != match.Order {
+			t.Errorf("Expected explanation of '%s' to match analysis '%s' by rule %d, got '%s' by rule %d", name, s, match.Order, e.Status, e.Match.Order)
+		}
+		var steps []bool
+		for _, step := range e.Steps {
+			steps = append(steps, step.Matched)
+		}
+		if !reflect.DeepEqual(steps, ts.steps) {
+			t.Errorf("Expected steps of '%s' to be '%v', got '%v'", name, ts.steps, steps)
+		}
+	}
+
+	e := rules.Explain(testsets["acknowledged"].values)
+	expected := []Condition{{Key: "acknowledged", Expected: false, Exists: true, Value: true}}
+	if !reflect.DeepEqual(e.Steps[1].Conditions, expected) {
+		t.Errorf("Expected conditions to be '%v', got '%v'", expected, e.Steps[1].Conditions)
+	}
+	if e.Steps[1].Evaluated {
+		t.Errorf("Expected expression of rule 20 not to be evaluated as its key in 'must_not' failed")
+	}
+
+	e = rules.Explain(map[string]bool{"known": true, "acknowledged": false})
+	if e.Err == nil || len(e.Steps) != 2 || e.Steps[1].Err == nil {
+		t.Errorf("Expected explanation to stop with an error at rule 20 for expression with key which does not exist, got '%v'", e)
+	}
+}
+
+func TestRuleExplainMatchesAnalyze(t *testing.T) {
+	rules := Rules{
+		10: Rule{Must: []string{"warn", "missing"}, MustNot: []string{}, Then: status.StatusDegraded},
+		20: Rule{Must: []string{"critical"}, MustNot: []string{}, Expression: "missing", Then: status.StatusNOK},
+		30: Rule{Must: []string{}, MustNot: []string{"critical"}, Expression: "missing or warn", Then: status.StatusUnknown},
+		99: Rule{Must: []string{}, MustNot: []string{}, Then: status.StatusOK},
+	}
+
+	testsets := map[string]map[string]bool{
+		"skipped keys":   {"warn": false, "critical": true},
+		"missing key":    {"warn": true, "critical": false},
+		"no expressions": {"warn": false, "critical": false},
+		"matched":        {"warn": false, "critical": false, "missing": true},
+	}
+
+	for name, values := range testsets {
+		s, match, err := rules.Analyze(values)
+		e := rules.Explain(values)
+		if e.Status != s || e.Match.Order != match.Order || (e.Err == nil) != (err == nil) {
+			t.Errorf("Expected explanation of '%s' to match analysis '%s' by rule %d (%v), got '%s' by rule %d (%v)", name, s, match.Order, err, e.Status, e.Match.Order, e.Err)
+		}
+	}
+
+	e := rules.Explain(testsets["skipped keys"])
+	expected := []Condition{{Key: "warn", Expected: true, Exists: true, Value: false}}
+	if len(e.Steps) == 0 || !reflect.DeepEqual(e.Steps[0].Conditions, expected) {
+		t.Errorf("Expected evaluation of rule 10 to stop at the first key failed, got '%v'", e.Steps)
+	}
+}
+
+func TestRuleUnmarshalExpression(t *testing.T) {
+	var r Rules
+	in := "10: { must: [], must_not: [], expression: critical or warn, then: not ok }\n" +
+		"20: { must: [], must_not: [], expression: critical or, then: not ok }\n"
+	if err := yaml.Unmarshal([]byte(in), &r); err != nil {
+		t.Fatalf("No error expected while reading rules, got: %s", err.Error())
+	}
+	if r[10].parsed == nil || r[10].parsed.err != nil {
+		t.Fatalf("Expected expression of rule 10 to be parsed when read, got '%v'", r[10].parsed)
+	}
+	if r[20].parsed == nil || r[20].parsed.err == nil {
+		t.Fatalf("Expected error of expression of rule 20 to be kept when read, got '%v'", r[20].parsed)
+	}
+
+	s, match, err := r.Analyze(map[string]bool{"critical": false, "warn": true})
+	if err != nil || s != status.StatusNOK || match.Order != 10 {
+		t.Errorf("Expected status '%s' by rule 10, got '%s' by rule %d (%v)", status.StatusNOK, s, match.Order, err)
+	}
+
+	if errs, _ := r.Validate([]string{"critical", "warn"}); len(errs) != 1 {
+		t.Errorf("Expected 1 error for the malformed expression, got %d: %v", len(errs), errs)
+	}
+
+	changed := r[10]
+	changed.Expression = "critical"
+	if expr, err := changed.expression(); err != nil || expr.String() != "critical" {
+		t.Errorf("Expected a modified expression to be parsed again, got '%v' (%v)", expr, err)
+	}
+}
@@ -33,6 +33,7 @@ func Defaults() Runners {
                 since: {{ $svc.Start.Format "2006-01-02 15:04:05" }}
           responsible: {{ $svc.Responsible }}
                values: {{ range $key, $val := $svc.Vals }}{{$key}}={{$val}} {{ end }}
+                 rule: {{ $svc.Rule }}
     {{- end -}}
   {{ end }}
 {{ end }}
@@ -77,6 +78,7 @@ func Defaults() Runners {
                 since: {{ $svc.Start.Format "2006-01-02 15:04:05" }}
           responsible: {{ $svc.Responsible }}
                values: {{ range $key, $val := $svc.Vals }}{{$key}}={{$val}} {{ end }}
+                 rule: {{ $svc.Rule }}
               message: {{ $svc.Output }}
                 error: {{ $svc.Err }}
           {{- end -}}
@@ -2,6 +2,7 @@ package runners
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -31,9 +32,20 @@ func getFuncs() template.FuncMap {
 		"describe": func(i interface{}) string {
 			return describeStruct(i, 0)
 		},
+
+		// functions which require access to the store, they are replaced
+		// via 'Runners.Funcs' when a store is available
+		"slo": func(bp string) (interface{}, error) {
+			return nil, errNoStore
+		},
+		"burnRate": func(bp string, window string) (float64, error) {
+			return 0, errNoStore
+		},
 	}
 }
 
+var errNoStore = errors.New("function requires a store which is not available")
+
 func describeStruct(t interface{}, depth int) string {
 	prefix := strings.Repeat("  ", depth)
 	var out string
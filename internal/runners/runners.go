@@ -68,6 +68,14 @@ func (r *Runners) AdHoc(t string) (name string, err error) {
 	return name, nil
 }
 
+// Funcs replaces the functions available in the templates of all runners.
+// Only functions already known to the templates can be replaced.
+func (r Runners) Funcs(funcs template.FuncMap) {
+	for _, runner := range r {
+		runner.Template.Funcs(funcs)
+	}
+}
+
 type Runner struct {
 	Template    *template.Template `yaml:"template"`
 	Description string             `yaml:"description"`
@@ -11,17 +11,37 @@ import (
 // Status represests the status itself.
 type Status int
 
-// The status code list.
+// The status code list. The integers are persisted and must therefore not
+// be changed, new status are appended.
 const (
 	StatusOK Status = Status(iota)
 	StatusNOK
 	StatusUnknown
+	// StatusDegraded is the status of a result which is still ok but
+	// impaired, eg. a service in warning state or a KPI of which some but
+	// not too many services are not ok.
+	StatusDegraded
 )
 
 var statusText = map[Status]string{
-	StatusOK:      "ok",
-	StatusNOK:     "not ok",
-	StatusUnknown: "unknown",
+	StatusOK:       "ok",
+	StatusNOK:      "not ok",
+	StatusUnknown:  "unknown",
+	StatusDegraded: "degraded",
+}
+
+// statusAlias holds alternative strings accepted by 'FromString'.
+var statusAlias = map[string]Status{
+	"warning": StatusDegraded,
+}
+
+// severity defines the precedence of the status, from the least to the most
+// severe: 'ok', 'degraded', 'unknown', 'not ok'.
+var severity = map[Status]int{
+	StatusOK:       0,
+	StatusDegraded: 1,
+	StatusUnknown:  2,
+	StatusNOK:      3,
 }
 
 // String implements the stringer interface.
@@ -37,6 +57,9 @@ func FromString(in string) (Status, error) {
 			return Status(status), nil
 		}
 	}
+	if status, ok := statusAlias[in]; ok {
+		return status, nil
+	}
 	return StatusUnknown, fmt.Errorf("string '%s' is not a valid status", in)
 }
 
@@ -50,6 +73,8 @@ func FromInt64(in int64) (Status, error) {
 		return StatusNOK, nil
 	case StatusUnknown:
 		return StatusUnknown, nil
+	case StatusDegraded:
+		return StatusDegraded, nil
 	default:
 		return StatusUnknown, fmt.Errorf("integer '%d' is not a valid status", in)
 	}
@@ -70,6 +95,8 @@ func (s Status) Colorize(in string) string {
 		out = ansi.Color(in, "red+b")
 	case StatusUnknown:
 		out = ansi.Color(in, "cyan+b")
+	case StatusDegraded:
+		out = ansi.Color(in, "yellow")
 	}
 	return out
 }
@@ -83,12 +110,31 @@ func FromBool(ok bool) Status {
 	return StatusNOK
 }
 
-// Bool returns on boolean representation of the status. Status 'Unknown' is
-// considered true.
+// Bool returns on boolean representation of the status. Status 'Unknown' and
+// 'Degraded' are considered true.
 func (s Status) Bool() bool {
 	return s != StatusNOK
 }
 
+// Worse checks whether the status is more severe than the status 'other'.
+// The status are ordered as 'ok', 'degraded', 'unknown' and 'not ok', the
+// latter being the most severe.
+func (s Status) Worse(other Status) bool {
+	return severity[s] > severity[other]
+}
+
+// Worst returns the most severe of the status provided. If no status is
+// provided, 'OK' is returned.
+func Worst(stati ...Status) Status {
+	out := StatusOK
+	for _, s := range stati {
+		if s.Worse(out) {
+			out = s
+		}
+	}
+	return out
+}
+
 // UnmarshalYAML implements the Unmarshaler interface of package yaml.
 // https://godoc.org/gopkg.in/yaml.v2#Unmarshaler
 func (s *Status) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -97,7 +143,7 @@ func (s *Status) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 	st, err := FromString(aux)
-	s = &st
+	*s = st
 	return err
 }
 
@@ -0,0 +1,58 @@
+package status
+
+import "testing"
+
+func TestWorst(t *testing.T) {
+	tests := []struct {
+		in  []Status
+		out Status
+	}{
+		{[]Status{}, StatusOK},
+		{[]Status{StatusOK, StatusOK}, StatusOK},
+		{[]Status{StatusOK, StatusDegraded}, StatusDegraded},
+		{[]Status{StatusUnknown, StatusDegraded, StatusOK}, StatusUnknown},
+		{[]Status{StatusDegraded, StatusNOK, StatusUnknown}, StatusNOK},
+	}
+
+	for _, test := range tests {
+		out := Worst(test.in...)
+		if out != test.out {
+			t.Errorf("Expected the worst of %v to be '%s', got '%s'", test.in, test.out, out)
+		}
+	}
+}
+
+func TestConversions(t *testing.T) {
+	ints := map[int64]Status{0: StatusOK, 1: StatusNOK, 2: StatusUnknown, 3: StatusDegraded}
+	for in, expected := range ints {
+		st, err := FromInt64(in)
+		if err != nil || st != expected {
+			t.Errorf("Expected integer %d to be status '%s', got '%s' and %v", in, expected, st, err)
+		}
+		if int64(st.Int()) != in {
+			t.Errorf("Expected status '%s' to be integer %d, got %d", st, in, st.Int())
+		}
+	}
+	if _, err := FromInt64(4); err == nil {
+		t.Errorf("Error expected for integer 4 but got nil")
+	}
+
+	strs := map[string]Status{"ok": StatusOK, "not ok": StatusNOK, "unknown": StatusUnknown, "degraded": StatusDegraded, "warning": StatusDegraded}
+	for in, expected := range strs {
+		st, err := FromString(in)
+		if err != nil || st != expected {
+			t.Errorf("Expected string '%s' to be status '%s', got '%s' and %v", in, expected, st, err)
+		}
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	var st Status
+	err := st.UnmarshalYAML(func(out interface{}) error {
+		*(out.(*string)) = "degraded"
+		return nil
+	})
+	if err != nil || st != StatusDegraded {
+		t.Errorf("Expected status '%s', got '%s' and %v", StatusDegraded, st, err)
+	}
+}
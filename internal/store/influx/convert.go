@@ -85,6 +85,8 @@ func (i Influx) asResultSet(data map[string]interface{}) (store.ResultSet, error
 				out.Tags[store.KindKeyPerformanceIndicator] = v.(string)
 			case store.KindService:
 				out.Tags[store.KindService] = v.(string)
+			case store.KindReference:
+				out.Tags[store.KindReference] = v.(string)
 			case "err":
 				out.Err = errors.New(v.(string))
 			case "output":
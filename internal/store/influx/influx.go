@@ -55,6 +55,11 @@ func Setup(conf store.Config) (store.Accessor, error) {
 	return cli, err
 }
 
+// Close closes the HTTP client of the store.
+func (i Influx) Close() error {
+	return i.cli.Close()
+}
+
 func (i Influx) Health() (string, error) {
 	_, out, err := i.cli.Ping(i.timeout)
 	return out, err
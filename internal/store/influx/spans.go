@@ -9,25 +9,8 @@ import (
 	"github.com/unprofession-al/bpmon/internal/store"
 )
 
-type spans []store.Span
-
-func (s spans) FilterByStatus(stati []status.Status) spans {
-	if len(stati) == 0 {
-		return s
-	}
-	var out spans
-	for _, span := range s {
-		for _, st := range stati {
-			if span.Status == st {
-				out = append(out, span)
-			}
-		}
-	}
-	return out
-}
-
 func (i Influx) GetSpans(rs store.ResultSet, start time.Time, end time.Time, interval time.Duration, stati []status.Status) ([]store.Span, error) {
-	var out spans
+	var out store.Spans
 	var err error
 	if i.getLastStatus {
 		for _, saveOkKind := range i.saveOK {
@@ -41,160 +24,33 @@ func (i Influx) GetSpans(rs store.ResultSet, start time.Time, end time.Time, int
 	return out.FilterByStatus(stati), err
 }
 
-func (i Influx) getSpans(rs store.ResultSet, start time.Time, end time.Time) ([]store.Span, error) {
-	out := []store.Span{}
-	totalDuration := end.Sub(start).Seconds()
-
+func (i Influx) getSpans(rs store.ResultSet, start time.Time, end time.Time) (store.Spans, error) {
 	q := newSelectQuery().From(rs.Kind().String()).Between(start, end).FilterTags(rs.Tags).Filter("changed = true")
 	resultsets, err := i.Run(q)
 	if err != nil {
 		msg := fmt.Sprintf("Cannot run query, error is: %s", err.Error())
-		return out, errors.New(msg)
-	}
-
-	earliestSpan := end
-	for i, resultset := range resultsets {
-		current := store.Span{
-			Tags:       resultset.Tags,
-			Pseudo:     false,
-			Status:     resultset.Status,
-			Annotation: resultset.Annotation,
-		}
-		current.Start = resultset.Start
-		if current.Start.Before(earliestSpan) {
-			earliestSpan = current.Start
-		}
-		current.End = end
-		if next := i + 1; next < len(resultsets) {
-			current.End = resultsets[i+1].Start
-		}
-		current.Duration = current.End.Sub(current.Start).Seconds()
-		current.DurationPercent = 100.0 / totalDuration * current.Duration
-		current.SetID()
-		out = append(out, current)
+		return store.Spans{}, errors.New(msg)
 	}
 
 	// get last state before 'start'
 	gap, _ := time.ParseDuration("30m")
 	q = newSelectQuery().From(rs.Kind().String()).Between(start.Add(gap*-1), start).FilterTags(rs.Tags).OrderBy("time").Desc().Limit(1)
 	last, err := i.First(q)
-
 	if err != nil {
 		debugString := fmt.Sprintf("error: %s - query: %s", err.Error(), q.Query())
-		// if no state at all is found
-		complete := store.Span{
-			Status:     status.StatusUnknown,
-			Pseudo:     true,
-			Annotation: debugString,
-			Start:      start,
-			End:        earliestSpan,
-			Tags:       rs.Tags,
-		}
-		complete.Duration = complete.End.Sub(complete.Start).Seconds()
-		complete.DurationPercent = 100.0 / totalDuration * complete.Duration
-		complete.SetID()
-		out = append([]store.Span{complete}, out...)
-	} else {
-		duration := earliestSpan.Sub(start).Seconds()
-		durationPercent := 100.0 / totalDuration * duration
-		first := store.Span{
-			Start:           start,
-			End:             earliestSpan,
-			Pseudo:          true,
-			Duration:        duration,
-			DurationPercent: durationPercent,
-			Tags:            last.Tags,
-			Status:          last.Status,
-			Annotation:      last.Annotation,
-		}
-		first.SetID()
-		out = append([]store.Span{first}, out...)
+		return store.SpansFromChanges(rs.Tags, resultsets, nil, debugString, start, end), nil
 	}
-
-	return out, nil
+	return store.SpansFromChanges(rs.Tags, resultsets, &last, "", start, end), nil
 }
 
-func (i Influx) assumeSpans(rs store.ResultSet, start time.Time, end time.Time, interval time.Duration) ([]store.Span, error) {
-	duration := end.Sub(start).Seconds()
-	s := []store.Span{
-		{
-			Status:     status.StatusOK,
-			Annotation: "",
-			Start:      start,
-			End:        end,
-			Tags:       rs.Tags,
-		},
-	}
-
+func (i Influx) assumeSpans(rs store.ResultSet, start time.Time, end time.Time, interval time.Duration) (store.Spans, error) {
 	q := newSelectQuery().From(rs.Kind().String()).Between(start, end).FilterTags(rs.Tags)
 	rows, err := i.Run(q)
 	if err != nil {
 		msg := fmt.Sprintf("Cannot run query, error is: %s", err.Error())
-		return s, errors.New(msg)
-	}
-
-	lastIndex := len(s) - 1
-
-	for _, row := range rows {
-		last := s[lastIndex]
-		replace := false
-
-		current := store.Span{
-			Tags:       row.Tags,
-			Status:     row.Status,
-			Start:      row.Start,
-			Annotation: row.Annotation,
-		}
-		current.End = current.Start.Add(interval)
-		if current.Start.Before(last.End) {
-			if last.Status == current.Status {
-				current.Start = last.Start
-				current.Annotation = last.Annotation
-				replace = true
-			} else {
-				last.End = current.Start
-				s[lastIndex] = last
-			}
-		} else if current.Start.After(last.End) {
-			filler := store.Span{
-				Start:      last.End,
-				End:        current.Start,
-				Status:     status.StatusOK,
-				Annotation: "",
-			}
-			s = append(s, filler)
-		}
-
-		// igrnore that case for now
-		// if current.End.Before(last.End) {}
-
-		if replace {
-			s[lastIndex] = current
-		} else {
-			s = append(s, current)
-		}
-
-		lastIndex = len(s) - 1
-	}
-
-	lastEvent := s[len(s)-1]
-	if lastEvent.End != end {
-		filler := store.Span{
-			Start:      lastEvent.End,
-			End:        end,
-			Status:     status.StatusOK,
-			Annotation: "",
-		}
-		s = append(s, filler)
-	}
-
-	for i, e := range s {
-		e.Duration = e.End.Sub(e.Start).Seconds()
-		e.DurationPercent = 100.0 / duration * e.Duration
-		e.SetID()
-		s[i] = e
+		return store.AssumeSpans(rs.Tags, nil, start, end, interval), errors.New(msg)
 	}
-	return s, nil
+	return store.AssumeSpans(rs.Tags, rows, start, end, interval), nil
 }
 
 func (i Influx) Annotate(id store.ID, annotation string) (store.ResultSet, error) {
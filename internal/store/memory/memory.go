@@ -0,0 +1,322 @@
+// Package memory provides a 'store.Accessor' implementation which keeps all
+// results in memory. The results can optionally be persisted to an append
+// only file in order to keep them across restarts. This allows to use BPMON
+// without any infrastructure as well as to run tests offline.
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// lastStatusGap defines how far before the start of the requested period the
+// last status is looked up.
+const lastStatusGap = 30 * time.Minute
+
+type Memory struct {
+	saveOK        []string
+	getLastStatus bool
+	printQueries  bool
+	path          string
+
+	mu      sync.Mutex
+	records []record
+	index   map[string]int
+	offset  int64
+}
+
+func init() {
+	store.Register("memory", Setup)
+}
+
+// Setup configures the 'Accessor' implementation and returns it. The
+// connection string is either 'memory://' to keep the results in memory only
+// or 'file://[path]' to persist them to the file at 'path'. Results already
+// persisted to the file are loaded.
+func Setup(conf store.Config) (store.Accessor, error) {
+	m := &Memory{
+		saveOK:        conf.SaveOK,
+		getLastStatus: conf.GetLastStatus,
+		printQueries:  conf.Debug,
+		index:         make(map[string]int),
+	}
+
+	u, err := url.Parse(conf.Connection)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "memory":
+		return m, nil
+	case "file":
+		m.path = strings.TrimPrefix(conf.Connection, "file://")
+		if m.path == "" {
+			return nil, errors.New("path of the file is missing in connection string")
+		}
+		return m, m.load()
+	}
+	return nil, fmt.Errorf("scheme '%s' is not supported, use memory:// or file://", u.Scheme)
+}
+
+// load reads all records appended to the file since it was read the last
+// time. This allows several processes (eg. the serve and the dashboard
+// subcommands) to share the same file. Records appended later replace
+// earlier records of the same entity and time, eg. when annotated. The
+// caller must hold the lock.
+func (m *Memory) load() error {
+	if m.path == "" {
+		return nil
+	}
+	f, err := os.Open(m.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Seek(m.offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// incomplete lines are read once completed
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var r record
+			err = json.Unmarshal(line, &r)
+			if err != nil {
+				return fmt.Errorf("could not read '%s' at offset %d: %s", m.path, m.offset, err.Error())
+			}
+			m.put(r)
+		}
+		m.offset += int64(len(line))
+	}
+}
+
+// put adds the record or replaces the record of the same entity and time.
+// The caller must hold the lock.
+func (m *Memory) put(r record) {
+	key := r.key()
+	if i, ok := m.index[key]; ok {
+		m.records[i] = r
+		return
+	}
+	m.index[key] = len(m.records)
+	m.records = append(m.records, r)
+}
+
+// persist appends the records to the file if configured. The caller must
+// hold the lock.
+func (m *Memory) persist(records []record) error {
+	if m.path == "" || len(records) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (m *Memory) Health() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.path == "" {
+		return fmt.Sprintf("%d results kept in memory", len(m.records)), nil
+	}
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	return fmt.Sprintf("%d results kept in memory and persisted to '%s'", len(m.records), m.path), nil
+}
+
+// Write implements the 'Accessor' interface. If 'debug' is set, the records
+// are printed but not kept, just as with the other stores.
+func (m *Memory) Write(rs *store.ResultSet) error {
+	records := m.asRecords(rs)
+	if m.printQueries {
+		for _, r := range records {
+			data, _ := json.Marshal(r)
+			fmt.Println(string(data))
+		}
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range records {
+		m.put(r)
+	}
+	return m.persist(records)
+}
+
+func (m *Memory) GetLatest(rs store.ResultSet) (store.ResultSet, error) {
+	all, err := m.find(rs.Kind(), rs.Tags, func(r record) bool { return true })
+	if err != nil {
+		return store.ResultSet{}, err
+	}
+	if len(all) == 0 {
+		return store.ResultSet{}, errors.New("no data returned")
+	}
+	return all[len(all)-1], nil
+}
+
+func (m *Memory) GetSpans(rs store.ResultSet, start time.Time, end time.Time, interval time.Duration, stati []status.Status) ([]store.Span, error) {
+	var out store.Spans
+	between := func(s, e time.Time) func(r record) bool {
+		return func(r record) bool {
+			return r.Time.After(s) && r.Time.Before(e)
+		}
+	}
+
+	if !m.getLastStatus || !stringInSlice(rs.Kind().String(), m.saveOK) {
+		rows, err := m.find(rs.Kind(), rs.Tags, between(start, end))
+		if err != nil {
+			return out, err
+		}
+		out = store.AssumeSpans(rs.Tags, rows, start, end, interval)
+		return out.FilterByStatus(stati), nil
+	}
+
+	inPeriod := between(start, end)
+	changes, err := m.find(rs.Kind(), rs.Tags, func(r record) bool {
+		return inPeriod(r) && r.Changed != nil && *r.Changed
+	})
+	if err != nil {
+		return out, err
+	}
+
+	before, err := m.find(rs.Kind(), rs.Tags, between(start.Add(-lastStatusGap), start))
+	if err != nil {
+		return out, err
+	}
+	if len(before) == 0 {
+		reason := fmt.Sprintf("no status found between %s and %s", start.Add(-lastStatusGap).Format(time.RFC3339), start.Format(time.RFC3339))
+		out = store.SpansFromChanges(rs.Tags, changes, nil, reason, start, end)
+	} else {
+		out = store.SpansFromChanges(rs.Tags, changes, &before[len(before)-1], "", start, end)
+	}
+	return out.FilterByStatus(stati), nil
+}
+
+func (m *Memory) Annotate(id store.ID, annotation string) (store.ResultSet, error) {
+	rs, err := id.GetResultSet()
+	if err != nil {
+		return rs, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = m.load()
+	if err != nil {
+		return rs, err
+	}
+
+	key := newRecord(&rs).key()
+	i, ok := m.index[key]
+	if !ok {
+		return rs, errors.New("no data returned")
+	}
+	r := m.records[i]
+	r.Annotated = true
+	r.Annotation = annotation
+	if m.printQueries {
+		data, _ := json.Marshal(r)
+		fmt.Println(string(data))
+		return r.asResultSet(), nil
+	}
+	m.records[i] = r
+
+	err = m.persist([]record{r})
+	return r.asResultSet(), err
+}
+
+// find returns all result sets of the kind requested whose tags match the
+// tags provided and which are accepted by the filter, ordered by time.
+func (m *Memory) find(kind store.Kind, tags map[store.Kind]string, filter func(record) bool) ([]store.ResultSet, error) {
+	m.mu.Lock()
+	err := m.load()
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	var matches []record
+	for _, r := range m.records {
+		if r.Kind != kind || !r.matches(tags) || !filter(r) {
+			continue
+		}
+		matches = append(matches, r)
+	}
+	m.mu.Unlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Time.Before(matches[j].Time)
+	})
+	var out []store.ResultSet
+	for _, r := range matches {
+		out = append(out, r.asResultSet())
+	}
+	return out, nil
+}
+
+// asRecords returns the records to be written for a result set and all its
+// children. As in other stores, results with status 'ok' are only written
+// if their kind is listed in 'saveOK'.
+func (m *Memory) asRecords(rs *store.ResultSet) []record {
+	var out []record
+	if rs.Status != status.StatusOK || stringInSlice(rs.Kind().String(), m.saveOK) {
+		out = append(out, newRecord(rs))
+	}
+	for _, childRs := range rs.Children {
+		out = append(out, m.asRecords(childRs)...)
+	}
+	return out
+}
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if strings.EqualFold(a, b) {
+			return true
+		}
+	}
+	return false
+}
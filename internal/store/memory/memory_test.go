@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+	"github.com/unprofession-al/bpmon/internal/store/storetest"
+)
+
+func newTestStore(t *testing.T, connection string) store.Accessor {
+	s, err := Setup(store.Config{
+		Kind:          "memory",
+		Connection:    connection,
+		SaveOK:        []string{"BP"},
+		GetLastStatus: true,
+	})
+	if err != nil {
+		t.Fatalf("Could not set up store: %s", err.Error())
+	}
+	return s
+}
+
+var bpTags = map[store.Kind]string{store.KindBusinessProcess: "app"}
+
+func resultSet(at time.Time, st status.Status, changed bool) *store.ResultSet {
+	return &store.ResultSet{
+		Start:         at,
+		Tags:          bpTags,
+		Vals:          map[string]bool{"in_availability": true},
+		Status:        st,
+		WasChecked:    true,
+		StatusChanged: changed,
+		Children: []*store.ResultSet{
+			{
+				Start:  at,
+				Tags:   map[store.Kind]string{store.KindBusinessProcess: "app", store.KindKeyPerformanceIndicator: "db"},
+				Status: st,
+			},
+		},
+	}
+}
+
+func TestGetSpans(t *testing.T) {
+	s := newTestStore(t, "memory://")
+
+	start := time.Unix(1500000000, 0)
+	writes := []struct {
+		offset  time.Duration
+		status  status.Status
+		changed bool
+	}{
+		{offset: 20 * time.Minute, status: status.StatusOK, changed: true},
+		{offset: -5 * time.Minute, status: status.StatusOK, changed: false},
+		{offset: 10 * time.Minute, status: status.StatusNOK, changed: true},
+		{offset: 15 * time.Minute, status: status.StatusNOK, changed: false},
+	}
+	for _, w := range writes {
+		if err := s.Write(resultSet(start.Add(w.offset), w.status, w.changed)); err != nil {
+			t.Fatalf("Error while writing: %s", err.Error())
+		}
+	}
+
+	spans, err := s.GetSpans(store.ResultSet{Tags: bpTags}, start, start.Add(time.Hour), 5*time.Minute, nil)
+	if err != nil {
+		t.Fatalf("Error while reading spans: %s", err.Error())
+	}
+	expected := []status.Status{status.StatusOK, status.StatusNOK, status.StatusOK}
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d spans, got %d: %+v", len(expected), len(spans), spans)
+	}
+	for i, st := range expected {
+		if spans[i].Status != st {
+			t.Errorf("Expected span %d to be '%s', got '%s'", i, st, spans[i].Status)
+		}
+	}
+
+	latest, err := s.GetLatest(store.ResultSet{Tags: bpTags})
+	if err != nil {
+		t.Fatalf("Error while reading latest: %s", err.Error())
+	}
+	if !latest.Start.Equal(start.Add(20 * time.Minute)) {
+		t.Errorf("Expected latest result set to be the one with the latest time, got %s", latest.Start)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bpmon-memory")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	connection := "file://" + filepath.Join(dir, "bpmon.jsonl")
+
+	at := time.Unix(1500000000, 123)
+	s := newTestStore(t, connection)
+	if err := s.Write(resultSet(at, status.StatusNOK, true)); err != nil {
+		t.Fatalf("Error while writing: %s", err.Error())
+	}
+	if _, err := s.Annotate(store.NewID(at, bpTags), "maintenance"); err != nil {
+		t.Fatalf("Error while annotating: %s", err.Error())
+	}
+
+	reloaded := newTestStore(t, connection)
+	latest, err := reloaded.GetLatest(store.ResultSet{Tags: bpTags})
+	if err != nil {
+		t.Fatalf("Error while reading latest after reload: %s", err.Error())
+	}
+	if !latest.Start.Equal(at) || latest.Status != status.StatusNOK || !latest.StatusChanged || latest.Annotation != "maintenance" || !latest.Vals["in_availability"] {
+		t.Errorf("Result set read after reload does not match the one written: %+v", latest)
+	}
+
+	// records appended by another store sharing the file are read
+	later := at.Add(time.Minute)
+	if err := s.Write(resultSet(later, status.StatusOK, true)); err != nil {
+		t.Fatalf("Error while writing: %s", err.Error())
+	}
+	latest, err = reloaded.GetLatest(store.ResultSet{Tags: bpTags})
+	if err != nil {
+		t.Fatalf("Error while reading latest written by another store: %s", err.Error())
+	}
+	if !latest.Start.Equal(later) {
+		t.Errorf("Expected result set written by another store to be read, got %+v", latest)
+	}
+
+	msg, err := reloaded.Health()
+	if err != nil {
+		t.Errorf("Unexpected error from health check: %s", err.Error())
+	}
+	if msg == "" {
+		t.Errorf("Expected health message to be not empty")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	for _, connection := range []string{"file://", "influx://localhost"} {
+		_, err := Setup(store.Config{Connection: connection})
+		if err == nil {
+			t.Errorf("Error expected for connection '%s' but got nil", connection)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(conf store.Config) (store.Accessor, error) {
+		conf.Kind = "memory"
+		conf.Connection = "memory://"
+		return Setup(conf)
+	})
+}
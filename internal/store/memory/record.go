@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// record is a result set without its children as kept in memory and written
+// to the file, one record per line.
+type record struct {
+	Kind       store.Kind            `json:"kind"`
+	Time       time.Time             `json:"time"`
+	Tags       map[store.Kind]string `json:"tags"`
+	Status     status.Status         `json:"status"`
+	Was        *status.Status        `json:"was,omitempty"`
+	Changed    *bool                 `json:"changed,omitempty"`
+	Annotated  bool                  `json:"annotated"`
+	Annotation string                `json:"annotation,omitempty"`
+	Output     string                `json:"output,omitempty"`
+	Err        string                `json:"err,omitempty"`
+	Vals       map[string]bool       `json:"vals,omitempty"`
+}
+
+func newRecord(rs *store.ResultSet) record {
+	r := record{
+		Kind:       rs.Kind(),
+		Time:       rs.Start,
+		Tags:       make(map[store.Kind]string),
+		Status:     rs.Status,
+		Annotated:  rs.Annotated,
+		Annotation: rs.Annotation,
+		Output:     rs.Output,
+		Vals:       make(map[string]bool),
+	}
+	for k, v := range rs.Tags {
+		r.Tags[k] = v
+	}
+	for k, v := range rs.Vals {
+		r.Vals[k] = v
+	}
+	if rs.Err != nil {
+		r.Err = rs.Err.Error()
+	}
+	if rs.WasChecked {
+		was := rs.Was
+		changed := rs.StatusChanged
+		r.Was = &was
+		r.Changed = &changed
+	}
+	return r
+}
+
+// key identifies the entity and time of the record.
+func (r record) key() string {
+	var pairs []string
+	for k, v := range r.Tags {
+		pairs = append(pairs, k.String()+"="+v)
+	}
+	sort.Strings(pairs)
+	return fmt.Sprintf("%s %d %s", r.Kind, r.Time.UnixNano(), strings.Join(pairs, ";"))
+}
+
+// matches returns true if all tags provided are equal to the tags of the
+// record.
+func (r record) matches(tags map[store.Kind]string) bool {
+	for k, v := range tags {
+		if r.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (r record) asResultSet() store.ResultSet {
+	out := store.ResultSet{
+		Start:      r.Time,
+		Tags:       make(map[store.Kind]string),
+		Vals:       make(map[string]bool),
+		Status:     r.Status,
+		Annotated:  r.Annotated,
+		Annotation: r.Annotation,
+		Output:     r.Output,
+	}
+	for k, v := range r.Tags {
+		out.Tags[k] = v
+	}
+	for k, v := range r.Vals {
+		out.Vals[k] = v
+	}
+	if r.Was != nil {
+		out.WasChecked = true
+		out.Was = *r.Was
+	}
+	if r.Changed != nil {
+		out.StatusChanged = *r.Changed
+	}
+	if r.Err != "" {
+		out.Err = errors.New(r.Err)
+	}
+	out.ID = out.Tags[out.Kind()]
+	return out
+}
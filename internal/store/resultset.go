@@ -23,6 +23,7 @@ type ResultSet struct {
 	Annotation    string
 	Err           error
 	Output        string
+	Rule          string
 	Responsible   string
 	Children      []*ResultSet
 }
@@ -39,6 +40,9 @@ func (rs ResultSet) Kind() Kind {
 	if _, ok := rs.Tags[KindService]; ok {
 		kind = KindService
 	}
+	if _, ok := rs.Tags[KindReference]; ok {
+		kind = KindReference
+	}
 	return kind
 }
 
@@ -108,6 +112,10 @@ const (
 	// KindService is used for Service
 	KindService Kind = "SVC"
 
+	// KindReference is used for Business Processes referenced by a Key
+	// Performance Indicator of another Business Process
+	KindReference Kind = "REF"
+
 	// KindUnknown is used for Unknown types
 	KindUnknown Kind = "UNKNOWN"
 )
@@ -23,3 +23,171 @@ type Span struct {
 func (s *Span) SetID() {
 	s.ID = NewID(s.Start, s.Tags)
 }
+
+// Spans is a list of 'Span'.
+type Spans []Span
+
+// FilterByStatus returns all spans matching one of the stati provided. If no
+// status is provided, all spans are returned.
+func (s Spans) FilterByStatus(stati []status.Status) Spans {
+	if len(stati) == 0 {
+		return s
+	}
+	var out Spans
+	for _, span := range s {
+		for _, st := range stati {
+			if span.Status == st {
+				out = append(out, span)
+			}
+		}
+	}
+	return out
+}
+
+// SpansFromChanges calculates the spans between 'start' and 'end' from the
+// result sets flagged as status change ('StatusChanged'), ordered by time.
+// 'last' is the latest result set persisted before 'start' and determines
+// the status until the first change. If 'last' is nil the status until the
+// first change is 'unknown' and 'reason' is used as annotation.
+func SpansFromChanges(tags map[Kind]string, changes []ResultSet, last *ResultSet, reason string, start time.Time, end time.Time) Spans {
+	out := Spans{}
+	totalDuration := end.Sub(start).Seconds()
+
+	earliestSpan := end
+	for i, resultset := range changes {
+		current := Span{
+			Tags:       resultset.Tags,
+			Pseudo:     false,
+			Status:     resultset.Status,
+			Annotation: resultset.Annotation,
+		}
+		current.Start = resultset.Start
+		if current.Start.Before(earliestSpan) {
+			earliestSpan = current.Start
+		}
+		current.End = end
+		if next := i + 1; next < len(changes) {
+			current.End = changes[next].Start
+		}
+		current.Duration = current.End.Sub(current.Start).Seconds()
+		current.DurationPercent = 100.0 / totalDuration * current.Duration
+		current.SetID()
+		out = append(out, current)
+	}
+
+	if last == nil {
+		// if no state at all is found
+		complete := Span{
+			Status:     status.StatusUnknown,
+			Pseudo:     true,
+			Annotation: reason,
+			Start:      start,
+			End:        earliestSpan,
+			Tags:       tags,
+		}
+		complete.Duration = complete.End.Sub(complete.Start).Seconds()
+		complete.DurationPercent = 100.0 / totalDuration * complete.Duration
+		complete.SetID()
+		return append(Spans{complete}, out...)
+	}
+
+	duration := earliestSpan.Sub(start).Seconds()
+	durationPercent := 100.0 / totalDuration * duration
+	first := Span{
+		Start:           start,
+		End:             earliestSpan,
+		Pseudo:          true,
+		Duration:        duration,
+		DurationPercent: durationPercent,
+		Tags:            last.Tags,
+		Status:          last.Status,
+		Annotation:      last.Annotation,
+	}
+	first.SetID()
+	return append(Spans{first}, out...)
+}
+
+// AssumeSpans calculates the spans between 'start' and 'end' from the result
+// sets provided, ordered by time, if only result sets with a status other
+// than 'ok' are persisted. Each result set is assumed to last for 'interval'
+// but not beyond 'end', all gaps are considered 'ok'.
+func AssumeSpans(tags map[Kind]string, rows []ResultSet, start time.Time, end time.Time, interval time.Duration) Spans {
+	duration := end.Sub(start).Seconds()
+	s := Spans{
+		{
+			Status:     status.StatusOK,
+			Annotation: "",
+			Start:      start,
+			End:        end,
+			Tags:       tags,
+		},
+	}
+
+	lastIndex := len(s) - 1
+
+	for _, row := range rows {
+		last := s[lastIndex]
+		replace := false
+
+		current := Span{
+			Tags:       row.Tags,
+			Status:     row.Status,
+			Start:      row.Start,
+			Annotation: row.Annotation,
+		}
+		current.End = current.Start.Add(interval)
+		if current.End.After(end) {
+			current.End = end
+		}
+		if current.Start.Before(last.End) {
+			if last.Status == current.Status {
+				current.Start = last.Start
+				current.Annotation = last.Annotation
+				replace = true
+			} else {
+				last.End = current.Start
+				s[lastIndex] = last
+			}
+		} else if current.Start.After(last.End) {
+			filler := Span{
+				Start:      last.End,
+				End:        current.Start,
+				Status:     status.StatusOK,
+				Annotation: "",
+				Tags:       tags,
+			}
+			s = append(s, filler)
+		}
+
+		// igrnore that case for now
+		// if current.End.Before(last.End) {}
+
+		if replace {
+			s[lastIndex] = current
+		} else {
+			s = append(s, current)
+		}
+
+		lastIndex = len(s) - 1
+	}
+
+	lastEvent := s[len(s)-1]
+	if lastEvent.End.Before(end) {
+		filler := Span{
+			Start:      lastEvent.End,
+			End:        end,
+			Status:     status.StatusOK,
+			Annotation: "",
+			Tags:       tags,
+		}
+		s = append(s, filler)
+	}
+
+	for i, e := range s {
+		e.Duration = e.End.Sub(e.Start).Seconds()
+		e.DurationPercent = 100.0 / duration * e.Duration
+		e.SetID()
+		s[i] = e
+	}
+	return s
+}
@@ -0,0 +1,162 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// tagColumns maps the tags of a result set to the columns of the results
+// table.
+var tagColumns = map[store.Kind]string{
+	store.KindBusinessProcess:         "bp",
+	store.KindKeyPerformanceIndicator: "kpi",
+	store.KindService:                 "svc",
+	store.KindReference:               "ref",
+}
+
+const columns = "kind, time, bp, kpi, svc, ref, status, was, changed, annotated, annotation, output, err, vals"
+
+const upsertStatement = "INSERT INTO results (" + columns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) " +
+	"ON CONFLICT (kind, bp, kpi, svc, ref, time) DO UPDATE SET " +
+	"status = excluded.status, was = excluded.was, changed = excluded.changed, annotated = excluded.annotated, " +
+	"annotation = excluded.annotation, output = excluded.output, err = excluded.err, vals = excluded.vals"
+
+// row represents a row of the results table.
+type row struct {
+	kind       string
+	time       int64
+	bp         string
+	kpi        string
+	svc        string
+	ref        string
+	status     int64
+	was        dbsql.NullInt64
+	changed    dbsql.NullBool
+	annotated  bool
+	annotation string
+	output     string
+	err        string
+	vals       string
+}
+
+func (r *row) dest() []interface{} {
+	return []interface{}{
+		&r.kind, &r.time, &r.bp, &r.kpi, &r.svc, &r.ref, &r.status, &r.was,
+		&r.changed, &r.annotated, &r.annotation, &r.output, &r.err, &r.vals,
+	}
+}
+
+func (r row) args() []interface{} {
+	var was, changed interface{}
+	if r.was.Valid {
+		was = r.was.Int64
+	}
+	if r.changed.Valid {
+		changed = r.changed.Bool
+	}
+	return []interface{}{
+		r.kind, r.time, r.bp, r.kpi, r.svc, r.ref, r.status, was,
+		changed, r.annotated, r.annotation, r.output, r.err, r.vals,
+	}
+}
+
+// asRows returns the rows to be written for a result set and all its
+// children. As in other stores, results with status 'ok' are only written
+// if their kind is listed in 'saveOK'.
+func (s *SQL) asRows(rs *store.ResultSet) []row {
+	var out []row
+
+	if rs.Status != status.StatusOK || stringInSlice(rs.Kind().String(), s.saveOK) {
+		vals, _ := json.Marshal(rs.Vals)
+		r := row{
+			kind:       rs.Kind().String(),
+			time:       rs.Start.UnixNano(),
+			bp:         rs.Tags[store.KindBusinessProcess],
+			kpi:        rs.Tags[store.KindKeyPerformanceIndicator],
+			svc:        rs.Tags[store.KindService],
+			ref:        rs.Tags[store.KindReference],
+			status:     int64(rs.Status.Int()),
+			annotated:  rs.Annotated,
+			annotation: rs.Annotation,
+			output:     rs.Output,
+			vals:       string(vals),
+		}
+		if rs.Err != nil {
+			r.err = rs.Err.Error()
+		}
+		if rs.WasChecked {
+			r.was = dbsql.NullInt64{Int64: int64(rs.Was.Int()), Valid: true}
+			r.changed = dbsql.NullBool{Bool: rs.StatusChanged, Valid: true}
+		}
+		out = append(out, r)
+	}
+
+	for _, childRs := range rs.Children {
+		out = append(out, s.asRows(childRs)...)
+	}
+	return out
+}
+
+func (r row) asResultSet() (store.ResultSet, error) {
+	var err error
+	out := store.ResultSet{
+		Start:      time.Unix(0, r.time),
+		Tags:       make(map[store.Kind]string),
+		Vals:       make(map[string]bool),
+		Annotated:  r.annotated,
+		Annotation: r.annotation,
+		Output:     r.output,
+	}
+
+	values := map[store.Kind]string{
+		store.KindBusinessProcess:         r.bp,
+		store.KindKeyPerformanceIndicator: r.kpi,
+		store.KindService:                 r.svc,
+		store.KindReference:               r.ref,
+	}
+	for kind, value := range values {
+		if value != "" {
+			out.Tags[kind] = value
+		}
+	}
+
+	out.Status, err = status.FromInt64(r.status)
+	if err != nil {
+		return out, err
+	}
+	if r.was.Valid {
+		out.WasChecked = true
+		out.Was, err = status.FromInt64(r.was.Int64)
+		if err != nil {
+			return out, err
+		}
+	}
+	out.StatusChanged = r.changed.Valid && r.changed.Bool
+	if r.err != "" {
+		out.Err = errors.New(r.err)
+	}
+	if r.vals != "" {
+		err = json.Unmarshal([]byte(r.vals), &out.Vals)
+		if err != nil {
+			return out, err
+		}
+	}
+
+	out.ID = out.Tags[out.Kind()]
+	return out, nil
+}
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if strings.EqualFold(a, b) {
+			return true
+		}
+	}
+	return false
+}
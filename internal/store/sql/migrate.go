@@ -0,0 +1,72 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+)
+
+// migrations holds the statements required to bring the schema from one
+// version to the next. The version of a schema is the number of migrations
+// applied. Migrations must never be changed once released, add a new one
+// instead. The statements must work on all supported databases.
+var migrations = [][]string{
+	{
+		`CREATE TABLE results (
+			kind       VARCHAR(16) NOT NULL,
+			time       BIGINT      NOT NULL,
+			bp         TEXT        NOT NULL DEFAULT '',
+			kpi        TEXT        NOT NULL DEFAULT '',
+			svc        TEXT        NOT NULL DEFAULT '',
+			ref        TEXT        NOT NULL DEFAULT '',
+			status     INTEGER     NOT NULL,
+			was        INTEGER     NULL,
+			changed    BOOLEAN     NULL,
+			annotated  BOOLEAN     NOT NULL DEFAULT FALSE,
+			annotation TEXT        NOT NULL DEFAULT '',
+			output     TEXT        NOT NULL DEFAULT '',
+			err        TEXT        NOT NULL DEFAULT '',
+			vals       TEXT        NOT NULL DEFAULT '{}'
+		)`,
+		`CREATE UNIQUE INDEX results_entity_time ON results (kind, bp, kpi, svc, ref, time)`,
+		`CREATE INDEX results_changed ON results (kind, bp, changed, time)`,
+	},
+}
+
+const schemaTable = "bpmon_schema"
+
+// migrate applies all migrations not yet applied to the database. Each
+// migration is applied in its own transaction.
+func (s *SQL) migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL)", schemaTable))
+	if err != nil {
+		return fmt.Errorf("could not create schema table: %s", err.Error())
+	}
+
+	var version int
+	err = s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", schemaTable)).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("could not read schema version: %s", err.Error())
+	}
+
+	for v := version; v < len(migrations); v++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range migrations[v] {
+			s.debug(stmt)
+			if _, err = tx.ExecContext(ctx, stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration to schema version %d failed: %s", v+1, err.Error())
+			}
+		}
+		if _, err = tx.ExecContext(ctx, s.rebind(fmt.Sprintf("INSERT INTO %s (version) VALUES (?)", schemaTable)), v+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration to schema version %d failed: %s", v+1, err.Error())
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("migration to schema version %d failed: %s", v+1, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package sql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// where holds the conditions shared by select and update queries. All
+// values are passed as arguments using '?' placeholders.
+type where struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (w *where) add(condition string, args ...interface{}) {
+	w.conditions = append(w.conditions, condition)
+	w.args = append(w.args, args...)
+}
+
+func (w *where) from(kind store.Kind) {
+	w.add("kind = ?", kind.String())
+}
+
+func (w *where) tags(tags map[store.Kind]string) {
+	var kinds []string
+	for kind := range tags {
+		kinds = append(kinds, kind.String())
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		column, ok := tagColumns[store.Kind(kind)]
+		if !ok {
+			// unknown tags cannot match any row
+			w.add("1 = 0")
+			continue
+		}
+		w.add(column+" = ?", tags[store.Kind(kind)])
+	}
+}
+
+func (w where) query() string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conditions, " AND ")
+}
+
+type selectquery struct {
+	where   where
+	orderBy string
+	desc    bool
+	limit   int
+}
+
+func newSelectQuery() *selectquery {
+	return &selectquery{}
+}
+
+func (sq *selectquery) From(kind store.Kind) *selectquery {
+	sq.where.from(kind)
+	return sq
+}
+
+func (sq *selectquery) Between(s time.Time, e time.Time) *selectquery {
+	sq.where.add("time < ?", e.UnixNano())
+	sq.where.add("time > ?", s.UnixNano())
+	return sq
+}
+
+func (sq *selectquery) FilterTags(tags map[store.Kind]string) *selectquery {
+	sq.where.tags(tags)
+	return sq
+}
+
+func (sq *selectquery) Filter(filter string, args ...interface{}) *selectquery {
+	sq.where.add(filter, args...)
+	return sq
+}
+
+func (sq *selectquery) OrderBy(orderBy string) *selectquery {
+	sq.orderBy = orderBy
+	return sq
+}
+
+func (sq *selectquery) Desc() *selectquery {
+	sq.desc = true
+	return sq
+}
+
+func (sq *selectquery) Limit(limit int) *selectquery {
+	sq.limit = limit
+	return sq
+}
+
+func (sq *selectquery) Query() (string, []interface{}) {
+	order := ""
+	if sq.orderBy != "" {
+		order = fmt.Sprintf(" ORDER BY %s ", sq.orderBy)
+		if sq.desc {
+			order += "DESC"
+		} else {
+			order += "ASC"
+		}
+	}
+	limit := ""
+	if sq.limit > 0 {
+		limit = fmt.Sprintf(" LIMIT %d", sq.limit)
+	}
+	return fmt.Sprintf("SELECT %s FROM results%s%s%s", columns, sq.where.query(), order, limit), sq.where.args
+}
+
+type updatequery struct {
+	where  where
+	fields []string
+	values []interface{}
+}
+
+func newUpdateQuery() *updatequery {
+	return &updatequery{}
+}
+
+func (uq *updatequery) From(kind store.Kind) *updatequery {
+	uq.where.from(kind)
+	return uq
+}
+
+func (uq *updatequery) FilterTags(tags map[store.Kind]string) *updatequery {
+	uq.where.tags(tags)
+	return uq
+}
+
+func (uq *updatequery) Filter(filter string, args ...interface{}) *updatequery {
+	uq.where.add(filter, args...)
+	return uq
+}
+
+func (uq *updatequery) Set(field string, value interface{}) *updatequery {
+	uq.fields = append(uq.fields, field+" = ?")
+	uq.values = append(uq.values, value)
+	return uq
+}
+
+func (uq *updatequery) Query() (string, []interface{}) {
+	args := append(append([]interface{}{}, uq.values...), uq.where.args...)
+	return fmt.Sprintf("UPDATE results SET %s%s", strings.Join(uq.fields, ", "), uq.where.query()), args
+}
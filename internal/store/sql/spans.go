@@ -0,0 +1,57 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// lastStatusGap defines how far before the start of the requested period the
+// last status is looked up.
+const lastStatusGap = 30 * time.Minute
+
+func (s *SQL) GetSpans(rs store.ResultSet, start time.Time, end time.Time, interval time.Duration, stati []status.Status) ([]store.Span, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	var out store.Spans
+	var err error
+	if s.getLastStatus && stringInSlice(rs.Kind().String(), s.saveOK) {
+		out, err = s.getSpans(ctx, rs, start, end)
+	} else {
+		out, err = s.assumeSpans(ctx, rs, start, end, interval)
+	}
+	return out.FilterByStatus(stati), err
+}
+
+func (s *SQL) getSpans(ctx context.Context, rs store.ResultSet, start time.Time, end time.Time) (store.Spans, error) {
+	q := newSelectQuery().From(rs.Kind()).Between(start, end).FilterTags(rs.Tags).Filter("changed = ?", true).OrderBy("time")
+	resultsets, err := s.run(ctx, q)
+	if err != nil {
+		msg := fmt.Sprintf("Cannot run query, error is: %s", err.Error())
+		return store.Spans{}, errors.New(msg)
+	}
+
+	// get last state before 'start'
+	q = newSelectQuery().From(rs.Kind()).Between(start.Add(-lastStatusGap), start).FilterTags(rs.Tags).OrderBy("time").Desc().Limit(1)
+	last, err := s.first(ctx, q)
+	if err != nil {
+		reason := fmt.Sprintf("error: %s - no status found between %s and %s", err.Error(), start.Add(-lastStatusGap).Format(time.RFC3339), start.Format(time.RFC3339))
+		return store.SpansFromChanges(rs.Tags, resultsets, nil, reason, start, end), nil
+	}
+	return store.SpansFromChanges(rs.Tags, resultsets, &last, "", start, end), nil
+}
+
+func (s *SQL) assumeSpans(ctx context.Context, rs store.ResultSet, start time.Time, end time.Time, interval time.Duration) (store.Spans, error) {
+	q := newSelectQuery().From(rs.Kind()).Between(start, end).FilterTags(rs.Tags).OrderBy("time")
+	rows, err := s.run(ctx, q)
+	if err != nil {
+		msg := fmt.Sprintf("Cannot run query, error is: %s", err.Error())
+		return store.AssumeSpans(rs.Tags, nil, start, end, interval), errors.New(msg)
+	}
+	return store.AssumeSpans(rs.Tags, rows, start, end, interval), nil
+}
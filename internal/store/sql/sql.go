@@ -0,0 +1,290 @@
+// Package sql provides a 'store.Accessor' implementation which persists the
+// results to a relational database. SQLite and PostgreSQL are supported, the
+// schema is created and migrated automatically.
+package sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// database drivers
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+const (
+	driverSQLite   = "sqlite3"
+	driverPostgres = "postgres"
+)
+
+type SQL struct {
+	db            *dbsql.DB
+	driver        string
+	saveOK        []string
+	printQueries  bool
+	getLastStatus bool
+	timeout       time.Duration
+
+	mu       sync.Mutex
+	migrated bool
+}
+
+func init() {
+	store.Register("sql", Setup)
+}
+
+// Setup configures the 'Accessor' implementation and returns it. The driver
+// is determined by the scheme of the connection string:
+//
+//   - sqlite3://[path] opens the SQLite database file at 'path'
+//   - postgres://[user]:[passwd]@[hostname]:[port]/[database]?[params]
+//     connects to a PostgreSQL database
+//
+// The database is not accessed until the first operation is performed.
+func Setup(conf store.Config) (store.Accessor, error) {
+	driver, dsn, err := parseConnection(conf.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := dbsql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == driverSQLite {
+		// SQLite does not support concurrent writes, also each connection to
+		// an in-memory database would open a new database.
+		db.SetMaxOpenConns(1)
+	}
+
+	s := &SQL{
+		db:            db,
+		driver:        driver,
+		saveOK:        conf.SaveOK,
+		printQueries:  conf.Debug,
+		getLastStatus: conf.GetLastStatus,
+		timeout:       conf.Timeout,
+	}
+	return s, nil
+}
+
+func parseConnection(connection string) (driver string, dsn string, err error) {
+	u, err := url.Parse(connection)
+	if err != nil {
+		return "", "", err
+	}
+	switch u.Scheme {
+	case "sqlite", "sqlite3":
+		dsn = strings.TrimPrefix(connection, u.Scheme+"://")
+		if dsn == "" {
+			return "", "", errors.New("path of the sqlite database is missing in connection string")
+		}
+		return driverSQLite, dsn, nil
+	case "postgres", "postgresql":
+		return driverPostgres, connection, nil
+	}
+	return "", "", fmt.Errorf("database '%s' is not supported, use sqlite3 or postgres", u.Scheme)
+}
+
+// context returns a context which is done once the timeout configured is
+// exceeded.
+func (s *SQL) context() (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+// prepare migrates the schema if this has not happened yet.
+func (s *SQL) prepare(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.migrated {
+		return nil
+	}
+	err := s.migrate(ctx)
+	if err != nil {
+		return err
+	}
+	s.migrated = true
+	return nil
+}
+
+// Close closes the database. Queries in progress are completed first.
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQL) Health() (string, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	err := s.db.PingContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	err = s.prepare(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s database is reachable, schema version is %d", s.driver, len(migrations)), nil
+}
+
+// Write implements the 'Accessor' interface. If 'debug' is set, the
+// statements are printed but not executed, just as with the other stores.
+func (s *SQL) Write(rs *store.ResultSet) error {
+	stmt := s.rebind(upsertStatement)
+	if s.printQueries {
+		for _, r := range s.asRows(rs) {
+			s.debug(stmt, r.args()...)
+		}
+		return nil
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	err := s.prepare(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, r := range s.asRows(rs) {
+		_, err = tx.ExecContext(ctx, stmt, r.args()...)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQL) GetLatest(rs store.ResultSet) (store.ResultSet, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	q := newSelectQuery().From(rs.Kind()).FilterTags(rs.Tags).OrderBy("time").Desc().Limit(1)
+	return s.first(ctx, q)
+}
+
+func (s *SQL) Annotate(id store.ID, annotation string) (store.ResultSet, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	rs, err := id.GetResultSet()
+	if err != nil {
+		return rs, err
+	}
+
+	q := newSelectQuery().From(rs.Kind()).FilterTags(rs.Tags).Filter("time = ?", rs.Start.UnixNano()).Limit(1)
+	rs, err = s.first(ctx, q)
+	if err != nil {
+		return rs, err
+	}
+
+	rs.Annotated = true
+	rs.Annotation = annotation
+
+	u := newUpdateQuery().From(rs.Kind()).FilterTags(rs.Tags).Filter("time = ?", rs.Start.UnixNano())
+	u.Set("annotated", true).Set("annotation", annotation)
+	stmt, args := u.Query()
+	stmt = s.rebind(stmt)
+	s.debug(stmt, args...)
+	if s.printQueries {
+		return rs, nil
+	}
+	_, err = s.db.ExecContext(ctx, stmt, args...)
+	return rs, err
+}
+
+func (s *SQL) first(ctx context.Context, q *selectquery) (store.ResultSet, error) {
+	var out store.ResultSet
+
+	all, err := s.run(ctx, q)
+	if err != nil {
+		return out, err
+	}
+
+	if len(all) == 0 {
+		return out, errors.New("no data returned")
+	}
+
+	out = all[0]
+	return out, nil
+}
+
+func (s *SQL) run(ctx context.Context, q *selectquery) ([]store.ResultSet, error) {
+	var out []store.ResultSet
+
+	err := s.prepare(ctx)
+	if err != nil {
+		return out, err
+	}
+
+	stmt, args := q.Query()
+	stmt = s.rebind(stmt)
+	s.debug(stmt, args...)
+
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r row
+		err = rows.Scan(r.dest()...)
+		if err != nil {
+			return out, err
+		}
+		rs, err := r.asResultSet()
+		if err != nil {
+			return out, err
+		}
+		out = append(out, rs)
+	}
+	return out, rows.Err()
+}
+
+// rebind replaces the '?' placeholders of a statement with the placeholders
+// of the driver in use.
+func (s *SQL) rebind(stmt string) string {
+	if s.driver != driverPostgres {
+		return stmt
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range stmt {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (s *SQL) debug(stmt string, args ...interface{}) {
+	if !s.printQueries {
+		return
+	}
+	if len(args) > 0 {
+		fmt.Printf("%s %v\n", stmt, args)
+		return
+	}
+	fmt.Println(stmt)
+}
@@ -1,6 +1,7 @@
 // Package store provides an interface that allows to implement various
 // backends to be loaded in compile time. This makes the persistence layer
-// of BPMON interchangeable.
+// of BPMON interchangeable. Implementations should run the conformance test
+// suite provided by the package 'storetest'.
 package store
 
 import (
@@ -17,12 +18,17 @@ import (
 // requested.
 type Config struct {
 	// kind defines the store implementation to be used by BPMON. Currently
-	// only influx is implemented.
+	// influx, sql and memory are implemented.
 	Kind string `yaml:"kind"`
 
 	// The connection string describes how to connect to your Influx Database.
 	// The string needs to follow the pattern:
 	//   [protocol]://[user]:[passwd]@[hostname]:[port]
+	// If kind is sql, the scheme selects the database:
+	//   sqlite3://[path to database file]
+	//   postgres://[user]:[passwd]@[hostname]:[port]/[database]?sslmode=disable
+	// If kind is memory, use memory:// to keep the data in memory only or
+	// file://[path] to persist the data to a file.
 	Connection string `yaml:"connection"`
 
 	// timeout is read as a go (golang) duration, please refer to
@@ -43,7 +49,7 @@ type Config struct {
 	GetLastStatus bool `yaml:"get_last_status"`
 
 	// if debug is set to true all queries generated and executed by bpmon will
-	// be logged to stdout.
+	// be logged to stdout. Results are then printed rather than written.
 	Debug bool `yaml:"debug"`
 
 	// BPMON verifies if a https connection is trusted. If you wont to trust a
@@ -143,3 +149,19 @@ type Accessor interface {
 	// its 'ID'. It also updates its field 'Annotated' to 'true'.
 	Annotate(id ID, annotation string) (ResultSet, error)
 }
+
+// Closer is implemented by stores which hold resources, eg. connections to a
+// database, that must be released once the store is no longer used.
+type Closer interface {
+	// Close releases the resources of the store. The store must not be used
+	// afterwards.
+	Close() error
+}
+
+// Close releases the resources of the store if it implements 'Closer'.
+func Close(a Accessor) error {
+	if c, ok := a.(Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
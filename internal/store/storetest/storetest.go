@@ -0,0 +1,405 @@
+// Package storetest provides a conformance test suite for 'store.Accessor'
+// implementations. Each implementation should run the suite in its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(conf store.Config) (store.Accessor, error) {
+//			conf.Connection = ...
+//			return Setup(conf)
+//		})
+//	}
+//
+// All timestamps written by the suite are whole seconds in order to support
+// stores which do not persist a higher precision.
+package storetest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/unprofession-al/bpmon/internal/status"
+	"github.com/unprofession-al/bpmon/internal/store"
+)
+
+// SetupFunc returns a new, empty store configured according to the
+// configuration provided. Only 'SaveOK' and 'GetLastStatus' are set by the
+// suite, all other fields must be set by the function if required.
+type SetupFunc func(conf store.Config) (store.Accessor, error)
+
+// interval is the interval the scripted result sets are written in.
+const interval = 5 * time.Minute
+
+// start is the beginning of the period the spans are requested for.
+var start = time.Unix(1500000000, 0).UTC()
+
+var (
+	bpTags  = map[store.Kind]string{store.KindBusinessProcess: "app"}
+	kpiTags = map[store.Kind]string{store.KindBusinessProcess: "app", store.KindKeyPerformanceIndicator: "db"}
+	svcTags = map[store.Kind]string{store.KindBusinessProcess: "app", store.KindKeyPerformanceIndicator: "db", store.KindService: "db1!ping"}
+)
+
+// step describes a single evaluation of the business process 'app' written
+// to the store.
+type step struct {
+	offset    time.Duration
+	bp        status.Status
+	kpi       status.Status
+	changed   bool
+	unchecked bool
+}
+
+// script writes the steps as result sets composed of a business process, a
+// KPI and a service, all of the same status.
+func script(t *testing.T, s store.Accessor, steps []step) {
+	t.Helper()
+	for _, st := range steps {
+		at := start.Add(st.offset)
+		rs := &store.ResultSet{
+			ID:            "app",
+			Start:         at,
+			Tags:          bpTags,
+			Vals:          map[string]bool{"in_availability": true},
+			Status:        st.bp,
+			Was:           status.StatusUnknown,
+			WasChecked:    !st.unchecked,
+			StatusChanged: st.changed,
+			Children: []*store.ResultSet{
+				{
+					ID:     "db",
+					Start:  at,
+					Tags:   kpiTags,
+					Vals:   map[string]bool{},
+					Status: st.kpi,
+					Children: []*store.ResultSet{
+						{
+							ID:     "db1!ping",
+							Start:  at,
+							Tags:   svcTags,
+							Vals:   map[string]bool{"critical": st.kpi == status.StatusNOK},
+							Status: st.kpi,
+							Output: "PING",
+						},
+					},
+				},
+			},
+		}
+		if err := s.Write(rs); err != nil {
+			t.Fatalf("Error while writing result set at %s: %s", at, err.Error())
+		}
+	}
+}
+
+// Run runs the conformance test suite against the store returned by 'setup'.
+func Run(t *testing.T, setup SetupFunc) {
+	tests := map[string]func(*testing.T, SetupFunc){
+		"GetLatest":          testGetLatest,
+		"SaveOK":             testSaveOK,
+		"SpansFromChanges":   testSpansFromChanges,
+		"SpansWithoutStatus": testSpansWithoutStatus,
+		"AssumedSpans":       testAssumedSpans,
+		"AssumedSpansAtEnd":  testAssumedSpansAtEnd,
+		"FilterByStatus":     testFilterByStatus,
+		"Annotate":           testAnnotate,
+	}
+	for _, name := range []string{"GetLatest", "SaveOK", "SpansFromChanges", "SpansWithoutStatus", "AssumedSpans", "AssumedSpansAtEnd", "FilterByStatus", "Annotate"} {
+		test := tests[name]
+		t.Run(name, func(t *testing.T) {
+			test(t, setup)
+		})
+	}
+}
+
+func newStore(t *testing.T, setup SetupFunc) store.Accessor {
+	t.Helper()
+	s, err := setup(store.Config{
+		SaveOK:        []string{"BP"},
+		GetLastStatus: true,
+	})
+	if err != nil {
+		t.Fatalf("Could not set up store: %s", err.Error())
+	}
+	return s
+}
+
+func testGetLatest(t *testing.T, setup SetupFunc) {
+	s := newStore(t, setup)
+	script(t, s, []step{
+		{offset: 10 * time.Minute, bp: status.StatusNOK, kpi: status.StatusNOK, changed: true},
+		{offset: 0, bp: status.StatusOK, kpi: status.StatusOK},
+		{offset: 5 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK},
+	})
+
+	latest, err := s.GetLatest(store.ResultSet{Tags: bpTags})
+	if err != nil {
+		t.Fatalf("Error while reading latest result set: %s", err.Error())
+	}
+	if !latest.Start.Equal(start.Add(10 * time.Minute)) {
+		t.Errorf("Expected latest result set at %s, got %s", start.Add(10*time.Minute), latest.Start)
+	}
+	if latest.Status != status.StatusNOK || !latest.WasChecked || !latest.StatusChanged {
+		t.Errorf("Latest result set does not match the one written: %+v", latest)
+	}
+	if latest.Kind() != store.KindBusinessProcess || latest.Tags[store.KindBusinessProcess] != "app" {
+		t.Errorf("Latest result set has wrong tags: %v", latest.Tags)
+	}
+	if !latest.Vals["in_availability"] {
+		t.Errorf("Values of latest result set were not persisted: %v", latest.Vals)
+	}
+
+	svc, err := s.GetLatest(store.ResultSet{Tags: svcTags})
+	if err != nil {
+		t.Fatalf("Error while reading latest service: %s", err.Error())
+	}
+	if !svc.Vals["critical"] || svc.Kind() != store.KindService {
+		t.Errorf("Latest service does not match the one written: %+v", svc)
+	}
+
+	_, err = s.GetLatest(store.ResultSet{Tags: map[store.Kind]string{store.KindBusinessProcess: "other"}})
+	if err == nil {
+		t.Errorf("Error expected for a business process without data but got nil")
+	}
+}
+
+func testSaveOK(t *testing.T, setup SetupFunc) {
+	s := newStore(t, setup)
+	script(t, s, []step{
+		{offset: 0, bp: status.StatusNOK, kpi: status.StatusNOK, changed: true},
+		{offset: 5 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK, changed: true},
+	})
+
+	bp, err := s.GetLatest(store.ResultSet{Tags: bpTags})
+	if err != nil {
+		t.Fatalf("Error while reading latest business process: %s", err.Error())
+	}
+	if !bp.Start.Equal(start.Add(5 * time.Minute)) {
+		t.Errorf("Expected status 'ok' of business process to be persisted since it is listed in 'save_ok'")
+	}
+
+	kpi, err := s.GetLatest(store.ResultSet{Tags: kpiTags})
+	if err != nil {
+		t.Fatalf("Error while reading latest KPI: %s", err.Error())
+	}
+	if !kpi.Start.Equal(start) {
+		t.Errorf("Expected status 'ok' of KPI not to be persisted since it is not listed in 'save_ok'")
+	}
+}
+
+func testSpansFromChanges(t *testing.T, setup SetupFunc) {
+	s := newStore(t, setup)
+	script(t, s, []step{
+		{offset: -5 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK},
+		{offset: 0, bp: status.StatusOK, kpi: status.StatusOK},
+		{offset: 10 * time.Minute, bp: status.StatusNOK, kpi: status.StatusNOK, changed: true},
+		{offset: 15 * time.Minute, bp: status.StatusNOK, kpi: status.StatusNOK},
+		{offset: 20 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK, changed: true},
+		{offset: 40 * time.Minute, bp: status.StatusUnknown, kpi: status.StatusUnknown, changed: true},
+		{offset: 45 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK, changed: true},
+		{offset: 90 * time.Minute, bp: status.StatusNOK, kpi: status.StatusNOK, changed: true},
+	})
+
+	end := start.Add(time.Hour)
+	spans, err := s.GetSpans(store.ResultSet{Tags: bpTags}, start, end, interval, nil)
+	if err != nil {
+		t.Fatalf("Error while reading spans: %s", err.Error())
+	}
+	expectSpans(t, spans, start, end, []expectedSpan{
+		{status: status.StatusOK, start: 0, end: 10 * time.Minute, pseudo: true},
+		{status: status.StatusNOK, start: 10 * time.Minute, end: 20 * time.Minute},
+		{status: status.StatusOK, start: 20 * time.Minute, end: 40 * time.Minute},
+		{status: status.StatusUnknown, start: 40 * time.Minute, end: 45 * time.Minute},
+		{status: status.StatusOK, start: 45 * time.Minute, end: time.Hour},
+	})
+}
+
+func testSpansWithoutStatus(t *testing.T, setup SetupFunc) {
+	s := newStore(t, setup)
+	script(t, s, []step{
+		{offset: -2 * time.Hour, bp: status.StatusNOK, kpi: status.StatusNOK, changed: true},
+		{offset: 30 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK, changed: true},
+	})
+
+	end := start.Add(time.Hour)
+	spans, err := s.GetSpans(store.ResultSet{Tags: bpTags}, start, end, interval, nil)
+	if err != nil {
+		t.Fatalf("Error while reading spans: %s", err.Error())
+	}
+	expectSpans(t, spans, start, end, []expectedSpan{
+		{status: status.StatusUnknown, start: 0, end: 30 * time.Minute, pseudo: true},
+		{status: status.StatusOK, start: 30 * time.Minute, end: time.Hour},
+	})
+}
+
+func testAssumedSpans(t *testing.T, setup SetupFunc) {
+	s := newStore(t, setup)
+	script(t, s, []step{
+		{offset: 0, bp: status.StatusOK, kpi: status.StatusOK},
+		{offset: 10 * time.Minute, bp: status.StatusNOK, kpi: status.StatusNOK, changed: true},
+		{offset: 15 * time.Minute, bp: status.StatusNOK, kpi: status.StatusNOK},
+		{offset: 20 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK, changed: true},
+		{offset: 30 * time.Minute, bp: status.StatusOK, kpi: status.StatusUnknown},
+		{offset: 75 * time.Minute, bp: status.StatusOK, kpi: status.StatusNOK},
+	})
+
+	end := start.Add(time.Hour)
+	spans, err := s.GetSpans(store.ResultSet{Tags: kpiTags}, start, end, interval, nil)
+	if err != nil {
+		t.Fatalf("Error while reading spans: %s", err.Error())
+	}
+	expectSpans(t, spans, start, end, []expectedSpan{
+		{status: status.StatusOK, start: 0, end: 10 * time.Minute},
+		{status: status.StatusNOK, start: 10 * time.Minute, end: 15 * time.Minute},
+		{status: status.StatusNOK, start: 15 * time.Minute, end: 20 * time.Minute},
+		{status: status.StatusOK, start: 20 * time.Minute, end: 30 * time.Minute},
+		{status: status.StatusUnknown, start: 30 * time.Minute, end: 35 * time.Minute},
+		{status: status.StatusOK, start: 35 * time.Minute, end: time.Hour},
+	})
+}
+
+func testAssumedSpansAtEnd(t *testing.T, setup SetupFunc) {
+	s := newStore(t, setup)
+	script(t, s, []step{
+		{offset: 0, bp: status.StatusOK, kpi: status.StatusOK},
+		{offset: 57 * time.Minute, bp: status.StatusOK, kpi: status.StatusNOK},
+	})
+
+	end := start.Add(time.Hour)
+	spans, err := s.GetSpans(store.ResultSet{Tags: kpiTags}, start, end, interval, nil)
+	if err != nil {
+		t.Fatalf("Error while reading spans: %s", err.Error())
+	}
+	expectSpans(t, spans, start, end, []expectedSpan{
+		{status: status.StatusOK, start: 0, end: 57 * time.Minute},
+		{status: status.StatusNOK, start: 57 * time.Minute, end: time.Hour},
+	})
+}
+
+func testFilterByStatus(t *testing.T, setup SetupFunc) {
+	s := newStore(t, setup)
+	script(t, s, []step{
+		{offset: -5 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK},
+		{offset: 0, bp: status.StatusOK, kpi: status.StatusOK},
+		{offset: 10 * time.Minute, bp: status.StatusNOK, kpi: status.StatusNOK, changed: true},
+		{offset: 20 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK, changed: true},
+		{offset: 30 * time.Minute, bp: status.StatusNOK, kpi: status.StatusNOK, changed: true},
+		{offset: 40 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK, changed: true},
+	})
+
+	end := start.Add(time.Hour)
+	for _, rs := range []store.ResultSet{{Tags: bpTags}, {Tags: kpiTags}} {
+		spans, err := s.GetSpans(rs, start, end, interval, []status.Status{status.StatusNOK})
+		if err != nil {
+			t.Fatalf("Error while reading spans of %s: %s", rs.Kind(), err.Error())
+		}
+		if len(spans) != 2 {
+			t.Errorf("Expected 2 spans of %s with status '%s', got %d: %+v", rs.Kind(), status.StatusNOK, len(spans), spans)
+		}
+		for _, span := range spans {
+			if span.Status != status.StatusNOK {
+				t.Errorf("Expected only spans of %s with status '%s', got '%s'", rs.Kind(), status.StatusNOK, span.Status)
+			}
+		}
+
+		spans, err = s.GetSpans(rs, start, end, interval, []status.Status{status.StatusUnknown})
+		if err != nil {
+			t.Fatalf("Error while reading spans of %s: %s", rs.Kind(), err.Error())
+		}
+		if len(spans) != 0 {
+			t.Errorf("Expected no spans of %s with status '%s', got %d", rs.Kind(), status.StatusUnknown, len(spans))
+		}
+	}
+}
+
+func testAnnotate(t *testing.T, setup SetupFunc) {
+	s := newStore(t, setup)
+	script(t, s, []step{
+		{offset: 0, bp: status.StatusOK, kpi: status.StatusOK},
+		{offset: 10 * time.Minute, bp: status.StatusNOK, kpi: status.StatusNOK, changed: true},
+		{offset: 20 * time.Minute, bp: status.StatusOK, kpi: status.StatusOK, changed: true},
+	})
+
+	end := start.Add(time.Hour)
+	for _, rs := range []store.ResultSet{{Tags: bpTags}, {Tags: kpiTags}} {
+		spans, err := s.GetSpans(rs, start, end, interval, []status.Status{status.StatusNOK})
+		if err != nil || len(spans) != 1 {
+			t.Fatalf("Could not read span of %s to annotate: %v, %+v", rs.Kind(), err, spans)
+		}
+
+		annotated, err := s.Annotate(spans[0].ID, "maintenance of "+rs.Kind().String())
+		if err != nil {
+			t.Fatalf("Error while annotating span of %s: %s", rs.Kind(), err.Error())
+		}
+		if !annotated.Annotated || annotated.Annotation != "maintenance of "+rs.Kind().String() {
+			t.Errorf("Result set returned is not annotated: %+v", annotated)
+		}
+		if !annotated.Start.Equal(spans[0].Start) || annotated.Status != status.StatusNOK {
+			t.Errorf("Result set annotated does not match the span: %+v", annotated)
+		}
+
+		spans, err = s.GetSpans(rs, start, end, interval, []status.Status{status.StatusNOK})
+		if err != nil || len(spans) != 1 {
+			t.Fatalf("Could not read annotated span of %s: %v, %+v", rs.Kind(), err, spans)
+		}
+		if spans[0].Annotation != "maintenance of "+rs.Kind().String() {
+			t.Errorf("Expected annotation of %s to be returned as part of the span, got '%s'", rs.Kind(), spans[0].Annotation)
+		}
+	}
+
+	_, err := s.Annotate(store.NewID(start.Add(5*time.Minute), bpTags), "nothing here")
+	if err == nil {
+		t.Errorf("Error expected when annotating a nonexistent result set but got nil")
+	}
+	_, err = s.Annotate(store.ID("gibberish"), "nothing here")
+	if err == nil {
+		t.Errorf("Error expected when annotating with an invalid id but got nil")
+	}
+}
+
+type expectedSpan struct {
+	status status.Status
+	start  time.Duration
+	end    time.Duration
+	pseudo bool
+}
+
+// expectSpans verifies the spans returned against the expected spans. The
+// spans must cover the whole period without gaps and overlaps.
+func expectSpans(t *testing.T, spans []store.Span, start time.Time, end time.Time, expected []expectedSpan) {
+	t.Helper()
+	if len(spans) != len(expected) {
+		t.Fatalf("Expected %d spans, got %d: %+v", len(expected), len(spans), spans)
+	}
+
+	var percent float64
+	for i, span := range spans {
+		e := expected[i]
+		if span.Status != e.status {
+			t.Errorf("Expected span %d to be '%s', got '%s'", i, e.status, span.Status)
+		}
+		if !span.Start.Equal(start.Add(e.start)) || !span.End.Equal(start.Add(e.end)) {
+			t.Errorf("Expected span %d to last from %s to %s, got %s to %s", i, start.Add(e.start), start.Add(e.end), span.Start, span.End)
+		}
+		if span.Pseudo != e.pseudo {
+			t.Errorf("Expected span %d to be pseudo '%t', got '%t'", i, e.pseudo, span.Pseudo)
+		}
+		if span.Duration != span.End.Sub(span.Start).Seconds() {
+			t.Errorf("Duration of span %d does not match its start and end: %f", i, span.Duration)
+		}
+		if span.ID == "" {
+			t.Errorf("Span %d has no id", i)
+		}
+		if len(span.Tags) == 0 {
+			t.Errorf("Span %d has no tags", i)
+		}
+		if i > 0 && !span.Start.Equal(spans[i-1].End) {
+			t.Errorf("Span %d does not start when span %d ends", i, i-1)
+		}
+		percent += span.DurationPercent
+	}
+
+	if !spans[0].Start.Equal(start) || !spans[len(spans)-1].End.Equal(end) {
+		t.Errorf("Spans do not cover the period from %s to %s", start, end)
+	}
+	if math.Abs(percent-100.0) > 0.000001 {
+		t.Errorf("Expected the duration of all spans to sum up to 100%%, got %f%%", percent)
+	}
+}
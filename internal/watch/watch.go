@@ -0,0 +1,91 @@
+// Package watch provides a simple polling file watcher. Polling is used
+// rather than file system notifications since configuration files are often
+// mounted from network file systems or replaced via symlinks (for example in
+// kubernetes config maps) where notifications are not reliable.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Watcher checks a list of glob patterns for added, removed or modified files
+// once per interval.
+type Watcher struct {
+	interval time.Duration
+	patterns func() []string
+}
+
+// New returns a Watcher for the glob patterns provided. Refer to the
+// documentation of filepath.Match for the pattern syntax.
+func New(interval time.Duration, patterns ...string) Watcher {
+	return NewFunc(interval, func() []string { return patterns })
+}
+
+// NewFunc returns a Watcher for the glob patterns returned by 'patterns'.
+// The function is called once per interval which allows to watch files that
+// are only known once the files watched are read, eg. files referenced by
+// the configuration.
+func NewFunc(interval time.Duration, patterns func() []string) Watcher {
+	return Watcher{
+		interval: interval,
+		patterns: patterns,
+	}
+}
+
+// Run sends to 'changed' each time a modification of the files watched is
+// detected until the context is cancelled.
+func (w Watcher) Run(ctx context.Context, changed chan<- struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	last := w.Fingerprint()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current := w.Fingerprint()
+		if current == last {
+			continue
+		}
+		last = current
+
+		select {
+		case changed <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Fingerprint returns a string representing the name, size and modification
+// time of all files matching the patterns of the Watcher. Files that cannot
+// be accessed are represented as such.
+func (w Watcher) Fingerprint() string {
+	var files []string
+	for _, pattern := range w.patterns() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			files = append(files, fmt.Sprintf("%s: %s", pattern, err.Error()))
+			continue
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				files = append(files, fmt.Sprintf("%s: %s", match, err.Error()))
+				continue
+			}
+			files = append(files, fmt.Sprintf("%s: %d %d", match, info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	sort.Strings(files)
+	return strings.Join(files, "\n")
+}
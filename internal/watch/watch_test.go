@@ -0,0 +1,82 @@
+package watch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bpmon-watch")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	w := New(10*time.Millisecond, filepath.Join(dir, "*.yaml"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{})
+	go w.Run(ctx, changed)
+
+	tests := map[string]func() error{
+		"add": func() error {
+			return ioutil.WriteFile(filepath.Join(dir, "a.yaml"), []byte("a"), 0644)
+		},
+		"modify": func() error {
+			return ioutil.WriteFile(filepath.Join(dir, "a.yaml"), []byte("ab"), 0644)
+		},
+		"remove": func() error {
+			return os.Remove(filepath.Join(dir, "a.yaml"))
+		},
+	}
+
+	for _, name := range []string{"add", "modify", "remove"} {
+		time.Sleep(20 * time.Millisecond)
+		if err := tests[name](); err != nil {
+			t.Fatalf("Could not %s file: %s", name, err.Error())
+		}
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+			t.Errorf("Change '%s' was not detected", name)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Could not write file: %s", err.Error())
+	}
+	select {
+	case <-changed:
+		t.Errorf("File not matching the pattern was detected as change")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNewFunc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bpmon-watch")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	patterns := []string{filepath.Join(dir, "*.yaml")}
+	w := NewFunc(time.Second, func() []string { return patterns })
+
+	before := w.Fingerprint()
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.ics"), []byte("a"), 0644); err != nil {
+		t.Fatalf("Could not write file: %s", err.Error())
+	}
+	if w.Fingerprint() != before {
+		t.Errorf("File not matching the pattern was detected as change")
+	}
+
+	patterns = append(patterns, filepath.Join(dir, "a.ics"))
+	if w.Fingerprint() == before {
+		t.Errorf("File matching the pattern added was not detected as change")
+	}
+}